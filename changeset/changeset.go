@@ -0,0 +1,307 @@
+// Package changeset defines the versioned JSON document configdiff writes
+// for "-o json", and decodes such documents back into changes.
+//
+// A document holds the schema version, metadata about the compared inputs,
+// a summary and the changes, whose values are plain JSON values:
+//
+//	{
+//	  "schema_version": 1,
+//	  "old": {"path": "old.yaml", "format": "yaml"},
+//	  "new": {"path": "new.yaml", "format": "yaml"},
+//	  "summary": {"total": 1, "added": 0, "removed": 0, "modified": 1, "moved": 0, "normalized": 0},
+//	  "changes": [
+//	    {"type": "modify", "path": "/replicas", "old_value": 2, "new_value": 3}
+//	  ]
+//	}
+//
+// Directory comparisons (configdiff -r) write a DirDocument instead, with a
+// "files" array whose entries carry their status and changes. DecodeAny and
+// ReadChanges read both kinds.
+//
+// Both formats are described by the JSON Schema in JSONSchema. Members may be
+// added within a schema version; consumers should ignore unknown members.
+// Incompatible changes increment SchemaVersion.
+package changeset
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/parse"
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// SchemaVersion is the version of the document format written by this
+// package, and the newest it can decode.
+const SchemaVersion = 1
+
+// JSONSchema is the JSON Schema (draft 2020-12) of the document format.
+//
+//go:embed schema.json
+var JSONSchema []byte
+
+// Document is a set of changes between two inputs.
+type Document struct {
+	// SchemaVersion is the version of the format, see SchemaVersion.
+	SchemaVersion int `json:"schema_version"`
+
+	// Old and New describe the compared inputs, when known.
+	Old *Input `json:"old,omitempty"`
+	New *Input `json:"new,omitempty"`
+
+	// Summary counts the changes by type.
+	Summary Summary `json:"summary"`
+
+	// Changes lists the changes in the order they were reported.
+	Changes []Change `json:"changes"`
+}
+
+// Input describes a compared input.
+type Input struct {
+	// Path is the file name of the input, or "-" for stdin.
+	Path string `json:"path,omitempty"`
+
+	// Format is the format the input was parsed as, e.g. "yaml".
+	Format string `json:"format,omitempty"`
+}
+
+// Summary counts the changes of a document by type. Total does not count
+// values equal after normalization, which are not changes.
+type Summary struct {
+	Total      int `json:"total"`
+	Added      int `json:"added"`
+	Removed    int `json:"removed"`
+	Modified   int `json:"modified"`
+	Moved      int `json:"moved"`
+	Normalized int `json:"normalized"`
+}
+
+// Change is a single change. Values are kept as raw JSON; a missing value
+// (nil) differs from a JSON null.
+type Change struct {
+	Type     diff.ChangeType `json:"type"`
+	Path     string          `json:"path"`
+	From     string          `json:"from,omitempty"`
+	OldValue json.RawMessage `json:"old_value,omitempty"`
+	NewValue json.RawMessage `json:"new_value,omitempty"`
+
+	// Index is the array index of changes to array elements addressed by
+	// position, e.g. "/args[2]".
+	Index *int `json:"index,omitempty"`
+
+	Description string        `json:"description,omitempty"`
+	Severity    diff.Severity `json:"severity,omitempty"`
+	Category    string        `json:"category,omitempty"`
+}
+
+// FromChanges builds a document from changes. The caller may fill in Old
+// and New.
+func FromChanges(changes []diff.Change) (*Document, error) {
+	doc := &Document{
+		SchemaVersion: SchemaVersion,
+		Changes:       make([]Change, 0, len(changes)),
+	}
+
+	for _, c := range changes {
+		entry := Change{
+			Type:        c.Type,
+			Path:        c.Path,
+			From:        c.From,
+			Index:       arrayIndex(c.Path),
+			Description: c.Description,
+			Severity:    c.Severity,
+			Category:    c.Category,
+		}
+
+		var err error
+		if entry.OldValue, err = marshalNode(c.OldValue); err != nil {
+			return nil, fmt.Errorf("failed to encode old value at %s: %w", c.Path, err)
+		}
+		if entry.NewValue, err = marshalNode(c.NewValue); err != nil {
+			return nil, fmt.Errorf("failed to encode new value at %s: %w", c.Path, err)
+		}
+
+		doc.Changes = append(doc.Changes, entry)
+		doc.Summary.count(c.Type)
+	}
+
+	return doc, nil
+}
+
+// count adds a change of type t to s.
+func (s *Summary) count(t diff.ChangeType) {
+	switch t {
+	case diff.ChangeTypeAdd:
+		s.Added++
+	case diff.ChangeTypeRemove:
+		s.Removed++
+	case diff.ChangeTypeModify:
+		s.Modified++
+	case diff.ChangeTypeMove:
+		s.Moved++
+	case diff.ChangeTypeNormalized:
+		s.Normalized++
+		return
+	}
+	s.Total++
+}
+
+// Decode reads a document from r. It fails if the document has no schema
+// version, one newer than SchemaVersion, or no changes.
+func Decode(r io.Reader) (*Document, error) {
+	var doc Document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode change set: %w", err)
+	}
+
+	switch {
+	case doc.SchemaVersion == 0:
+		return nil, errors.New("failed to decode change set: no schema_version, not a configdiff JSON document")
+	case doc.SchemaVersion > SchemaVersion:
+		return nil, fmt.Errorf("failed to decode change set: schema version %d is newer than the supported version %d", doc.SchemaVersion, SchemaVersion)
+	case doc.Changes == nil:
+		return nil, errors.New("failed to decode change set: no changes member")
+	}
+	return &doc, nil
+}
+
+// ReadChanges decodes a document of either kind from r and reconstructs
+// its changes, so that archived JSON output can be fed to the report
+// generators. The changes of a directory document are those of all its
+// files, in file order; use DecodeDir to keep them apart by file.
+func ReadChanges(r io.Reader) ([]diff.Change, error) {
+	doc, dir, err := DecodeAny(r)
+	if err != nil {
+		return nil, err
+	}
+	if dir != nil {
+		return dir.DiffChanges()
+	}
+	return doc.DiffChanges()
+}
+
+// Unmarshal decodes a document from data, like Decode.
+func Unmarshal(data []byte) (*Document, error) {
+	return Decode(bytes.NewReader(data))
+}
+
+// DiffChanges reconstructs the changes of the document, with their values
+// as trees.
+func (d *Document) DiffChanges() ([]diff.Change, error) {
+	return diffChanges(d.Changes)
+}
+
+// diffChanges reconstructs encoded changes, with their values as trees.
+func diffChanges(entries []Change) ([]diff.Change, error) {
+	changes := make([]diff.Change, 0, len(entries))
+	for _, entry := range entries {
+		c := diff.Change{
+			Type:        entry.Type,
+			Path:        entry.Path,
+			From:        entry.From,
+			Description: entry.Description,
+			Severity:    entry.Severity,
+			Category:    entry.Category,
+		}
+		if entry.Index != nil {
+			c.ArrayIndex = *entry.Index
+		}
+
+		var err error
+		if c.OldValue, err = unmarshalNode(entry.OldValue, entry.Path); err != nil {
+			return nil, fmt.Errorf("invalid old value at %s: %w", entry.Path, err)
+		}
+		if c.NewValue, err = unmarshalNode(entry.NewValue, entry.Path); err != nil {
+			return nil, fmt.Errorf("invalid new value at %s: %w", entry.Path, err)
+		}
+
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// marshalNode encodes a tree as plain JSON, or returns nil for a nil tree.
+func marshalNode(n *tree.Node) (json.RawMessage, error) {
+	if n == nil {
+		return nil, nil
+	}
+	v, err := nodeToValue(n)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// unmarshalNode decodes raw JSON found at path into a tree, or returns nil
+// for a missing value.
+func unmarshalNode(raw json.RawMessage, path string) (*tree.Node, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	n, err := parse.ParseJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+	n.SetPaths(path)
+	return n, nil
+}
+
+// nodeToValue converts a tree to a plain Go value for JSON serialization.
+func nodeToValue(n *tree.Node) (interface{}, error) {
+	switch n.Kind {
+	case tree.KindNull:
+		return nil, nil
+
+	case tree.KindBool, tree.KindNumber, tree.KindString:
+		return n.Value, nil
+
+	case tree.KindObject:
+		obj := make(map[string]interface{}, len(n.Object))
+		for k, v := range n.Object {
+			value, err := nodeToValue(v)
+			if err != nil {
+				return nil, err
+			}
+			obj[k] = value
+		}
+		return obj, nil
+
+	case tree.KindArray:
+		arr := make([]interface{}, len(n.Array))
+		for i, elem := range n.Array {
+			value, err := nodeToValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = value
+		}
+		return arr, nil
+
+	default:
+		return nil, fmt.Errorf("unknown node kind: %v", n.Kind)
+	}
+}
+
+// arrayIndex returns the index of a path ending in a positional array
+// element, e.g. 2 for "/args[2]", or nil.
+func arrayIndex(path string) *int {
+	if !strings.HasSuffix(path, "]") {
+		return nil
+	}
+	open := strings.LastIndexByte(path, '[')
+	if open < 0 {
+		return nil
+	}
+	i, err := strconv.Atoi(path[open+1 : len(path)-1])
+	if err != nil || i < 0 {
+		return nil
+	}
+	return &i
+}
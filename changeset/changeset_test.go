@@ -0,0 +1,236 @@
+package changeset
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/parse"
+	"github.com/pfrederiksen/configdiff/schema"
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+func TestRoundTrip(t *testing.T) {
+	oldDoc, err := parse.ParseYAML([]byte(`
+replicas: 2
+image: app:1.0
+args: [--a, --b]
+labels: {tier: web}
+empty: null
+big: 12345678901234567890
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newDoc, err := parse.ParseYAML([]byte(`
+replicas: 3
+image: app:1.0
+args: [--a]
+tags: {tier: web}
+empty: 1
+big: 12345678901234567891
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := diff.Diff(oldDoc, newDoc, diff.Options{StableOrder: true, KeyRenameThreshold: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes[0].Severity = diff.SeverityWarning
+	changes[0].Category = "scaling"
+	changes[0].Description = "example"
+
+	doc, err := FromChanges(changes)
+	if err != nil {
+		t.Fatalf("FromChanges() error = %v", err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	got, err := decoded.DiffChanges()
+	if err != nil {
+		t.Fatalf("DiffChanges() error = %v", err)
+	}
+
+	if len(got) != len(changes) {
+		t.Fatalf("DiffChanges() = %d changes, want %d", len(got), len(changes))
+	}
+	for i, want := range changes {
+		g := got[i]
+		if g.Type != want.Type || g.Path != want.Path || g.From != want.From ||
+			g.Severity != want.Severity || g.Category != want.Category || g.Description != want.Description {
+			t.Errorf("change %d = %+v, want %+v", i, g, want)
+		}
+		if !equalNodes(g.OldValue, want.OldValue) || !equalNodes(g.NewValue, want.NewValue) {
+			t.Errorf("change %d at %s values = %v → %v, want %v → %v", i, want.Path, g.OldValue, g.NewValue, want.OldValue, want.NewValue)
+		}
+	}
+}
+
+// equalNodes reports whether two trees are equal, or both nil.
+func equalNodes(a, b *tree.Node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(b)
+}
+
+func TestFromChanges(t *testing.T) {
+	changes := []diff.Change{
+		{Type: diff.ChangeTypeAdd, Path: "/args[1]", NewValue: tree.NewString("--b")},
+		{Type: diff.ChangeTypeRemove, Path: "/env[name=DEBUG]", OldValue: tree.NewNull()},
+		{Type: diff.ChangeTypeModify, Path: "/replicas", OldValue: tree.NewInt(2), NewValue: tree.NewInt(3)},
+		{Type: diff.ChangeTypeMove, Path: "/tags", From: "/labels"},
+		{Type: diff.ChangeTypeNormalized, Path: "/cidr", OldValue: tree.NewString("10.0.0.1/8"), NewValue: tree.NewString("10.0.0.0/8")},
+	}
+
+	doc, err := FromChanges(changes)
+	if err != nil {
+		t.Fatalf("FromChanges() error = %v", err)
+	}
+
+	want := Summary{Total: 4, Added: 1, Removed: 1, Modified: 1, Moved: 1, Normalized: 1}
+	if doc.Summary != want {
+		t.Errorf("Summary = %+v, want %+v", doc.Summary, want)
+	}
+
+	data, err := json.Marshal(doc.Changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`{"type":"add","path":"/args[1]","new_value":"--b","index":1}`,
+		`{"type":"remove","path":"/env[name=DEBUG]","old_value":null}`,
+		`{"type":"move","path":"/tags","from":"/labels"}`,
+	} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("changes JSON missing %s, got %s", s, data)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"current version", `{"schema_version": 1, "summary": {}, "changes": [], "extra": true}`, ""},
+		{"missing version", `[{"Type": "add"}]`, "failed to decode change set"},
+		{"no version member", `{"changes": []}`, "no schema_version"},
+		{"newer version", `{"schema_version": 2, "changes": []}`, "schema version 2 is newer"},
+		{"invalid json", `{`, "failed to decode change set"},
+		{"directory document", `{"schema_version": 1, "files": [], "summary": {}}`, "no changes member"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.input))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Decode() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadChanges(t *testing.T) {
+	input := `{
+  "schema_version": 1,
+  "summary": {"total": 2, "added": 1, "removed": 0, "modified": 1, "moved": 0, "normalized": 0},
+  "changes": [
+    {"type": "add", "path": "/spec/ports[1]", "new_value": {"port": 443, "name": "https"}, "index": 1},
+    {"type": "modify", "path": "/spec/replicas", "old_value": 2, "new_value": 3}
+  ]
+}`
+
+	changes, err := ReadChanges(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadChanges() error = %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("ReadChanges() = %d changes, want 2", len(changes))
+	}
+
+	added := changes[0]
+	if added.ArrayIndex != 1 || added.OldValue != nil || added.NewValue.Kind != tree.KindObject {
+		t.Errorf("added change = %+v, want an object added at index 1", added)
+	}
+	if port := added.NewValue.Object["port"]; port.Value != int64(443) || port.Path != "/spec/ports[1]/port" {
+		t.Errorf("port = %v at %q, want 443 at /spec/ports[1]/port", port.Value, port.Path)
+	}
+	if modified := changes[1]; modified.OldValue.Value != int64(2) || modified.NewValue.Value != int64(3) {
+		t.Errorf("modified change = %v → %v, want 2 → 3", modified.OldValue.Value, modified.NewValue.Value)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	validator, err := schema.CompileValidator(JSONSchema)
+	if err != nil {
+		t.Fatalf("JSONSchema does not compile: %v", err)
+	}
+
+	doc, err := FromChanges([]diff.Change{
+		{Type: diff.ChangeTypeAdd, Path: "/args[1]", NewValue: tree.NewString("--b")},
+		{Type: diff.ChangeTypeModify, Path: "/replicas", OldValue: tree.NewInt(2), NewValue: tree.NewInt(3), Severity: diff.SeverityError},
+		{Type: diff.ChangeTypeMove, Path: "/tags", From: "/labels"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Old = &Input{Path: "old.yaml", Format: "yaml"}
+	doc.New = &Input{Path: "-", Format: "json"}
+
+	tests := []struct {
+		name      string
+		doc       interface{}
+		wantValid bool
+	}{
+		{"document", doc, true},
+		{"empty document", &Document{SchemaVersion: SchemaVersion, Changes: []Change{}}, true},
+		{"unknown version", map[string]interface{}{"schema_version": 2, "summary": doc.Summary, "changes": []Change{}}, false},
+		{"unknown change type", map[string]interface{}{
+			"schema_version": 1, "summary": doc.Summary, "changes": []map[string]string{{"type": "copy", "path": "/a"}},
+		}, false},
+		{"directory document", map[string]interface{}{
+			"schema_version": 1,
+			"files":          []map[string]interface{}{{"path": "app.yaml", "status": "modified", "changes": doc.Changes}},
+			"summary":        map[string]int{"compared": 1, "modified": 1, "added": 0, "removed": 0, "renamed": 0, "errors": 0},
+		}, true},
+		{"unknown file status", map[string]interface{}{
+			"schema_version": 1,
+			"files":          []map[string]string{{"path": "app.yaml", "status": "copied"}},
+			"summary":        map[string]int{"compared": 1, "modified": 1, "added": 0, "removed": 0, "renamed": 0, "errors": 0},
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			node, err := parse.ParseJSON(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			errs := validator.Validate(node)
+			if (len(errs) == 0) != tt.wantValid {
+				t.Errorf("Validate() = %v, want valid %v", errs, tt.wantValid)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package changeset
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/patch"
+)
+
+// DirDocument is a set of changes between two directories, file by file.
+type DirDocument struct {
+	// SchemaVersion is the version of the format, see SchemaVersion.
+	SchemaVersion int `json:"schema_version"`
+
+	// Files lists the compared files, sorted by path.
+	Files []File `json:"files"`
+
+	// Summary counts the files by status.
+	Summary DirSummary `json:"summary"`
+}
+
+// File is a single file of a directory comparison.
+type File struct {
+	// Path is the slash-separated path of the file, in the new directory
+	// for renamed files.
+	Path string `json:"path"`
+
+	// OldPath is the path of a renamed file in the old directory.
+	OldPath string `json:"old_path,omitempty"`
+
+	// Status is what happened to the file: unchanged, modified, added,
+	// removed, renamed or error.
+	Status string `json:"status"`
+
+	// Similarity is the share of unchanged values of a renamed file (0-1).
+	Similarity float64 `json:"similarity,omitempty"`
+
+	// Changes lists the changes of the file.
+	Changes []Change `json:"changes,omitempty"`
+
+	// Operations holds the patch operations of the file instead of its
+	// changes, in documents written with "-o patch".
+	Operations []patch.Operation `json:"operations,omitempty"`
+
+	// Error describes why the file could not be compared.
+	Error string `json:"error,omitempty"`
+}
+
+// DirSummary counts the files of a directory document by status. Compared
+// counts the files present on both sides under the same path.
+type DirSummary struct {
+	Compared int `json:"compared"`
+	Modified int `json:"modified"`
+	Added    int `json:"added"`
+	Removed  int `json:"removed"`
+	Renamed  int `json:"renamed"`
+	Errors   int `json:"errors"`
+}
+
+// DecodeDir reads a directory document from r. It fails if the document
+// has no schema version, one newer than SchemaVersion, or no files.
+func DecodeDir(r io.Reader) (*DirDocument, error) {
+	var doc DirDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode directory change set: %w", err)
+	}
+
+	switch {
+	case doc.SchemaVersion == 0:
+		return nil, errors.New("failed to decode directory change set: no schema_version, not a configdiff JSON document")
+	case doc.SchemaVersion > SchemaVersion:
+		return nil, fmt.Errorf("failed to decode directory change set: schema version %d is newer than the supported version %d", doc.SchemaVersion, SchemaVersion)
+	case doc.Files == nil:
+		return nil, errors.New("failed to decode directory change set: no files member")
+	}
+	return &doc, nil
+}
+
+// DecodeAny reads a document of either kind from r: the changes between
+// two files, returned as a *Document, or between two directories, returned
+// as a *DirDocument. Exactly one of them is non-nil on success.
+func DecodeAny(r io.Reader) (*Document, *DirDocument, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read change set: %w", err)
+	}
+
+	var probe struct {
+		Files json.RawMessage `json:"files"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode change set: %w", err)
+	}
+
+	if probe.Files != nil {
+		dir, err := DecodeDir(bytes.NewReader(data))
+		return nil, dir, err
+	}
+	doc, err := Decode(bytes.NewReader(data))
+	return doc, nil, err
+}
+
+// DiffChanges reconstructs the changes of every file, in file order. Use
+// File.DiffChanges to keep them apart by file.
+func (d *DirDocument) DiffChanges() ([]diff.Change, error) {
+	var changes []diff.Change
+	for i := range d.Files {
+		fileChanges, err := d.Files[i].DiffChanges()
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, fileChanges...)
+	}
+	if changes == nil {
+		changes = []diff.Change{}
+	}
+	return changes, nil
+}
+
+// DiffChanges reconstructs the changes of the file, with their values as
+// trees. It fails for files written with "-o patch", which carry patch
+// operations instead.
+func (f *File) DiffChanges() ([]diff.Change, error) {
+	if len(f.Operations) > 0 {
+		return nil, fmt.Errorf("%s: patch operations cannot be read as changes, use -o json output", f.Path)
+	}
+
+	changes, err := diffChanges(f.Changes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Path, err)
+	}
+	return changes, nil
+}
@@ -0,0 +1,109 @@
+package changeset
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+func TestDirRoundTrip(t *testing.T) {
+	appChanges := []diff.Change{
+		{Type: diff.ChangeTypeModify, Path: "/replicas", OldValue: tree.NewInt(2), NewValue: tree.NewInt(3), Severity: diff.SeverityWarning},
+		{Type: diff.ChangeTypeAdd, Path: "/labels/tier", NewValue: tree.NewString("web")},
+	}
+	dbChanges := []diff.Change{
+		{Type: diff.ChangeTypeMove, Path: "/database", From: "/db"},
+	}
+
+	app, err := FromChanges(appChanges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := FromChanges(dbChanges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := &DirDocument{
+		SchemaVersion: SchemaVersion,
+		Files: []File{
+			{Path: "app.yaml", Status: "modified", Changes: app.Changes},
+			{Path: "db.yaml", OldPath: "database.yaml", Status: "renamed", Similarity: 0.8, Changes: db.Changes},
+			{Path: "new.yaml", Status: "added"},
+			{Path: "broken.yaml", Status: "error", Error: "failed to parse"},
+		},
+		Summary: DirSummary{Compared: 1, Modified: 1, Added: 1, Renamed: 1, Errors: 1},
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, dir, err := DecodeAny(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("DecodeAny() error = %v", err)
+	}
+	if file != nil || dir == nil || len(dir.Files) != 4 {
+		t.Fatalf("DecodeAny() = %+v, %+v, want a directory document of 4 files", file, dir)
+	}
+	if f := dir.Files[1]; f.OldPath != "database.yaml" || f.Similarity != 0.8 || dir.Files[3].Error != "failed to parse" {
+		t.Errorf("files = %+v, want the renamed and failed files kept", dir.Files)
+	}
+	if dir.Summary != doc.Summary {
+		t.Errorf("Summary = %+v, want %+v", dir.Summary, doc.Summary)
+	}
+
+	changes, err := ReadChanges(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("ReadChanges() error = %v", err)
+	}
+	want := append(appChanges, dbChanges...)
+	if len(changes) != len(want) {
+		t.Fatalf("ReadChanges() = %d changes, want %d", len(changes), len(want))
+	}
+	for i, w := range want {
+		g := changes[i]
+		if g.Type != w.Type || g.Path != w.Path || g.From != w.From || g.Severity != w.Severity ||
+			!equalNodes(g.OldValue, w.OldValue) || !equalNodes(g.NewValue, w.NewValue) {
+			t.Errorf("change %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestDecodeDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"current version", `{"schema_version": 1, "files": [], "summary": {}, "extra": true}`, ""},
+		{"no version member", `{"files": []}`, "no schema_version"},
+		{"newer version", `{"schema_version": 2, "files": []}`, "schema version 2 is newer"},
+		{"file document", `{"schema_version": 1, "summary": {}, "changes": []}`, "no files member"},
+		{"invalid json", `{`, "failed to decode directory change set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeDir(strings.NewReader(tt.input))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("DecodeDir() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("DecodeDir() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadChanges_DirPatch(t *testing.T) {
+	input := `{"schema_version": 1, "files": [{"path": "a.yaml", "status": "modified", "operations": [{"op": "remove", "path": "/a"}]}], "summary": {}}`
+	if _, err := ReadChanges(strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), "patch operations cannot be read") {
+		t.Errorf("ReadChanges() error = %v, want patch operations rejected", err)
+	}
+}
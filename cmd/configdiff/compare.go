@@ -5,79 +5,129 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/pfrederiksen/configdiff"
+	"github.com/pfrederiksen/configdiff/diff"
 	"github.com/pfrederiksen/configdiff/internal/cli"
+	"github.com/pfrederiksen/configdiff/policy"
+	"github.com/pfrederiksen/configdiff/schema"
 )
 
-// compare performs the diff operation between two files or directories
+// compare performs the diff operation between two files, directories or archives
 func compare(oldFile, newFile string) error {
 	// Check if inputs are directories
 	oldInfo, oldErr := os.Stat(oldFile)
 	newInfo, newErr := os.Stat(newFile)
+	oldIsDir := oldErr == nil && oldInfo.IsDir()
+	newIsDir := newErr == nil && newInfo.IsDir()
+
+	// Archives are compared like directories, without extracting them
+	oldIsTree := oldIsDir || cli.IsArchive(oldFile)
+	newIsTree := newIsDir || cli.IsArchive(newFile)
 
 	// Handle directory comparison
-	if oldErr == nil && newErr == nil && oldInfo.IsDir() && newInfo.IsDir() {
-		if !recursive {
+	if oldIsTree && newIsTree {
+		if (oldIsDir || newIsDir) && !recursive {
 			return fmt.Errorf("comparing directories requires --recursive flag")
 		}
-		hasChanges, err := compareDirectories(oldFile, newFile)
+		if findRenames < 0 || findRenames > 100 {
+			return fmt.Errorf("invalid --find-renames value %d, must be between 0 and 100", findRenames)
+		}
+		hasChanges, severity, err := compareDirectories(oldFile, newFile)
 		if err != nil {
 			return err
 		}
 
-		// Handle exit code mode for directory comparison
-		if exitCode && hasChanges {
-			os.Exit(1)
+		// Handle exit code mode and policy violations for directory comparison
+		if code := exitStatus(hasChanges, severity); code != 0 {
+			os.Exit(code)
 		}
 
 		return nil
 	}
 
 	// One is a directory and one isn't
-	if oldErr == nil && oldInfo.IsDir() {
+	if oldIsDir {
 		return fmt.Errorf("cannot compare directory %q with file %q", oldFile, newFile)
 	}
-	if newErr == nil && newInfo.IsDir() {
+	if newIsDir {
 		return fmt.Errorf("cannot compare file %q with directory %q", oldFile, newFile)
 	}
+	if oldIsTree {
+		return fmt.Errorf("cannot compare archive %q with file %q", oldFile, newFile)
+	}
+	if newIsTree {
+		return fmt.Errorf("cannot compare file %q with archive %q", oldFile, newFile)
+	}
 
 	// Both are files (or stdin), proceed with normal comparison
-	hasChanges, err := compareFiles(oldFile, newFile)
+	hasChanges, severity, err := compareFiles(oldFile, newFile)
 	if err != nil {
 		return err
 	}
 
-	// Handle exit code mode for single file comparison
-	if exitCode && hasChanges {
-		os.Exit(1)
+	// Handle exit code mode and policy violations for single file comparison
+	if code := exitStatus(hasChanges, severity); code != 0 {
+		os.Exit(code)
 	}
 
 	return nil
 }
 
-// compareFiles performs the diff operation between two files.
-// Returns true if changes were found, false otherwise.
-func compareFiles(oldFile, newFile string) (bool, error) {
+// exitStatus returns the exit code for a comparison. Policy violations
+// exit with 3 for errors and 2 for warnings, taking precedence over
+// --exit-code, which exits with 1 when changes were found.
+func exitStatus(hasChanges bool, severity diff.Severity) int {
+	switch severity {
+	case diff.SeverityError:
+		return 3
+	case diff.SeverityWarning:
+		return 2
+	}
+	if exitCode && hasChanges {
+		return 1
+	}
+	return 0
+}
+
+// newCLIOptions builds validated CLI options for comparing two files from
+// the command-line flags and the loaded config file.
+func newCLIOptions(oldFile, newFile string) (cli.CLIOptions, error) {
 	// Build CLI options from flags
 	cliOpts := cli.CLIOptions{
-		OldFile:        oldFile,
-		NewFile:        newFile,
-		Format:         format,
-		OldFormat:      oldFormat,
-		NewFormat:      newFormat,
-		IgnorePaths:    ignorePaths,
-		ArrayKeys:      arrayKeys,
-		NumericStrings: numericStrings,
-		BoolStrings:    boolStrings,
-		StableOrder:    stableOrder,
-		OutputFormat:   outputFormat,
-		NoColor:        noColor,
-		MaxValueLength: maxValueLength,
-		Quiet:          quiet,
-		ExitCode:       exitCode,
+		OldFile:         oldFile,
+		NewFile:         newFile,
+		Format:          format,
+		OldFormat:       oldFormat,
+		NewFormat:       newFormat,
+		IgnorePaths:     ignorePaths,
+		ArrayKeys:       arrayKeys,
+		StrictArrayKeys: strictArrayKeys,
+		UnorderedArrays: unordered,
+		InferArrayKeys:  inferArrayKeys,
+		KeyRenames:      keyRenames,
+		Normalize:       normalize,
+		Tolerance:       tolerance,
+		NullAbsent:      nullAbsent,
+		EmptyAbsent:     emptyAbsent,
+		EmptyStringNull: emptyStringNull,
+		Defaults:        defaults,
+		Schema:          schemaFile,
+		Policy:          policyFile,
+		Classify:        classify,
+		MinSeverity:     minSeverity,
+		NumericStrings:  numericStrings,
+		BoolStrings:     boolStrings,
+		StableOrder:     stableOrder,
+		OutputFormat:    outputFormat,
+		NoColor:         noColor,
+		MaxValueLength:  maxValueLength,
+		Quiet:           quiet,
+		ExitCode:        exitCode,
+		Include:         includeGlobs,
+		Exclude:         excludeGlobs,
+		UseGitignore:    useGitignore,
 	}
 
 	// Apply config file defaults (CLI flags take precedence)
@@ -87,49 +137,134 @@ func compareFiles(oldFile, newFile string) (bool, error) {
 
 	// Validate options
 	if err := cliOpts.Validate(); err != nil {
-		return false, err
+		return cli.CLIOptions{}, err
+	}
+
+	return cliOpts, nil
+}
+
+// compareFiles performs the diff operation between two files.
+// Returns whether changes were found and the highest severity of policy
+// violations, if any.
+func compareFiles(oldFile, newFile string) (bool, diff.Severity, error) {
+	cliOpts, err := newCLIOptions(oldFile, newFile)
+	if err != nil {
+		return false, "", err
 	}
 
 	// Read old file
 	oldInput, err := cli.ReadInput(oldFile, cliOpts.GetOldFormat())
 	if err != nil {
-		return false, err
+		return false, "", err
 	}
 
 	// Read new file
 	newInput, err := cli.ReadInput(newFile, cliOpts.GetNewFormat())
 	if err != nil {
-		return false, err
+		return false, "", err
+	}
+
+	pol, err := loadPolicy(cliOpts)
+	if err != nil {
+		return false, "", err
+	}
+
+	classifier, err := loadClassifier(cliOpts)
+	if err != nil {
+		return false, "", err
 	}
 
 	// Convert CLI options to library options
 	diffOpts, err := cliOpts.ToLibraryOptions()
 	if err != nil {
-		return false, err
+		return false, "", err
+	}
+	if verbose {
+		diffOpts.OnInferredKey = cli.InferredKeyReporter(os.Stderr)
 	}
 
-	// Perform the diff
-	result, err := configdiff.DiffBytes(
-		oldInput.Data, oldInput.Format,
-		newInput.Data, newInput.Format,
-		diffOpts,
-	)
+	// With --quiet --exit-code only whether anything changed matters, so
+	// stop at the first change
+	if quiet && exitCode && pol == nil && classifier == nil && cliOpts.Schema == "" && os.Getenv("GITHUB_OUTPUT") == "" {
+		changed, err := cli.AnyChange(oldInput, newInput, diffOpts)
+		if err != nil {
+			return false, "", fmt.Errorf("diff failed: %w", err)
+		}
+		return changed, "", nil
+	}
+
+	// git-diff output is neither summarized nor sorted, so print it as the
+	// changes are found unless something needs them all first
+	if outputFormat == "git-diff" && !quiet && pol == nil && classifier == nil && cliOpts.Schema == "" && cliOpts.MinSeverity == "" && os.Getenv("GITHUB_OUTPUT") == "" {
+		changed, err := cli.WriteGitDiff(os.Stdout, oldInput, newInput, diffOpts, oldFile, newFile)
+		if err != nil {
+			return false, "", fmt.Errorf("diff failed: %w", err)
+		}
+		fmt.Println()
+		return changed, "", nil
+	}
+
+	// Parse once, for both the diff and schema validation
+	oldTree, newTree, err := cli.ParseInputs(oldInput, newInput, diffOpts)
+	if err != nil {
+		return false, "", fmt.Errorf("diff failed: %w", err)
+	}
+
+	// Perform the diff. The other output formats open with a summary, sort
+	// the changes or write one document, so all changes are collected first
+	result, err := configdiff.DiffTrees(oldTree, newTree, diffOpts)
 	if err != nil {
-		return false, fmt.Errorf("diff failed: %w", err)
+		return false, "", fmt.Errorf("diff failed: %w", err)
+	}
+
+	// Assign categories and severities to the changes
+	if classifier != nil {
+		if err := cli.ClassifyChanges(classifier, result); err != nil {
+			return false, "", err
+		}
+	}
+
+	// Validate both files and flag changes that break the schema
+	var validation *schema.Validation
+	if cliOpts.Schema != "" {
+		validation, err = cli.ValidateInputs(cliOpts.Schema, oldTree, newTree, result)
+		if err != nil {
+			return false, "", err
+		}
+	}
+
+	// Evaluate the policy and flag violating changes
+	var violations []policy.Violation
+	if pol != nil {
+		violations, err = cli.ApplyPolicy(pol, result)
+		if err != nil {
+			return false, "", err
+		}
 	}
 
 	// Format and output results (unless quiet mode)
 	var output string
 	if !quiet {
-		output, err = cli.FormatOutput(result, cli.OutputOptions{
+		shown, err := cli.FilterBySeverity(result, cliOpts.GetMinSeverity())
+		if err != nil {
+			return false, "", err
+		}
+
+		output, err = cli.FormatOutput(shown, cli.OutputOptions{
 			Format:         outputFormat,
 			NoColor:        noColor,
 			MaxValueLength: maxValueLength,
 			OldFile:        oldFile,
 			NewFile:        newFile,
+			OldFormat:      oldInput.Format,
+			NewFormat:      newInput.Format,
+			Validation:     validation,
+			SchemaFile:     cliOpts.Schema,
+			Violations:     violations,
+			PolicyFile:     cliOpts.Policy,
 		})
 		if err != nil {
-			return false, err
+			return false, "", err
 		}
 
 		fmt.Println(output)
@@ -144,128 +279,135 @@ func compareFiles(oldFile, newFile string) (bool, error) {
 		}
 	}
 
-	// Return whether changes were found
-	return hasChanges, nil
+	// Return whether changes were found and how severe violations are
+	return hasChanges, policy.MaxSeverity(violations), nil
 }
 
-// compareDirectories recursively compares two directories.
-// Returns true if any changes were found, false otherwise.
-func compareDirectories(oldDir, newDir string) (bool, error) {
-	// Collect all config files from both directories
-	oldFiles, err := collectConfigFiles(oldDir)
+// compareDirectories recursively compares two directories or archives.
+// Returns whether any changes were found and the highest severity of policy
+// violations, if any.
+func compareDirectories(oldDir, newDir string) (bool, diff.Severity, error) {
+	cliOpts, err := newCLIOptions(oldDir, newDir)
 	if err != nil {
-		return false, fmt.Errorf("failed to scan old directory: %w", err)
+		return false, "", err
+	}
+
+	if cliOpts.Schema != "" {
+		return false, "", fmt.Errorf("--schema is not supported for directory comparisons")
 	}
 
-	newFiles, err := collectConfigFiles(newDir)
+	pol, err := loadPolicy(cliOpts)
 	if err != nil {
-		return false, fmt.Errorf("failed to scan new directory: %w", err)
-	}
-
-	// Build set of all relative paths
-	allPaths := make(map[string]bool)
-	for _, path := range oldFiles {
-		rel, _ := filepath.Rel(oldDir, path)
-		allPaths[rel] = true
-	}
-	for _, path := range newFiles {
-		rel, _ := filepath.Rel(newDir, path)
-		allPaths[rel] = true
-	}
-
-	// Track if any differences found
-	hasAnyChanges := false
-	filesCompared := 0
-	filesAdded := 0
-	filesRemoved := 0
-
-	// Compare each file
-	for relPath := range allPaths {
-		oldPath := filepath.Join(oldDir, relPath)
-		newPath := filepath.Join(newDir, relPath)
-
-		oldExists := fileExists(oldPath)
-		newExists := fileExists(newPath)
-
-		if oldExists && newExists {
-			// File exists in both directories - compare them
-			if !quiet {
-				fmt.Printf("\n=== %s ===\n", relPath)
-			}
-
-			fileHasChanges, err := compareFiles(oldPath, newPath)
-			if err != nil {
-				if !quiet {
-					fmt.Printf("Error: %v\n", err)
-				}
-				continue
-			}
-			filesCompared++
-			if fileHasChanges {
-				hasAnyChanges = true
-			}
-		} else if newExists && !oldExists {
-			// File added
-			filesAdded++
-			if !quiet {
-				fmt.Printf("\n+++ %s (added)\n", relPath)
-			}
-			hasAnyChanges = true
-		} else if oldExists && !newExists {
-			// File removed
-			filesRemoved++
-			if !quiet {
-				fmt.Printf("\n--- %s (removed)\n", relPath)
-			}
-			hasAnyChanges = true
-		}
+		return false, "", err
 	}
 
-	// Print summary
-	if !quiet {
-		fmt.Printf("\n")
-		fmt.Printf("Summary: %d files compared, %d added, %d removed\n",
-			filesCompared, filesAdded, filesRemoved)
+	classifier, err := loadClassifier(cliOpts)
+	if err != nil {
+		return false, "", err
 	}
 
-	// Return whether any changes were found
-	return hasAnyChanges, nil
-}
+	diffOpts, err := cliOpts.ToLibraryOptions()
+	if err != nil {
+		return false, "", err
+	}
+	if verbose {
+		diffOpts.OnInferredKey = cli.InferredKeyReporter(os.Stderr)
+	}
 
-// collectConfigFiles recursively finds all config files in a directory
-func collectConfigFiles(dir string) ([]string, error) {
-	var files []string
+	oldFS, oldCloser, err := cli.OpenTree(oldDir)
+	if err != nil {
+		return false, "", err
+	}
+	defer oldCloser.Close()
+
+	newFS, newCloser, err := cli.OpenTree(newDir)
+	if err != nil {
+		return false, "", err
+	}
+	defer newCloser.Close()
+
+	result, err := configdiff.DiffFS(oldFS, newFS, configdiff.DirOptions{
+		Options:         diffOpts,
+		OldFormat:       cliOpts.GetOldFormat(),
+		NewFormat:       cliOpts.GetNewFormat(),
+		Include:         cliOpts.Include,
+		Exclude:         cliOpts.Exclude,
+		UseGitignore:    cliOpts.UseGitignore,
+		RenameThreshold: findRenames,
+	})
+	if err != nil {
+		return false, "", err
+	}
+
+	// Assign categories and severities to the changes of every file
+	if classifier != nil {
+		if err := cli.ClassifyDirChanges(classifier, result); err != nil {
+			return false, "", err
+		}
+	}
 
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+	// Evaluate the policy against every file
+	var violations []policy.Violation
+	if pol != nil {
+		violations, err = cli.ApplyDirPolicy(pol, result)
 		if err != nil {
-			return err
+			return false, "", err
 		}
+	}
 
-		// Skip directories
-		if info.IsDir() {
-			return nil
+	// Format and output results (unless quiet mode)
+	var output string
+	if !quiet {
+		shown, err := cli.FilterDirBySeverity(result, cliOpts.GetMinSeverity())
+		if err != nil {
+			return false, "", err
 		}
 
-		// Check if it's a config file by extension
-		ext := strings.ToLower(filepath.Ext(path))
-		switch ext {
-		case ".yaml", ".yml", ".json", ".hcl", ".tf", ".toml":
-			files = append(files, path)
+		output, err = cli.FormatDirOutput(shown, cli.OutputOptions{
+			Format:         cliOpts.OutputFormat,
+			NoColor:        cliOpts.NoColor,
+			MaxValueLength: cliOpts.MaxValueLength,
+			Violations:     violations,
+			PolicyFile:     cliOpts.Policy,
+		})
+		if err != nil {
+			return false, "", err
 		}
 
-		return nil
-	})
+		fmt.Print(output)
+		if !strings.HasSuffix(output, "\n") {
+			fmt.Println()
+		}
+	}
 
-	return files, err
+	// Write GitHub Actions outputs if in GHA environment
+	hasChanges := result.HasChanges()
+	if githubOutput := os.Getenv("GITHUB_OUTPUT"); githubOutput != "" {
+		if err := writeGitHubOutputs(githubOutput, hasChanges, output); err != nil {
+			// Log error but don't fail the command
+			fmt.Fprintf(os.Stderr, "Warning: Failed to write GitHub Actions outputs: %v\n", err)
+		}
+	}
+
+	// Return whether any changes were found and how severe violations are
+	return hasChanges, policy.MaxSeverity(violations), nil
 }
 
-// fileExists checks if a file exists
-func fileExists(path string) bool {
-	info, err := os.Stat(path)
-	if err != nil {
-		return false
+// loadPolicy loads the policy file given with --policy, or returns nil
+func loadPolicy(cliOpts cli.CLIOptions) (*policy.Policy, error) {
+	if cliOpts.Policy == "" {
+		return nil, nil
+	}
+	return policy.Load(cliOpts.Policy)
+}
+
+// loadClassifier loads the rule files and packs given with --classify, or
+// returns nil
+func loadClassifier(cliOpts cli.CLIOptions) (*policy.Classifier, error) {
+	if len(cliOpts.Classify) == 0 {
+		return nil, nil
 	}
-	return !info.IsDir()
+	return policy.LoadClassifiers(cliOpts.Classify)
 }
 
 // writeGitHubOutputs writes GitHub Actions outputs to the GITHUB_OUTPUT file
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/pfrederiksen/configdiff/diff"
 )
 
 func TestCLI(t *testing.T) {
@@ -86,107 +90,6 @@ func TestVersionInfo(t *testing.T) {
 	}
 }
 
-func TestCollectConfigFiles(t *testing.T) {
-	tmpDir := t.TempDir()
-
-	// Create test files
-	testFiles := []string{
-		"config.yaml",
-		"config.yml",
-		"data.json",
-		"terraform.tf",
-		"vars.hcl",
-		"Cargo.toml",
-		"subdir/nested.yaml",
-		"README.md",     // Should not be collected
-		"script.sh",     // Should not be collected
-	}
-
-	for _, f := range testFiles {
-		path := filepath.Join(tmpDir, f)
-		dir := filepath.Dir(path)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			t.Fatalf("Failed to create directory: %v", err)
-		}
-		if err := os.WriteFile(path, []byte("test"), 0644); err != nil {
-			t.Fatalf("Failed to create test file %s: %v", f, err)
-		}
-	}
-
-	files, err := collectConfigFiles(tmpDir)
-	if err != nil {
-		t.Fatalf("collectConfigFiles() error = %v", err)
-	}
-
-	// Should collect exactly 6 config files (not README.md or script.sh)
-	if len(files) != 7 {
-		t.Errorf("collectConfigFiles() found %d files, want 7", len(files))
-	}
-
-	// Check that config files are present
-	wantExtensions := map[string]bool{
-		".yaml": false,
-		".yml":  false,
-		".json": false,
-		".tf":   false,
-		".hcl":  false,
-		".toml": false,
-	}
-
-	for _, f := range files {
-		ext := filepath.Ext(f)
-		if _, ok := wantExtensions[ext]; ok {
-			wantExtensions[ext] = true
-		}
-	}
-
-	for ext, found := range wantExtensions {
-		if !found {
-			t.Errorf("No %s file found in collected files", ext)
-		}
-	}
-}
-
-func TestFileExists(t *testing.T) {
-	tmpDir := t.TempDir()
-
-	existingFile := filepath.Join(tmpDir, "exists.txt")
-	if err := os.WriteFile(existingFile, []byte("test"), 0644); err != nil {
-		t.Fatalf("Failed to create test file: %v", err)
-	}
-
-	tests := []struct {
-		name string
-		path string
-		want bool
-	}{
-		{
-			name: "existing file",
-			path: existingFile,
-			want: true,
-		},
-		{
-			name: "non-existent file",
-			path: filepath.Join(tmpDir, "nonexistent.txt"),
-			want: false,
-		},
-		{
-			name: "directory",
-			path: tmpDir,
-			want: false, // directories should return false
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := fileExists(tt.path)
-			if got != tt.want {
-				t.Errorf("fileExists(%q) = %v, want %v", tt.path, got, tt.want)
-			}
-		})
-	}
-}
-
 func TestCompareDirectories(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -227,7 +130,7 @@ func TestCompareDirectories(t *testing.T) {
 	quiet = true // Suppress output during test
 	exitCode = false
 
-	_, err := compareDirectories(oldDir, newDir)
+	_, _, err := compareDirectories(oldDir, newDir)
 	if err != nil {
 		t.Errorf("compareDirectories() error = %v", err)
 	}
@@ -376,7 +279,7 @@ func TestCompareFilesReturnValue(t *testing.T) {
 			quiet = true
 			exitCode = false
 
-			hasChanges, err := compareFiles(tt.oldFile, tt.newFile)
+			hasChanges, _, err := compareFiles(tt.oldFile, tt.newFile)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("compareFiles() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -419,7 +322,7 @@ func TestDirectoryComparisonDoesNotExitEarly(t *testing.T) {
 	quiet = true
 	exitCode = true // This used to cause early exit, now it should work correctly
 
-	hasChanges, err := compareDirectories(oldDir, newDir)
+	hasChanges, _, err := compareDirectories(oldDir, newDir)
 	if err != nil {
 		t.Errorf("compareDirectories() error = %v", err)
 	}
@@ -573,3 +476,222 @@ func TestWriteGitHubOutputsAppend(t *testing.T) {
 		t.Error("New content was not appended")
 	}
 }
+
+func TestCompareDirectoriesWithRenames(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	oldDir := filepath.Join(tmpDir, "old")
+	newDir := filepath.Join(tmpDir, "new")
+
+	if err := os.MkdirAll(oldDir, 0755); err != nil {
+		t.Fatalf("Failed to create old dir: %v", err)
+	}
+	if err := os.MkdirAll(newDir, 0755); err != nil {
+		t.Fatalf("Failed to create new dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(oldDir, "old-name.yaml"), []byte("a: 1\nb: 2"), 0644); err != nil {
+		t.Fatalf("Failed to write old file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(newDir, "new-name.yaml"), []byte("a: 1\nb: 2"), 0644); err != nil {
+		t.Fatalf("Failed to write new file: %v", err)
+	}
+
+	quiet = true
+	exitCode = false
+	findRenames = 50
+	defer func() { findRenames = 0 }()
+
+	hasChanges, _, err := compareDirectories(oldDir, newDir)
+	if err != nil {
+		t.Fatalf("compareDirectories() error = %v", err)
+	}
+	if !hasChanges {
+		t.Error("compareDirectories() should report a renamed file as a change")
+	}
+}
+
+func TestCompareArchives(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	writeZip := func(path string, files map[string]string) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for name, content := range files {
+			w, err := zw.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write([]byte(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldZip := filepath.Join(tmpDir, "old.zip")
+	newZip := filepath.Join(tmpDir, "new.zip")
+	file := filepath.Join(tmpDir, "file.yaml")
+	writeZip(oldZip, map[string]string{"app/values.yaml": "replicas: 2"})
+	writeZip(newZip, map[string]string{"app/values.yaml": "replicas: 3"})
+	if err := os.WriteFile(file, []byte("replicas: 2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	quiet = true
+	exitCode = false
+	recursive = false
+
+	hasChanges, _, err := compareDirectories(oldZip, newZip)
+	if err != nil {
+		t.Fatalf("compareDirectories() error = %v", err)
+	}
+	if !hasChanges {
+		t.Error("compareDirectories() should detect changes between archives")
+	}
+
+	if err := compare(oldZip, newZip); err != nil {
+		t.Errorf("compare() of two archives should not require --recursive, got %v", err)
+	}
+
+	err = compare(oldZip, file)
+	if err == nil || !contains(err.Error(), "cannot compare archive") {
+		t.Errorf("compare() archive vs file error = %v, want cannot compare archive", err)
+	}
+}
+
+func TestCompareWithPolicy(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	oldFile := filepath.Join(tmpDir, "old.yaml")
+	newFile := filepath.Join(tmpDir, "new.yaml")
+	policyPath := filepath.Join(tmpDir, "policy.yaml")
+	if err := os.WriteFile(oldFile, []byte("replicas: 3\nimage: web:1"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(newFile, []byte("replicas: 2\nimage: web:latest"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		policy       string
+		wantSeverity diff.Severity
+		wantExit     int
+	}{
+		{"error", "rules: [{path: /replicas, when: new < old}]", diff.SeverityError, 3},
+		{"warning", "rules: [{path: /image, when: 'new matches \":latest$\"', severity: warn}]", diff.SeverityWarning, 2},
+		{"info", "rules: [{path: /image, severity: info}]", diff.SeverityInfo, 0},
+		{"no violations", "rules: [{path: /replicas, when: new > old}]", "", 0},
+	}
+
+	quiet = true
+	exitCode = false
+	defer func() { policyFile = "" }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(policyPath, []byte(tt.policy), 0644); err != nil {
+				t.Fatalf("Failed to write policy: %v", err)
+			}
+			policyFile = policyPath
+
+			hasChanges, severity, err := compareFiles(oldFile, newFile)
+			if err != nil {
+				t.Fatalf("compareFiles() error = %v", err)
+			}
+			if !hasChanges {
+				t.Error("compareFiles() hasChanges = false, want true")
+			}
+			if severity != tt.wantSeverity {
+				t.Errorf("compareFiles() severity = %q, want %q", severity, tt.wantSeverity)
+			}
+			if got := exitStatus(hasChanges, severity); got != tt.wantExit {
+				t.Errorf("exitStatus() = %d, want %d", got, tt.wantExit)
+			}
+		})
+	}
+}
+
+func TestCompareWithPredicateSelectors(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	oldFile := filepath.Join(tmpDir, "old.yaml")
+	newFile := filepath.Join(tmpDir, "new.yaml")
+	if err := os.WriteFile(oldFile, []byte("items:\n  - {name: a, port: 80, image: web:1}\n  - {name: a, port: 90, image: web:1}"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(newFile, []byte("items:\n  - {name: a, port: 80, image: web:2}\n  - {name: a, port: 90, image: web:1}"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	quiet = true
+	exitCode = false
+	defer func() {
+		ignorePaths = nil
+		rootCmd.Flags().Lookup("ignore").Changed = false
+	}()
+
+	// The flag parser splits values on commas, including inside predicates
+	if err := rootCmd.ParseFlags([]string{"-i", "/items[name=a,port=80]"}); err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+	hasChanges, _, err := compareFiles(oldFile, newFile)
+	if err != nil {
+		t.Fatalf("compareFiles() error = %v", err)
+	}
+	if hasChanges {
+		t.Error("compareFiles() hasChanges = true, want the change ignored")
+	}
+}
+
+func TestRender(t *testing.T) {
+	tmpDir := t.TempDir()
+	changes := filepath.Join(tmpDir, "changes.json")
+	content := `{
+  "schema_version": 1,
+  "old": {"path": "old.yaml", "format": "yaml"},
+  "new": {"path": "new.yaml", "format": "yaml"},
+  "summary": {"total": 1, "added": 0, "removed": 0, "modified": 1, "moved": 0, "normalized": 0},
+  "changes": [{"type": "modify", "path": "/replicas", "old_value": 2, "new_value": 3}]
+}`
+	if err := os.WriteFile(changes, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write change set: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		from    string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{"report", changes, "report", "~ /replicas: 2 → 3", false},
+		{"git-diff keeps file names", changes, "git-diff", "--- a/old.yaml", false},
+		{"patch", changes, "patch", `"op": "replace"`, false},
+		{"missing file", filepath.Join(tmpDir, "missing.json"), "report", "", true},
+		{"unknown format", changes, "xml", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renderFrom = tt.from
+			outputFormat = tt.format
+			noColor = true
+			defer func() { renderFrom, outputFormat, noColor = "", "report", false }()
+
+			var buf bytes.Buffer
+			err := render(&buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Contains(buf.Bytes(), []byte(tt.want)) {
+				t.Errorf("render() output missing %q, got:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+}
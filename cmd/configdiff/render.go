@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+// renderFrom is the change set read by the render command
+var renderFrom string
+
+var renderCmd = &cobra.Command{
+	Use:   "render --from <changes.json>",
+	Short: "Render saved JSON output in another output format",
+	Long: `Render a change set saved with "-o json", from two files or two
+directories, in any output format without the original files.
+
+Validation and policy results saved in the change set are not rendered.`,
+	Example: `  # Save the changes, e.g. as a CI artifact
+  configdiff old.yaml new.yaml -o json > changes.json
+
+  # Render them later
+  configdiff render --from changes.json
+  configdiff render --from changes.json -o patch
+  cat changes.json | configdiff render --from - -o side-by-side`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return render(cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	renderCmd.Flags().StringVar(&renderFrom, "from", "", "JSON change set written by -o json (- for stdin)")
+	_ = renderCmd.MarkFlagRequired("from")
+	renderCmd.Flags().StringVarP(&outputFormat, "output", "o", "report", "Output format (report, compact, json, patch, stat, side-by-side, git-diff)")
+	renderCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+	renderCmd.Flags().IntVar(&maxValueLength, "max-value-length", 80, "Truncate values longer than N chars (0 = no limit)")
+
+	rootCmd.AddCommand(renderCmd)
+}
+
+// render writes the change set named by --from to w in the output format
+func render(w io.Writer) error {
+	var data []byte
+	var err error
+	if renderFrom == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(renderFrom)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read change set: %w", err)
+	}
+
+	cs, err := cli.ReadChangeSet(data)
+	if err != nil {
+		return err
+	}
+
+	opts := cli.OutputOptions{
+		Format:         outputFormat,
+		NoColor:        noColor,
+		MaxValueLength: maxValueLength,
+	}
+
+	// Print like the comparison that wrote the change set
+	if cs.Dir != nil {
+		output, err := cli.FormatDirOutput(cs.Dir, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(w, output)
+		if !strings.HasSuffix(output, "\n") {
+			fmt.Fprintln(w)
+		}
+		return nil
+	}
+
+	opts.OldFile, opts.OldFormat = cs.Old.Path, cs.Old.Format
+	opts.NewFile, opts.NewFormat = cs.New.Path, cs.New.Format
+	output, err := cli.FormatOutput(cs.Result, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, output)
+	return nil
+}
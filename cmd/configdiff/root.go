@@ -9,20 +9,39 @@ import (
 
 var (
 	// Global flags
-	format         string
-	oldFormat      string
-	newFormat      string
-	ignorePaths    []string
-	arrayKeys      []string
-	numericStrings bool
-	boolStrings    bool
-	stableOrder    bool
-	outputFormat   string
-	noColor        bool
-	maxValueLength int
-	quiet          bool
-	exitCode       bool
-	recursive      bool
+	format          string
+	oldFormat       string
+	newFormat       string
+	ignorePaths     []string
+	arrayKeys       []string
+	strictArrayKeys bool
+	unordered       []string
+	inferArrayKeys  bool
+	keyRenames      int
+	normalize       []string
+	tolerance       []string
+	nullAbsent      bool
+	emptyAbsent     bool
+	emptyStringNull bool
+	defaults        []string
+	schemaFile      string
+	policyFile      string
+	classify        []string
+	minSeverity     string
+	numericStrings  bool
+	boolStrings     bool
+	stableOrder     bool
+	outputFormat    string
+	noColor         bool
+	maxValueLength  int
+	quiet           bool
+	exitCode        bool
+	verbose         bool
+	recursive       bool
+	findRenames     int
+	includeGlobs    []string
+	excludeGlobs    []string
+	useGitignore    bool
 
 	// Config file loaded at startup
 	cfg *config.Config
@@ -38,6 +57,9 @@ rules for semantic comparison, ignore specific paths, treat arrays as sets,
 handle type coercions, and generate both machine-readable patches and
 human-friendly reports.
 
+Directories (with --recursive) and archives (.tar, .tar.gz, .tgz, .zip) are
+compared file by file.
+
 Use "-" for stdin input (only one file can be stdin).`,
 	Example: `  # Basic comparison
   configdiff old.yaml new.yaml
@@ -51,11 +73,31 @@ Use "-" for stdin input (only one file can be stdin).`,
   # Array-as-set comparison
   configdiff old.yaml new.yaml --array-key /spec/containers=name
 
+  # Treat equivalent CIDRs and URLs as equal
+  configdiff old.yaml new.yaml --normalize /spec/podCIDR=cidr --normalize /endpoint=url
+
+  # Ignore floating point noise
+  configdiff old.json new.json --tolerance 1e-9 --tolerance /metrics/*=1%
+
+  # Compare a live object with its manifest, ignoring server-side defaults
+  kubectl get deploy web -o yaml | configdiff deploy.yaml - --defaults kubernetes -i /status -i /metadata/*
+
   # Different output formats
   configdiff old.yaml new.yaml -o compact
   configdiff old.yaml new.yaml -o json
   configdiff old.yaml new.yaml -o patch
 
+  # Directory comparison with rename detection
+  configdiff -r -M old-dir/ new-dir/
+  configdiff -r --find-renames=80 old-dir/ new-dir/
+
+  # Compare two packaged Helm charts or zipped bundles
+  configdiff mychart-1.0.0.tgz mychart-1.1.0.tgz
+  configdiff -r bundle.zip ./bundle/
+
+  # Limit directory comparison to some files
+  configdiff -r old-dir/ new-dir/ --include 'apps/**' --exclude '**/charts/**' --gitignore
+
   # Exit code mode for CI
   if configdiff old.yaml new.yaml --exit-code; then
     echo "No changes detected"
@@ -78,7 +120,22 @@ func init() {
 
 	// Diff option flags
 	rootCmd.Flags().StringSliceVarP(&ignorePaths, "ignore", "i", nil, "Paths to ignore (can be repeated)")
-	rootCmd.Flags().StringSliceVar(&arrayKeys, "array-key", nil, "Array paths to key fields (format: path=key)")
+	rootCmd.Flags().StringSliceVar(&arrayKeys, "array-key", nil, "Array path selectors to key fields (format: path=key or path=key1,key2)")
+	rootCmd.Flags().BoolVar(&strictArrayKeys, "strict-array-keys", false, "Fail when an element of a keyed array lacks its key or repeats one")
+	rootCmd.Flags().StringSliceVar(&unordered, "unordered", nil, "Array path selectors compared ignoring element order (can be repeated)")
+	rootCmd.Flags().BoolVar(&inferArrayKeys, "infer-array-keys", false, "Compare arrays of objects as sets keyed by an inferred field (name, id, key, containerPort or any unique field)")
+	rootCmd.Flags().IntVar(&keyRenames, "find-key-renames", 0, "Report removed and added keys whose values are at least N% alike as renames (90 if no value given)")
+	rootCmd.Flags().Lookup("find-key-renames").NoOptDefVal = "90"
+	rootCmd.Flags().StringSliceVar(&normalize, "normalize", nil, "Canonicalize values before comparing (format: path=cidr|ip|url)")
+	rootCmd.Flags().StringSliceVar(&tolerance, "tolerance", nil, "Allowed numeric difference, absolute or relative (format: [path=]0.001 or [path=]1%)")
+	rootCmd.Flags().BoolVar(&nullAbsent, "null-equals-absent", false, "Treat null values and missing keys as equal")
+	rootCmd.Flags().BoolVar(&emptyAbsent, "empty-equals-absent", false, "Treat empty arrays/objects and missing keys as equal")
+	rootCmd.Flags().BoolVar(&emptyStringNull, "empty-string-equals-null", false, "Treat empty strings and null as equal")
+	rootCmd.Flags().StringSliceVar(&defaults, "defaults", nil, "JSON Schema/OpenAPI file, or 'kubernetes', whose default values are not reported when omitted")
+	rootCmd.Flags().StringVar(&schemaFile, "schema", "", "Validate both files against a JSON Schema and flag changes that break it")
+	rootCmd.Flags().StringVar(&policyFile, "policy", "", "Evaluate a policy file against the changes (exit 2 on warnings, 3 on errors)")
+	rootCmd.Flags().StringSliceVar(&classify, "classify", nil, "Classification rule file, or 'kubernetes'/'terraform' rule pack, assigning categories and severities (can be repeated)")
+	rootCmd.Flags().StringVar(&minSeverity, "min-severity", "", "Only show changes of at least this severity (info, warning, error)")
 	rootCmd.Flags().BoolVar(&numericStrings, "numeric-strings", false, "Coerce numeric strings to numbers")
 	rootCmd.Flags().BoolVar(&boolStrings, "bool-strings", false, "Coerce bool strings to booleans")
 	rootCmd.Flags().BoolVar(&stableOrder, "stable-order", true, "Sort output deterministically")
@@ -89,7 +146,13 @@ func init() {
 	rootCmd.Flags().IntVar(&maxValueLength, "max-value-length", 80, "Truncate values longer than N chars (0 = no limit)")
 	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Quiet mode (no output)")
 	rootCmd.Flags().BoolVar(&exitCode, "exit-code", false, "Exit with code 1 if differences found")
+	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Report inferred array keys on stderr")
 	rootCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Recursively compare directories")
+	rootCmd.Flags().IntVarP(&findRenames, "find-renames", "M", 0, "Detect renamed files in directories that are at least N% similar (50 if no value given)")
+	rootCmd.Flags().Lookup("find-renames").NoOptDefVal = "50"
+	rootCmd.Flags().StringSliceVar(&includeGlobs, "include", nil, "Only compare directory files matching these globs (e.g. 'apps/**/*.yaml')")
+	rootCmd.Flags().StringSliceVar(&excludeGlobs, "exclude", nil, "Skip directory files and folders matching these globs (e.g. '**/node_modules')")
+	rootCmd.Flags().BoolVar(&useGitignore, "gitignore", false, "Honor .gitignore files in directory comparisons")
 
 	// Add version command
 	rootCmd.AddCommand(versionCmd)
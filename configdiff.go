@@ -5,12 +5,11 @@
 package configdiff
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/pfrederiksen/configdiff/diff"
-	"github.com/pfrederiksen/configdiff/parse"
 	"github.com/pfrederiksen/configdiff/patch"
-	"github.com/pfrederiksen/configdiff/report"
 	"github.com/pfrederiksen/configdiff/tree"
 )
 
@@ -28,6 +27,49 @@ type (
 	// ChangeType categorizes the kind of change.
 	ChangeType = diff.ChangeType
 
+	// Comparator implements a custom equality rule for matching paths.
+	Comparator = diff.Comparator
+
+	// ComparatorFunc adapts a function to the Comparator interface.
+	ComparatorFunc = diff.ComparatorFunc
+
+	// ComparatorRule registers a Comparator for a path pattern.
+	ComparatorRule = diff.ComparatorRule
+
+	// Comparison is the verdict of a Comparator.
+	Comparison = diff.Comparison
+
+	// Normalizer canonicalizes string values before comparison.
+	Normalizer = diff.Normalizer
+
+	// NormalizerRule applies a Normalizer to a path pattern.
+	NormalizerRule = diff.NormalizerRule
+
+	// Tolerance allows numbers to differ by a small amount.
+	Tolerance = diff.Tolerance
+
+	// ToleranceRule applies a Tolerance to a path pattern.
+	ToleranceRule = diff.ToleranceRule
+
+	// Equivalence treats null, empty and missing values as equal.
+	Equivalence = diff.Equivalence
+
+	// EquivalenceRule applies an Equivalence to a path pattern.
+	EquivalenceRule = diff.EquivalenceRule
+
+	// Defaults supplies default values for keys that may be omitted.
+	Defaults = diff.Defaults
+
+	// ArrayKeyError reports an array element that lacks its key or repeats
+	// one when Options.StrictArrayKeys is set.
+	ArrayKeyError = diff.ArrayKeyError
+
+	// WalkFunc receives the changes streamed by Walk.
+	WalkFunc = diff.WalkFunc
+
+	// Severity ranks how much attention a change needs.
+	Severity = diff.Severity
+
 	// Patch represents a machine-readable set of operations.
 	Patch = patch.Patch
 
@@ -46,10 +88,39 @@ const (
 	// ChangeTypeModify indicates a value was changed.
 	ChangeTypeModify = diff.ChangeTypeModify
 
-	// ChangeTypeMove indicates a value was moved (array reordering).
+	// ChangeTypeMove indicates a value was moved, e.g. a renamed key.
 	ChangeTypeMove = diff.ChangeTypeMove
+
+	// ChangeTypeNormalized indicates values equal only after normalization.
+	ChangeTypeNormalized = diff.ChangeTypeNormalized
+)
+
+// Re-export severity constants.
+const (
+	// SeverityInfo marks a change worth knowing about.
+	SeverityInfo = diff.SeverityInfo
+
+	// SeverityWarning marks a change that should be reviewed.
+	SeverityWarning = diff.SeverityWarning
+
+	// SeverityError marks a change that breaks the configuration.
+	SeverityError = diff.SeverityError
 )
 
+// SkipAll is returned by a WalkFunc to stop Walk without error.
+var SkipAll = diff.SkipAll
+
+// Walk compares two normalized trees and passes each change to fn as it is
+// found. See diff.Walk.
+func Walk(a, b *tree.Node, opts Options, fn WalkFunc) error {
+	return diff.Walk(a, b, opts, fn)
+}
+
+// WalkContext is like Walk but stops with ctx.Err() when ctx is done.
+func WalkContext(ctx context.Context, a, b *tree.Node, opts Options, fn WalkFunc) error {
+	return diff.WalkContext(ctx, a, b, opts, fn)
+}
+
 // Result contains the output of a diff operation.
 type Result struct {
 	// Changes is the list of detected changes.
@@ -62,52 +133,43 @@ type Result struct {
 	Report string
 }
 
+// HasChanges reports whether the result contains any change. Values that
+// are equal only after normalization do not count.
+func (r *Result) HasChanges() bool {
+	return diff.HasChanges(r.Changes)
+}
+
 // DiffBytes compares two configuration byte slices and returns the diff result.
 //
 // Supported formats: "yaml", "json", "hcl"
 func DiffBytes(a []byte, aFormat string, b []byte, bFormat string, opts Options) (*Result, error) {
-	// Parse format a
-	aTree, err := parse.Parse(a, parse.Format(aFormat))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse format %s: %w", aFormat, err)
-	}
+	return DiffBytesContext(context.Background(), a, aFormat, b, bFormat, opts)
+}
 
-	// Parse format b
-	var bTree *tree.Node
-	bTree, err = parse.Parse(b, parse.Format(bFormat))
+// DiffBytesContext is like DiffBytes, but stops with ctx.Err() when ctx is
+// done, and enforces opts.Limits on parsing as well as diffing. Zero fields
+// of opts.Limits take their value from limits.Default().
+func DiffBytesContext(ctx context.Context, a []byte, aFormat string, b []byte, bFormat string, opts Options) (*Result, error) {
+	d, err := New(WithOptions(opts))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse format %s: %w", bFormat, err)
+		return nil, fmt.Errorf("diff failed: %w", err)
 	}
-
-	return DiffTrees(aTree, bTree, opts)
+	return d.DiffBytesContext(ctx, a, aFormat, b, bFormat)
 }
 
 // DiffTrees compares two normalized tree nodes and returns the diff result.
 func DiffTrees(a, b *tree.Node, opts Options) (*Result, error) {
-	// Compute the diff
-	changes, err := diff.Diff(a, b, opts)
-	if err != nil {
-		return nil, fmt.Errorf("diff failed: %w", err)
-	}
+	return DiffTreesContext(context.Background(), a, b, opts)
+}
 
-	// Generate patch from changes
-	var patchObj *patch.Patch
-	patchObj, err = patch.FromChanges(changes)
+// DiffTreesContext is like DiffTrees but stops with ctx.Err() when ctx is
+// done.
+func DiffTreesContext(ctx context.Context, a, b *tree.Node, opts Options) (*Result, error) {
+	d, err := New(WithOptions(opts))
 	if err != nil {
-		return nil, fmt.Errorf("patch generation failed: %w", err)
-	}
-
-	// Generate pretty report
-	reportText := report.GenerateDetailed(changes)
-
-	// Build result
-	result := &Result{
-		Changes: changes,
-		Patch:   patchObj,
-		Report:  reportText,
+		return nil, fmt.Errorf("diff failed: %w", err)
 	}
-
-	return result, nil
+	return d.DiffTreesContext(ctx, a, b)
 }
 
 // DiffYAML is a convenience function for comparing two YAML byte slices.
@@ -1,6 +1,13 @@
 package configdiff
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pfrederiksen/configdiff/limits"
+)
 
 func TestChangeTypeString(t *testing.T) {
 	tests := []struct {
@@ -46,3 +53,159 @@ func TestOptions(t *testing.T) {
 		t.Error("StableOrder = false, want true")
 	}
 }
+
+func TestDiffYAML_ArraySetKeyPatterns(t *testing.T) {
+	oldYAML := []byte(`
+kind: List
+items:
+  - kind: Deployment
+    spec:
+      template:
+        spec:
+          containers:
+            - name: web
+              image: web:1
+              env:
+                - {name: LOG_LEVEL, value: info}
+                - {name: PORT, value: "8080"}
+            - name: sidecar
+              image: proxy:1
+  - kind: CronJob
+    spec:
+      jobTemplate:
+        spec:
+          template:
+            spec:
+              containers:
+                - name: backup
+                  image: backup:1
+                - name: notify
+                  image: notify:1
+`)
+	newYAML := []byte(`
+kind: List
+items:
+  - kind: Deployment
+    spec:
+      template:
+        spec:
+          containers:
+            - name: sidecar
+              image: proxy:1
+            - name: web
+              image: web:2
+              env:
+                - {name: PORT, value: "8080"}
+                - {name: LOG_LEVEL, value: debug}
+  - kind: CronJob
+    spec:
+      jobTemplate:
+        spec:
+          template:
+            spec:
+              containers:
+                - name: notify
+                  image: notify:1
+                - name: backup
+                  image: backup:1
+`)
+
+	tests := []struct {
+		name string
+		keys map[string]string
+		want []string
+	}{
+		{
+			name: "one rule for every workload and nested env",
+			keys: map[string]string{
+				"/items[*]/**/containers": "name",
+				"/**/containers[*]/env":   "name",
+			},
+			want: []string{
+				"/items[0]/spec/template/spec/containers[name=web]/env[name=LOG_LEVEL]/value",
+				"/items[0]/spec/template/spec/containers[name=web]/image",
+			},
+		},
+		{
+			name: "most specific rule wins",
+			keys: map[string]string{
+				"/**/containers": "image",
+				"/items[*]/spec/template/spec/containers": "name",
+				"/**/containers[name=web]/env":            "name",
+				"/**/env":                                 "value",
+			},
+			want: []string{
+				"/items[0]/spec/template/spec/containers[name=web]/env[name=LOG_LEVEL]/value",
+				"/items[0]/spec/template/spec/containers[name=web]/image",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := DiffYAML(oldYAML, newYAML, Options{ArraySetKeys: tt.keys, StableOrder: true})
+			if err != nil {
+				t.Fatalf("DiffYAML() error = %v", err)
+			}
+
+			var paths []string
+			for _, c := range result.Changes {
+				paths = append(paths, c.Path)
+			}
+			if strings.Join(paths, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("paths =\n%s\nwant\n%s", strings.Join(paths, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestDiffBytesContext_Limits(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		lim     limits.Limits
+		wantErr bool
+	}{
+		{"within limits", "a: 1", "a: 2", limits.Default(), false},
+		{"input too large", "a: 1", "a: 12345", limits.Limits{MaxInputBytes: 6}, true},
+		{"too many changes", "a: 1\nb: 1", "a: 2\nb: 2", limits.Limits{MaxChanges: 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Limits: tt.lim}
+			_, err := DiffBytesContext(context.Background(), []byte(tt.a), "yaml", []byte(tt.b), "yaml", opts)
+			if got := errors.Is(err, limits.ErrExceeded); got != tt.wantErr {
+				t.Errorf("DiffBytesContext() error = %v, want limit exceeded: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiffer_DiffBytes(t *testing.T) {
+	d, err := New(WithIgnorePaths("/metadata/**"), WithArraySetKey("/items", "name"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+		want int
+	}{
+		{"ignored path", "metadata: {uid: 1}", "metadata: {uid: 2}", 0},
+		{"keyed array", "items: [{name: a, v: 1}, {name: b}]", "items: [{name: b}, {name: a, v: 2}]", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := d.DiffBytes([]byte(tt.a), "yaml", []byte(tt.b), "yaml")
+			if err != nil {
+				t.Fatalf("DiffBytes() error = %v", err)
+			}
+			if len(result.Changes) != tt.want {
+				t.Errorf("DiffBytes() = %d changes, want %d", len(result.Changes), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package diff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// ArrayKeyError reports an element of a keyed array that cannot be matched
+// by its key when Options.StrictArrayKeys is set.
+type ArrayKeyError struct {
+	// Path is the path of the array.
+	Path string
+
+	// Index is the position of the element in its array.
+	Index int
+
+	// Key lists the key fields, comma-separated.
+	Key string
+
+	// Duplicate is set when the element repeats the key of an earlier
+	// element, and unset when it lacks a key field.
+	Duplicate bool
+
+	// Value is the repeated key, e.g. "name=web", for duplicates.
+	Value string
+}
+
+// Error implements the error interface.
+func (e *ArrayKeyError) Error() string {
+	if e.Duplicate {
+		return fmt.Sprintf("array %s: element %d repeats key %s", e.Path, e.Index, e.Value)
+	}
+	return fmt.Sprintf("array %s: element %d has no %s key", e.Path, e.Index, e.Key)
+}
+
+// keyedSet holds the elements of an array indexed by key.
+type keyedSet struct {
+	byKey map[string]*tree.Node
+	keys  []string // keys in array order
+
+	// rest holds the elements without a usable key, in array order.
+	rest []indexedNode
+}
+
+// indexedNode is an array element with its position.
+type indexedNode struct {
+	node  *tree.Node
+	index int
+}
+
+// keyFields splits a key spec like "containerPort,protocol" into fields.
+func keyFields(spec string) []string {
+	var fields []string
+	for _, f := range strings.Split(spec, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// indexByKey indexes the elements of arr by their key fields. Elements
+// lacking a field or repeating a key are kept apart, or reported as an
+// *ArrayKeyError with StrictArrayKeys.
+func (d *differ) indexByKey(arr *tree.Node, path string, fields []string) (keyedSet, error) {
+	set := keyedSet{byKey: make(map[string]*tree.Node)}
+	for i, elem := range arr.Array {
+		key, ok := elementKey(elem, fields)
+		_, seen := set.byKey[key]
+
+		if !ok || seen {
+			if d.opts.StrictArrayKeys {
+				return keyedSet{}, &ArrayKeyError{
+					Path:      path,
+					Index:     i,
+					Key:       strings.Join(fields, ","),
+					Duplicate: ok,
+					Value:     key,
+				}
+			}
+			set.rest = append(set.rest, indexedNode{node: elem, index: i})
+			continue
+		}
+
+		set.byKey[key] = elem
+		set.keys = append(set.keys, key)
+	}
+	return set, nil
+}
+
+// elementKey renders the key of an array element as it appears in paths,
+// e.g. "name=web" or "containerPort=80,protocol=TCP". It reports false if
+// the element is not an object or a key field is missing or not a string,
+// number or boolean.
+func elementKey(elem *tree.Node, fields []string) (string, bool) {
+	if elem == nil || elem.Kind != tree.KindObject || len(fields) == 0 {
+		return "", false
+	}
+
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		v, ok := elem.Object[field]
+		if !ok {
+			return "", false
+		}
+
+		var value string
+		switch v.Kind {
+		case tree.KindString:
+			value = v.Value.(string)
+		case tree.KindNumber:
+			value = v.NumberString()
+		case tree.KindBool:
+			value = strconv.FormatBool(v.Value.(bool))
+		default:
+			return "", false
+		}
+		parts[i] = field + "=" + value
+	}
+	return strings.Join(parts, ","), true
+}
+
+// fail stops the diff with err, keeping the first error.
+func (d *differ) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
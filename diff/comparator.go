@@ -0,0 +1,89 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// Comparator implements a domain-specific equality rule for the values at
+// matching paths, such as case-insensitive hostnames or normalized URLs.
+//
+// Compare is only called when the value exists on both sides. It is consulted
+// before the default comparison, so it also sees values whose kinds differ.
+type Comparator interface {
+	Compare(path string, a, b *tree.Node) Comparison
+}
+
+// ComparatorFunc adapts an ordinary function to the Comparator interface.
+type ComparatorFunc func(path string, a, b *tree.Node) Comparison
+
+// Compare calls f(path, a, b).
+func (f ComparatorFunc) Compare(path string, a, b *tree.Node) Comparison {
+	return f(path, a, b)
+}
+
+// Comparison is the verdict of a Comparator.
+// The zero value leaves the decision to the default comparison.
+type Comparison struct {
+	// Handled reports that the comparator made a decision.
+	// When false, the next matching comparator or the default comparison is used.
+	Handled bool
+
+	// Equal reports the values as equal: no change is recorded and nested
+	// values are not compared.
+	Equal bool
+
+	// Description explains why the values differ. It is attached to the
+	// resulting modify change and shown in reports instead of the raw values.
+	Description string
+}
+
+// ComparatorRule registers a Comparator for the paths matching a pattern.
+type ComparatorRule struct {
+	// Path is a path pattern, matched like IgnorePaths.
+	Path string
+
+	// Comparator decides equality for values at matching paths.
+	Comparator Comparator
+}
+
+// CaseInsensitive treats strings that differ only in case as equal,
+// e.g. for hostnames or enum-like values.
+var CaseInsensitive = ComparatorFunc(func(path string, a, b *tree.Node) Comparison {
+	if a.Kind != tree.KindString || b.Kind != tree.KindString {
+		return Comparison{}
+	}
+	return Comparison{
+		Handled: true,
+		Equal:   strings.EqualFold(a.Value.(string), b.Value.(string)),
+	}
+})
+
+// compareCustom consults the comparators registered for path, in order.
+// It returns false if none of them handled the comparison.
+func (d *differ) compareCustom(a, b *tree.Node, path string) bool {
+	for _, rule := range d.opts.Comparators {
+		if !d.match(path, rule.Path) {
+			continue
+		}
+
+		result := rule.Comparator.Compare(path, a, b)
+		if !result.Handled {
+			continue
+		}
+
+		if !result.Equal {
+			d.addChange(Change{
+				Type:        ChangeTypeModify,
+				Path:        path,
+				OldValue:    a,
+				NewValue:    b,
+				Description: result.Description,
+			})
+		}
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,23 @@
+package diff
+
+import (
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// Defaults knows the default values of keys that may be omitted from a
+// document, e.g. from a JSON Schema. A key missing on one side whose value on
+// the other side is the default is not reported as a change.
+type Defaults interface {
+	// IsDefault reports whether value, found at path in the document root,
+	// is the default for that path.
+	IsDefault(root *tree.Node, path string, value *tree.Node) bool
+}
+
+// isDefault reports whether value, present at path in root only, is the
+// default for a missing key.
+func (d *differ) isDefault(root *tree.Node, path string, value *tree.Node) bool {
+	if d.opts.Defaults == nil {
+		return false
+	}
+	return d.opts.Defaults.IsDefault(root, path, value)
+}
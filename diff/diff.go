@@ -2,11 +2,12 @@
 package diff
 
 import (
+	"context"
 	"fmt"
 	"sort"
-	"strconv"
-	"strings"
 
+	"github.com/pfrederiksen/configdiff/limits"
+	"github.com/pfrederiksen/configdiff/selector"
 	"github.com/pfrederiksen/configdiff/tree"
 )
 
@@ -24,8 +25,23 @@ type Change struct {
 	// NewValue is the new value (nil for removals).
 	NewValue *tree.Node
 
+	// From is the previous path of a moved value (ChangeTypeMove only).
+	From string
+
 	// ArrayIndex is set for array element changes (optional).
 	ArrayIndex int
+
+	// Description explains the change in domain terms when a Comparator
+	// decided it (optional).
+	Description string
+
+	// Severity flags changes that need attention, e.g. ones that break
+	// schema validation (optional).
+	Severity Severity
+
+	// Category groups changes by what they affect, e.g. "security" or
+	// "scaling", as assigned by classification rules (optional).
+	Category string
 }
 
 // ChangeType categorizes the kind of change.
@@ -41,25 +57,102 @@ const (
 	// ChangeTypeModify indicates a value was changed.
 	ChangeTypeModify ChangeType = "modify"
 
-	// ChangeTypeMove indicates a value was moved (array reordering).
+	// ChangeTypeMove indicates a value was moved from the path in From,
+	// e.g. a renamed key.
 	ChangeTypeMove ChangeType = "move"
+
+	// ChangeTypeNormalized indicates values that differ textually but are
+	// equal after normalization. It is not a change and is only reported
+	// when Options.ReportNormalized is set.
+	ChangeTypeNormalized ChangeType = "normalized"
 )
 
 // Options configures how diffs are computed.
 type Options struct {
-	// IgnorePaths specifies paths to ignore in the diff.
-	// Supports glob-like patterns with wildcards (*).
-	// Example: []string{"metadata.creationTimestamp", "status.*"}
+	// IgnorePaths specifies paths to ignore in the diff, as selectors
+	// (see package selector) such as "/status/*", "/**/uid" or
+	// "/spec/containers[name=sidecar]".
 	IgnorePaths []string
 
 	// ArraySetKeys maps array paths to their key field names.
-	// Arrays at these paths are treated as sets keyed by the specified field.
+	// Arrays at these paths are treated as sets keyed by the specified field,
+	// or by several comma-separated fields, e.g. "containerPort,protocol".
+	// Keys may be strings, numbers or booleans. Elements without the key,
+	// or repeating a key, are compared by position among themselves.
+	// Paths may be selectors, e.g. "/spec/template/**/containers".
 	// Example: map[string]string{"/spec/containers": "name"}
 	ArraySetKeys map[string]string
 
+	// StrictArrayKeys makes Diff fail with an *ArrayKeyError when an
+	// element of a keyed array lacks the key or repeats one, instead of
+	// comparing such elements by position.
+	StrictArrayKeys bool
+
+	// UnorderedArrays lists selectors of arrays compared as multisets:
+	// elements are paired with equal elements wherever they are, and only
+	// elements added or removed are reported. ArraySetKeys takes precedence.
+	// Example: []string{"/spec/containers[*]/args", "/metadata/finalizers"}
+	UnorderedArrays []string
+
+	// InferArrayKeys compares arrays of objects that no other option covers
+	// as sets, keyed by a field inferred from the elements: name, id, key
+	// or containerPort, or else any field whose value is unique on each
+	// side and shared by both.
+	InferArrayKeys bool
+
+	// OnInferredKey, if set, is called with the path and key field of each
+	// array whose key was inferred, so the key can be pinned in ArraySetKeys.
+	// DiffFS may call it from several goroutines at once.
+	OnInferredKey func(path, key string)
+
 	// Coercions configures type coercion rules.
 	Coercions Coercions
 
+	// Comparators registers custom equality rules for matching paths.
+	// They are consulted in order before the default comparison.
+	Comparators []ComparatorRule
+
+	// Normalizers canonicalizes string values at matching paths before
+	// they are compared, e.g. with NormalizeCIDR, NormalizeIP or NormalizeURL.
+	Normalizers []NormalizerRule
+
+	// ReportNormalized records values that are equal only after
+	// normalization as ChangeTypeNormalized changes.
+	ReportNormalized bool
+
+	// Tolerance allows numbers to differ by a small amount.
+	// The zero value compares numbers exactly.
+	Tolerance Tolerance
+
+	// Tolerances overrides Tolerance for numbers at matching paths.
+	// The first matching rule wins.
+	Tolerances []ToleranceRule
+
+	// Equivalence treats null, empty and missing values as equal.
+	// The zero value treats them all as different.
+	Equivalence Equivalence
+
+	// Equivalences overrides Equivalence for values at matching paths.
+	// The first matching rule wins.
+	Equivalences []EquivalenceRule
+
+	// Defaults supplies default values for keys that may be omitted, such
+	// as a schema.Schema. A key missing on one side and set to its default
+	// on the other is not a change.
+	Defaults Defaults
+
+	// KeyRenameThreshold enables key rename detection: a key removed from
+	// an object and a key added to it are reported as a ChangeTypeMove,
+	// followed by any changes between their values, when at least this
+	// percentage of their values is unchanged. 100 pairs only equal values;
+	// 0 disables rename detection.
+	KeyRenameThreshold int
+
+	// Limits bounds the diff; only MaxChanges applies to comparing trees.
+	// A diff reporting more changes fails with a *limits.Error. Zero fields
+	// take their value from limits.Default().
+	Limits limits.Limits
+
 	// StableOrder ensures deterministic ordering in output.
 	StableOrder bool
 }
@@ -77,34 +170,62 @@ type Coercions struct {
 
 // Diff compares two trees and returns the detected changes.
 func Diff(a, b *tree.Node, opts Options) ([]Change, error) {
-	d := &differ{
-		opts:    opts,
-		changes: make([]Change, 0),
-	}
-
-	d.diffNodes(a, b, "/")
+	return DiffContext(context.Background(), a, b, opts)
+}
 
-	if opts.StableOrder {
-		sort.Slice(d.changes, func(i, j int) bool {
-			return d.changes[i].Path < d.changes[j].Path
-		})
+// DiffContext is like Diff but stops with ctx.Err() when ctx is done.
+// To compare many trees with the same options, create a Differ with New.
+func DiffContext(ctx context.Context, a, b *tree.Node, opts Options) ([]Change, error) {
+	d, err := New(WithOptions(opts))
+	if err != nil {
+		return nil, err
 	}
-
-	return d.changes, nil
+	return d.DiffContext(ctx, a, b)
 }
 
 // differ holds state during diff operation.
 type differ struct {
 	opts    Options
 	changes []Change
+
+	// ctx, if set, stops the diff when it is done.
+	ctx context.Context
+
+	// emit, if set, receives the changes instead of changes.
+	emit WalkFunc
+
+	// oldRoot and newRoot are the documents being compared.
+	oldRoot, newRoot *tree.Node
+
+	// selectors holds the compiled path patterns of opts.
+	selectors map[string]*selector.Selector
+
+	// arrayKeys holds the ArraySetKeys selectors, most specific first.
+	arrayKeys []arrayKeyRule
+
+	// hashes memoizes the structural hashes of compared trees that do not
+	// cache them, such as trees built by hand.
+	hashes tree.Hashes
+
+	// reported counts the changes reported, against opts.Limits.
+	reported int
+
+	// err is the first error that stopped the diff.
+	err error
 }
 
 // diffNodes compares two nodes at a given path.
 func (d *differ) diffNodes(a, b *tree.Node, path string) {
-	// Check if path should be ignored
-	if d.shouldIgnore(path) {
+	// Check if path should be ignored, or the diff already failed
+	if d.err != nil || d.shouldIgnore(path) {
 		return
 	}
+	if d.ctx != nil {
+		if err := d.ctx.Err(); err != nil {
+			d.fail(err)
+			return
+		}
+	}
 
 	// Handle nil cases
 	if a == nil && b == nil {
@@ -127,9 +248,27 @@ func (d *differ) diffNodes(a, b *tree.Node, path string) {
 		return
 	}
 
+	// Identical subtrees have no changes
+	if d.identical(a, b) {
+		return
+	}
+
+	// Custom comparators take precedence over the default comparison
+	if d.compareCustom(a, b, path) {
+		return
+	}
+
+	if d.normalizedEqual(a, b, path) {
+		return
+	}
+
+	if d.equivalentEmpty(a, b, path) {
+		return
+	}
+
 	// Try coercion if types differ
 	if a.Kind != b.Kind {
-		if d.canCoerce(a, b) {
+		if d.canCoerce(a, b, path) {
 			return // Values are equal after coercion
 		}
 		d.addChange(Change{
@@ -147,7 +286,17 @@ func (d *differ) diffNodes(a, b *tree.Node, path string) {
 		// Both null, no change
 		return
 
-	case tree.KindBool, tree.KindNumber, tree.KindString:
+	case tree.KindNumber:
+		if !d.numbersEqual(a, b, path) {
+			d.addChange(Change{
+				Type:     ChangeTypeModify,
+				Path:     path,
+				OldValue: a,
+				NewValue: b,
+			})
+		}
+
+	case tree.KindBool, tree.KindString:
 		if a.Value != b.Value {
 			d.addChange(Change{
 				Type:     ChangeTypeModify,
@@ -184,30 +333,67 @@ func (d *differ) diffObjects(a, b *tree.Node, path string) {
 		sort.Strings(keys)
 	}
 
+	// Keys only on one side are held back when they may be renames
+	renames := d.opts.KeyRenameThreshold > 0
+	var removed, added []string
+
 	for _, key := range keys {
 		childPath := joinPath(path, key)
 		aVal, aExists := a.Object[key]
 		bVal, bExists := b.Object[key]
 
 		if !aExists {
-			d.diffNodes(nil, bVal, childPath)
+			if d.equivalentToAbsent(bVal, childPath) || d.isDefault(d.newRoot, childPath, bVal) {
+				continue
+			}
+			if renames {
+				added = append(added, key)
+			} else {
+				d.diffNodes(nil, bVal, childPath)
+			}
 		} else if !bExists {
-			d.diffNodes(aVal, nil, childPath)
+			if d.equivalentToAbsent(aVal, childPath) || d.isDefault(d.oldRoot, childPath, aVal) {
+				continue
+			}
+			if renames {
+				removed = append(removed, key)
+			} else {
+				d.diffNodes(aVal, nil, childPath)
+			}
 		} else {
 			d.diffNodes(aVal, bVal, childPath)
 		}
 	}
+
+	if renames {
+		d.diffRenames(a, b, path, removed, added)
+	}
 }
 
 // diffArrays compares two array nodes.
 func (d *differ) diffArrays(a, b *tree.Node, path string) {
 	// Check if this array should be treated as a set
-	keyField, isSet := d.opts.ArraySetKeys[path]
+	keyField, isSet := d.arraySetKey(path)
 	if isSet {
 		d.diffArrayAsSet(a, b, path, keyField)
 		return
 	}
 
+	if d.isUnordered(path) {
+		d.diffArrayUnordered(a, b, path)
+		return
+	}
+
+	if d.opts.InferArrayKeys {
+		if key, ok := inferArrayKey(a, b); ok {
+			if d.opts.OnInferredKey != nil {
+				d.opts.OnInferredKey(path, key)
+			}
+			d.diffArrayAsSet(a, b, path, key)
+			return
+		}
+	}
+
 	// Positional array comparison
 	maxLen := len(a.Array)
 	if len(b.Array) > maxLen {
@@ -227,36 +413,29 @@ func (d *differ) diffArrays(a, b *tree.Node, path string) {
 	}
 }
 
-// diffArrayAsSet compares arrays as sets keyed by a field.
-func (d *differ) diffArrayAsSet(a, b *tree.Node, path, keyField string) {
-	// Build maps of elements by key
-	aMap := make(map[string]*tree.Node)
-	bMap := make(map[string]*tree.Node)
+// diffArrayAsSet compares arrays as sets keyed by one or more fields.
+// Elements without the key, or repeating a key seen before, are compared
+// by position among themselves, or fail the diff with StrictArrayKeys.
+func (d *differ) diffArrayAsSet(a, b *tree.Node, path, keySpec string) {
+	fields := keyFields(keySpec)
 
-	for _, elem := range a.Array {
-		if key := d.extractKey(elem, keyField); key != "" {
-			aMap[key] = elem
-		}
-	}
-
-	for _, elem := range b.Array {
-		if key := d.extractKey(elem, keyField); key != "" {
-			bMap[key] = elem
-		}
-	}
-
-	// Find all unique keys
-	allKeys := make(map[string]bool)
-	for k := range aMap {
-		allKeys[k] = true
+	aSet, err := d.indexByKey(a, path, fields)
+	if err != nil {
+		d.fail(err)
+		return
 	}
-	for k := range bMap {
-		allKeys[k] = true
+	bSet, err := d.indexByKey(b, path, fields)
+	if err != nil {
+		d.fail(err)
+		return
 	}
 
-	keys := make([]string, 0, len(allKeys))
-	for k := range allKeys {
-		keys = append(keys, k)
+	// Keys in old order, then keys only found in new
+	keys := append([]string(nil), aSet.keys...)
+	for _, k := range bSet.keys {
+		if _, ok := aSet.byKey[k]; !ok {
+			keys = append(keys, k)
+		}
 	}
 
 	if d.opts.StableOrder {
@@ -265,44 +444,36 @@ func (d *differ) diffArrayAsSet(a, b *tree.Node, path, keyField string) {
 
 	// Compare elements by key
 	for _, key := range keys {
-		childPath := fmt.Sprintf("%s[%s=%s]", path, keyField, key)
-		aElem, aExists := aMap[key]
-		bElem, bExists := bMap[key]
-
-		if !aExists {
-			d.diffNodes(nil, bElem, childPath)
-		} else if !bExists {
-			d.diffNodes(aElem, nil, childPath)
-		} else {
-			d.diffNodes(aElem, bElem, childPath)
-		}
+		childPath := fmt.Sprintf("%s[%s]", path, key)
+		d.diffNodes(aSet.byKey[key], bSet.byKey[key], childPath)
 	}
-}
 
-// extractKey extracts the key field value from an object node.
-func (d *differ) extractKey(node *tree.Node, keyField string) string {
-	if node.Kind != tree.KindObject {
-		return ""
-	}
-	keyNode, exists := node.Object[keyField]
-	if !exists || keyNode.Kind != tree.KindString {
-		return ""
+	// Compare the remaining elements by position
+	for i := 0; i < max(len(aSet.rest), len(bSet.rest)); i++ {
+		var aElem, bElem *tree.Node
+		index := 0
+		if i < len(aSet.rest) {
+			aElem, index = aSet.rest[i].node, aSet.rest[i].index
+		}
+		if i < len(bSet.rest) {
+			bElem, index = bSet.rest[i].node, bSet.rest[i].index
+		}
+		d.diffNodes(aElem, bElem, fmt.Sprintf("%s[%d]", path, index))
 	}
-	return keyNode.Value.(string)
 }
 
 // canCoerce checks if two nodes can be considered equal via coercion.
-func (d *differ) canCoerce(a, b *tree.Node) bool {
+func (d *differ) canCoerce(a, b *tree.Node, path string) bool {
 	// Numeric string coercion
 	if d.opts.Coercions.NumericStrings {
 		if a.Kind == tree.KindString && b.Kind == tree.KindNumber {
-			if num, err := strconv.ParseFloat(a.Value.(string), 64); err == nil {
-				return num == b.Value.(float64)
+			if num, err := tree.ParseNumber(a.Value.(string)); err == nil {
+				return d.numbersEqual(num, b, path)
 			}
 		}
 		if a.Kind == tree.KindNumber && b.Kind == tree.KindString {
-			if num, err := strconv.ParseFloat(b.Value.(string), 64); err == nil {
-				return a.Value.(float64) == num
+			if num, err := tree.ParseNumber(b.Value.(string)); err == nil {
+				return d.numbersEqual(a, num, path)
 			}
 		}
 	}
@@ -330,72 +501,52 @@ func (d *differ) canCoerce(a, b *tree.Node) bool {
 	return false
 }
 
+// identical reports whether a and b are structurally equal, by the hashes
+// cached in parsed trees. It reports false for trees without cached hashes,
+// which are compared by walking them instead, as hashing them would cost
+// as much as the walk.
+func (d *differ) identical(a, b *tree.Node) bool {
+	return a.Hashed() && b.Hashed() && a.Hash() == b.Hash()
+}
+
 // shouldIgnore checks if a path should be ignored.
 func (d *differ) shouldIgnore(path string) bool {
 	for _, pattern := range d.opts.IgnorePaths {
-		if matchPath(path, pattern) {
+		if d.match(path, pattern) {
 			return true
 		}
 	}
 	return false
 }
 
-// matchPath checks if a path matches a pattern (supports * wildcard).
-func matchPath(path, pattern string) bool {
-	// Simple implementation: support * as wildcard
-	// Convert pattern to segments
-	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
-	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
-
-	// If pattern has no wildcards, do exact match
-	if !strings.Contains(pattern, "*") {
-		return "/" + strings.Join(pathSegments, "/") == "/" + strings.Join(patternSegments, "/")
-	}
+// MatchPath reports whether path matches pattern the way IgnorePaths are
+// matched. See package selector for the pattern syntax.
+func MatchPath(path, pattern string) bool {
+	return matchPath(path, pattern)
+}
 
-	// Check if pattern matches
-	return matchSegments(pathSegments, patternSegments)
+// matchPath checks if a path matches a selector pattern. Invalid patterns
+// match nothing.
+func matchPath(path, pattern string) bool {
+	return selector.Match(path, pattern)
 }
 
-// matchSegments checks if path segments match pattern segments.
-func matchSegments(pathSegs, patternSegs []string) bool {
-	if len(patternSegs) == 0 {
-		return len(pathSegs) == 0
+// addChange adds a change to the list, or passes it to emit.
+func (d *differ) addChange(c Change) {
+	if d.err != nil {
+		return
 	}
-
-	if len(pathSegs) == 0 {
-		// Check if all remaining pattern segments are wildcards
-		for _, p := range patternSegs {
-			if p != "*" {
-				return false
-			}
-		}
-		return true
+	d.reported++
+	if err := limits.Check(limits.Changes, int64(d.reported), int64(d.opts.Limits.MaxChanges)); err != nil {
+		d.fail(err)
+		return
 	}
-
-	pattern := patternSegs[0]
-	if pattern == "*" {
-		// Wildcard can match:
-		// 1. Nothing (move to next pattern segment)
-		// 2. One or more path segments (consume path segments)
-		if len(patternSegs) == 1 {
-			// Last pattern segment is *, matches everything remaining
-			return true
+	if d.emit != nil {
+		if err := d.emit(c); err != nil {
+			d.fail(err)
 		}
-		// Try matching the rest of the pattern at this position or later
-		return matchSegments(pathSegs[1:], patternSegs[1:]) ||
-			matchSegments(pathSegs[1:], patternSegs)
-	}
-
-	// Check if first segments match
-	if pathSegs[0] != pattern {
-		return false
+		return
 	}
-
-	return matchSegments(pathSegs[1:], patternSegs[1:])
-}
-
-// addChange adds a change to the list.
-func (d *differ) addChange(c Change) {
 	d.changes = append(d.changes, c)
 }
 
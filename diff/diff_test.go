@@ -1,8 +1,15 @@
 package diff
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/pfrederiksen/configdiff/limits"
+	"github.com/pfrederiksen/configdiff/selector"
 	"github.com/pfrederiksen/configdiff/tree"
 )
 
@@ -447,6 +454,681 @@ func TestDiff_IgnorePaths(t *testing.T) {
 	}
 }
 
+func TestDiff_Selectors(t *testing.T) {
+	container := func(name, image string) *tree.Node {
+		return tree.NewObject(map[string]*tree.Node{
+			"name":  tree.NewString(name),
+			"image": tree.NewString(image),
+		})
+	}
+	doc := func(web, sidecar string) *tree.Node {
+		return tree.NewObject(map[string]*tree.Node{
+			"spec": tree.NewObject(map[string]*tree.Node{
+				"template": tree.NewObject(map[string]*tree.Node{
+					"containers": tree.NewArray([]*tree.Node{container("web", web), container("sidecar", sidecar)}),
+				}),
+			}),
+		})
+	}
+	reordered := tree.NewObject(map[string]*tree.Node{
+		"spec": tree.NewObject(map[string]*tree.Node{
+			"template": tree.NewObject(map[string]*tree.Node{
+				"containers": tree.NewArray([]*tree.Node{container("sidecar", "envoy:2"), container("web", "nginx:1")}),
+			}),
+		}),
+	})
+
+	tests := []struct {
+		name      string
+		a, b      *tree.Node
+		opts      Options
+		wantPaths []string
+	}{
+		{
+			name:      "ignore elements by predicate",
+			a:         doc("nginx:1", "envoy:1"),
+			b:         doc("nginx:2", "envoy:2"),
+			opts:      Options{IgnorePaths: []string{"/spec/**/containers[name=sidecar]"}},
+			wantPaths: []string{"/spec/template/containers[0]/image"},
+		},
+		{
+			name:      "ignore any element field",
+			a:         doc("nginx:1", "envoy:1"),
+			b:         doc("nginx:2", "envoy:2"),
+			opts:      Options{IgnorePaths: []string{"/**/containers[*]/image"}},
+			wantPaths: nil,
+		},
+		{
+			name:      "array set keys by selector",
+			a:         doc("nginx:1", "envoy:1"),
+			b:         reordered,
+			opts:      Options{ArraySetKeys: map[string]string{"/**/containers": "name"}},
+			wantPaths: []string{"/spec/template/containers[name=sidecar]/image"},
+		},
+		{
+			name:      "ignore keyed element",
+			a:         doc("nginx:1", "envoy:1"),
+			b:         reordered,
+			opts:      Options{ArraySetKeys: map[string]string{"/**/containers": "name"}, IgnorePaths: []string{"/**/containers[name~=^side]"}},
+			wantPaths: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.StableOrder = true
+			changes, err := Diff(tt.a, tt.b, tt.opts)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			var paths []string
+			for _, c := range changes {
+				paths = append(paths, c.Path)
+			}
+			if strings.Join(paths, ",") != strings.Join(tt.wantPaths, ",") {
+				t.Errorf("Diff() paths = %v, want %v", paths, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestDiff_ArraySetKeyTypes(t *testing.T) {
+	port := func(n float64, protocol string) *tree.Node {
+		return tree.NewObject(map[string]*tree.Node{
+			"containerPort": tree.NewNumber(n),
+			"protocol":      tree.NewString(protocol),
+		})
+	}
+	obj := func(kvs ...interface{}) *tree.Node {
+		m := make(map[string]*tree.Node)
+		for i := 0; i < len(kvs); i += 2 {
+			switch v := kvs[i+1].(type) {
+			case string:
+				m[kvs[i].(string)] = tree.NewString(v)
+			case bool:
+				m[kvs[i].(string)] = tree.NewBool(v)
+			}
+		}
+		return tree.NewObject(m)
+	}
+	doc := func(elems ...*tree.Node) *tree.Node {
+		return tree.NewObject(map[string]*tree.Node{"items": tree.NewArray(elems)})
+	}
+
+	tests := []struct {
+		name      string
+		a, b      *tree.Node
+		key       string
+		wantPaths []string
+	}{
+		{
+			name:      "composite numeric key",
+			a:         doc(port(53, "UDP"), port(53, "TCP"), port(80, "TCP")),
+			b:         doc(port(80, "TCP"), port(53, "TCP")),
+			key:       "containerPort,protocol",
+			wantPaths: []string{"/items[containerPort=53,protocol=UDP]"},
+		},
+		{
+			name:      "bool key",
+			a:         doc(obj("primary", true, "host", "a"), obj("primary", false, "host", "b")),
+			b:         doc(obj("primary", false, "host", "b"), obj("primary", true, "host", "c")),
+			key:       "primary",
+			wantPaths: []string{"/items[primary=true]/host"},
+		},
+		{
+			name:      "elements without key fall back to position",
+			a:         doc(obj("name", "web"), obj("value", "x"), obj("value", "y")),
+			b:         doc(obj("value", "x"), obj("name", "web"), obj("value", "z")),
+			key:       "name",
+			wantPaths: []string{"/items[2]/value"},
+		},
+		{
+			name:      "duplicate keys fall back to position",
+			a:         doc(obj("name", "web", "v", "1"), obj("name", "web", "v", "2")),
+			b:         doc(obj("name", "web", "v", "1"), obj("name", "web", "v", "3")),
+			key:       "name",
+			wantPaths: []string{"/items[1]/v"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := Diff(tt.a, tt.b, Options{ArraySetKeys: map[string]string{"/items": tt.key}, StableOrder: true})
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			var paths []string
+			for _, c := range changes {
+				paths = append(paths, c.Path)
+			}
+			if strings.Join(paths, ",") != strings.Join(tt.wantPaths, ",") {
+				t.Errorf("Diff() paths = %v, want %v", paths, tt.wantPaths)
+			}
+		})
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		opts := Options{ArraySetKeys: map[string]string{"/items": "name"}, StrictArrayKeys: true}
+
+		_, err := Diff(doc(obj("name", "a")), doc(obj("name", "a"), obj("value", "x")), opts)
+		var keyErr *ArrayKeyError
+		if !errors.As(err, &keyErr) || keyErr.Duplicate || keyErr.Index != 1 || keyErr.Path != "/items" {
+			t.Errorf("missing key error = %#v", err)
+		}
+
+		_, err = Diff(doc(obj("name", "a"), obj("name", "a")), doc(), opts)
+		if !errors.As(err, &keyErr) || !keyErr.Duplicate || keyErr.Value != "name=a" {
+			t.Errorf("duplicate key error = %#v", err)
+		}
+		if err != nil && err.Error() != "array /items: element 1 repeats key name=a" {
+			t.Errorf("Error() = %q", err)
+		}
+	})
+}
+
+func TestDiff_UnorderedArrays(t *testing.T) {
+	strs := func(vs ...string) *tree.Node {
+		elems := make([]*tree.Node, len(vs))
+		for i, v := range vs {
+			elems[i] = tree.NewString(v)
+		}
+		return tree.NewObject(map[string]*tree.Node{"items": tree.NewArray(elems)})
+	}
+	nums := func(vs ...*tree.Node) *tree.Node {
+		return tree.NewObject(map[string]*tree.Node{"items": tree.NewArray(vs)})
+	}
+
+	tests := []struct {
+		name string
+		a, b *tree.Node
+		want []string
+	}{
+		{
+			name: "reordered",
+			a:    strs("a", "b", "c"),
+			b:    strs("c", "a", "b"),
+		},
+		{
+			name: "added and removed",
+			a:    strs("a", "b", "c"),
+			b:    strs("d", "c", "a"),
+			want: []string{"add /items[0]", "remove /items[1]"},
+		},
+		{
+			name: "duplicates counted",
+			a:    strs("x", "x", "y"),
+			b:    strs("y", "x"),
+			want: []string{"remove /items[1]"},
+		},
+		{
+			name: "duplicate added",
+			a:    strs("x"),
+			b:    strs("x", "x"),
+			want: []string{"add /items[1]"},
+		},
+		{
+			name: "numbers equal however stored",
+			a:    nums(tree.NewInt(1), tree.NewNumber(2.5)),
+			b:    nums(tree.NewNumber(2.5), tree.NewNumber(1)),
+		},
+		{
+			name: "objects matched by value",
+			a: nums(
+				tree.NewObject(map[string]*tree.Node{"key": tree.NewString("a"), "effect": tree.NewString("NoSchedule")}),
+				tree.NewObject(map[string]*tree.Node{"key": tree.NewString("b"), "effect": tree.NewString("NoSchedule")}),
+			),
+			b: nums(
+				tree.NewObject(map[string]*tree.Node{"key": tree.NewString("b"), "effect": tree.NewString("NoSchedule")}),
+				tree.NewObject(map[string]*tree.Node{"key": tree.NewString("a"), "effect": tree.NewString("NoExecute")}),
+			),
+			want: []string{"remove /items[0]", "add /items[1]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := Diff(tt.a, tt.b, Options{UnorderedArrays: []string{"/items"}, StableOrder: true})
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			var got []string
+			for _, c := range changes {
+				got = append(got, string(c.Type)+" "+c.Path)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiff_InferArrayKeys(t *testing.T) {
+	obj := func(kvs ...interface{}) *tree.Node {
+		m := make(map[string]*tree.Node)
+		for i := 0; i < len(kvs); i += 2 {
+			switch v := kvs[i+1].(type) {
+			case string:
+				m[kvs[i].(string)] = tree.NewString(v)
+			case int:
+				m[kvs[i].(string)] = tree.NewInt(int64(v))
+			}
+		}
+		return tree.NewObject(m)
+	}
+	doc := func(elems ...*tree.Node) *tree.Node {
+		return tree.NewObject(map[string]*tree.Node{"items": tree.NewArray(elems)})
+	}
+
+	tests := []struct {
+		name      string
+		a, b      *tree.Node
+		wantKey   string
+		wantPaths []string
+	}{
+		{
+			name:      "well-known name field",
+			a:         doc(obj("name", "web", "image", "nginx:1"), obj("name", "db", "image", "pg:15")),
+			b:         doc(obj("name", "db", "image", "pg:16"), obj("name", "web", "image", "nginx:1")),
+			wantKey:   "name",
+			wantPaths: []string{"/items[name=db]/image"},
+		},
+		{
+			name:      "numeric containerPort",
+			a:         doc(obj("containerPort", 80, "protocol", "TCP"), obj("containerPort", 443, "protocol", "TCP")),
+			b:         doc(obj("containerPort", 443, "protocol", "TCP"), obj("containerPort", 80, "protocol", "UDP")),
+			wantKey:   "containerPort",
+			wantPaths: []string{"/items[containerPort=80]/protocol"},
+		},
+		{
+			name:      "unique field shared by both sides",
+			a:         doc(obj("host", "a", "weight", 1), obj("host", "b", "weight", 1)),
+			b:         doc(obj("host", "b", "weight", 2), obj("host", "a", "weight", 1)),
+			wantKey:   "host",
+			wantPaths: []string{"/items[host=b]/weight"},
+		},
+		{
+			name:      "duplicate values are not keys",
+			a:         doc(obj("name", "x", "v", "1"), obj("name", "x", "v", "2")),
+			b:         doc(obj("name", "x", "v", "3"), obj("name", "x", "v", "4")),
+			wantPaths: []string{"/items[0]/v", "/items[1]/v"},
+		},
+		{
+			name:      "field without shared values is not a key",
+			a:         doc(obj("host", "a"), obj("host", "b")),
+			b:         doc(obj("host", "c")),
+			wantPaths: []string{"/items[0]/host", "/items[1]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inferred []string
+			opts := Options{
+				InferArrayKeys: true,
+				OnInferredKey: func(path, key string) {
+					inferred = append(inferred, path+"="+key)
+				},
+				StableOrder: true,
+			}
+			changes, err := Diff(tt.a, tt.b, opts)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+
+			var paths []string
+			for _, c := range changes {
+				paths = append(paths, c.Path)
+			}
+			if strings.Join(paths, ",") != strings.Join(tt.wantPaths, ",") {
+				t.Errorf("Diff() paths = %v, want %v", paths, tt.wantPaths)
+			}
+
+			var want []string
+			if tt.wantKey != "" {
+				want = []string{"/items=" + tt.wantKey}
+			}
+			if strings.Join(inferred, ",") != strings.Join(want, ",") {
+				t.Errorf("inferred keys = %v, want %v", inferred, want)
+			}
+		})
+	}
+}
+
+func TestDiff_KeyRenames(t *testing.T) {
+	obj := func(kvs ...interface{}) *tree.Node {
+		m := make(map[string]*tree.Node)
+		for i := 0; i < len(kvs); i += 2 {
+			switch v := kvs[i+1].(type) {
+			case string:
+				m[kvs[i].(string)] = tree.NewString(v)
+			case int:
+				m[kvs[i].(string)] = tree.NewInt(int64(v))
+			case *tree.Node:
+				m[kvs[i].(string)] = v
+			}
+		}
+		return tree.NewObject(m)
+	}
+
+	tests := []struct {
+		name      string
+		a, b      *tree.Node
+		threshold int
+		ignore    []string
+		want      []string
+	}{
+		{
+			name:      "identical value renamed",
+			a:         obj("db_host", "db.local", "port", 5432),
+			b:         obj("database_host", "db.local", "port", 5432),
+			threshold: 100,
+			want:      []string{"move /db_host -> /database_host"},
+		},
+		{
+			name:      "similar subtree renamed with changes",
+			a:         obj("old", obj("a", 1, "b", 2, "c", 3, "d", 4)),
+			b:         obj("new", obj("a", 1, "b", 2, "c", 3, "d", 5)),
+			threshold: 70,
+			want:      []string{"move /old -> /new", "modify /new/d"},
+		},
+		{
+			name:      "below threshold",
+			a:         obj("old", obj("a", 1, "b", 2)),
+			b:         obj("new", obj("a", 1, "b", 3)),
+			threshold: 70,
+			want:      []string{"add /new", "remove /old"},
+		},
+		{
+			name:      "best match wins",
+			a:         obj("x", "one", "y", "two"),
+			b:         obj("p", "two", "q", "one"),
+			threshold: 100,
+			want:      []string{"move /y -> /p", "move /x -> /q"},
+		},
+		{
+			name: "disabled",
+			a:    obj("db_host", "db.local"),
+			b:    obj("database_host", "db.local"),
+			want: []string{"add /database_host", "remove /db_host"},
+		},
+		{
+			name:      "ignored keys are not paired",
+			a:         obj("db_host", "db.local"),
+			b:         obj("database_host", "db.local"),
+			threshold: 100,
+			ignore:    []string{"/database_host"},
+			want:      []string{"remove /db_host"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := Diff(tt.a, tt.b, Options{KeyRenameThreshold: tt.threshold, IgnorePaths: tt.ignore, StableOrder: true})
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			var got []string
+			for _, c := range changes {
+				if c.Type == ChangeTypeMove {
+					got = append(got, "move "+c.From+" -> "+c.Path)
+				} else {
+					got = append(got, string(c.Type)+" "+c.Path)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWalk(t *testing.T) {
+	a := tree.NewObject(map[string]*tree.Node{
+		"a": tree.NewString("1"),
+		"b": tree.NewString("2"),
+		"c": tree.NewObject(map[string]*tree.Node{"d": tree.NewString("3")}),
+	})
+	b := tree.NewObject(map[string]*tree.Node{
+		"a": tree.NewString("x"),
+		"c": tree.NewObject(map[string]*tree.Node{"d": tree.NewString("y")}),
+		"e": tree.NewString("z"),
+	})
+	opts := Options{StableOrder: true}
+	stop := errors.New("stop")
+
+	tests := []struct {
+		name      string
+		ctx       func() context.Context
+		stopAfter int
+		stopErr   error
+		wantPaths []string
+		wantErr   error
+	}{
+		{
+			name:      "all changes",
+			wantPaths: []string{"/a", "/b", "/c/d", "/e"},
+		},
+		{
+			name:      "skip all after the first change",
+			stopAfter: 1,
+			stopErr:   SkipAll,
+			wantPaths: []string{"/a"},
+		},
+		{
+			name:      "other errors are returned",
+			stopAfter: 2,
+			stopErr:   stop,
+			wantPaths: []string{"/a", "/b"},
+			wantErr:   stop,
+		},
+		{
+			name: "canceled context",
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			wantErr: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx()
+			}
+
+			var paths []string
+			err := WalkContext(ctx, a, b, opts, func(c Change) error {
+				paths = append(paths, c.Path)
+				if len(paths) == tt.stopAfter {
+					return tt.stopErr
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("WalkContext() error = %v, want %v", err, tt.wantErr)
+			}
+			if strings.Join(paths, ",") != strings.Join(tt.wantPaths, ",") {
+				t.Errorf("WalkContext() paths = %v, want %v", paths, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestDiffContext_Limits(t *testing.T) {
+	a := tree.NewObject(map[string]*tree.Node{
+		"a": tree.NewString("1"),
+		"b": tree.NewString("2"),
+		"c": tree.NewString("3"),
+	})
+	b := tree.NewObject(map[string]*tree.Node{})
+
+	tests := []struct {
+		name       string
+		maxChanges int
+		wantErr    bool
+	}{
+		{"unlimited", -1, false},
+		{"default", 0, false},
+		{"at the limit", 3, false},
+		{"over the limit", 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Limits: limits.Limits{MaxChanges: tt.maxChanges}}
+			changes, err := DiffContext(context.Background(), a, b, opts)
+			if tt.wantErr {
+				var limErr *limits.Error
+				if !errors.As(err, &limErr) || limErr.Limit != limits.Changes {
+					t.Fatalf("DiffContext() error = %v, want changes limit exceeded", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DiffContext() error = %v", err)
+			}
+			if len(changes) != 3 {
+				t.Errorf("DiffContext() = %d changes, want 3", len(changes))
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr string
+	}{
+		{"no options", nil, ""},
+		{"functional options", []Option{
+			WithIgnorePaths("/status/**"),
+			WithArraySetKey("/items", "name"),
+			WithComparator("/host", CaseInsensitive),
+			WithStableOrder(),
+		}, ""},
+		{"invalid selector", []Option{WithIgnorePaths("/a[")}, "/a["},
+		{"empty array key", []Option{WithArraySetKey("/items", " , ")}, `array key for "/items" is empty`},
+		{"nil comparator", []Option{WithComparator("/host", nil)}, `comparator for "/host" is nil`},
+		{"nil normalizer", []Option{WithNormalizer("/ip", nil)}, `normalizer for "/ip" is nil`},
+		{"rename threshold", []Option{WithOptions(Options{KeyRenameThreshold: 101})}, "not between 0 and 100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.opts...)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiffer_OptionsAreCopied(t *testing.T) {
+	opts := Options{IgnorePaths: []string{"/a"}, ArraySetKeys: map[string]string{}}
+	d, err := New(WithOptions(opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.IgnorePaths[0] = "/b"
+	opts.ArraySetKeys["/items"] = "name"
+
+	got := d.Options()
+	if got.IgnorePaths[0] != "/a" || len(got.ArraySetKeys) != 0 {
+		t.Errorf("Options() = %v, %v, want the options New was given", got.IgnorePaths, got.ArraySetKeys)
+	}
+}
+
+func TestDiffer_Concurrent(t *testing.T) {
+	d, err := New(WithArraySetKey("/items", "name"), WithIgnorePaths("/items[*]/ignored"), WithStableOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := largeTree(200, -1), largeTree(200, 100)
+	want, err := d.Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := d.Diff(a, b)
+			if err == nil && len(got) != len(want) {
+				err = fmt.Errorf("Diff() = %d changes, want %d", len(got), len(want))
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestDiffer_ModifiedTrees(t *testing.T) {
+	d, err := New(WithUnorderedArrays("/tags"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := tree.NewObject(map[string]*tree.Node{
+		"a":    tree.NewString("x"),
+		"tags": tree.NewArray([]*tree.Node{tree.NewString("one")}),
+	})
+	b := tree.NewObject(map[string]*tree.Node{
+		"a":    tree.NewString("x"),
+		"tags": tree.NewArray([]*tree.Node{tree.NewString("one")}),
+	})
+	if changes, err := d.Diff(a, b); err != nil || len(changes) != 0 {
+		t.Fatalf("Diff() = %v, %v, want no changes", changes, err)
+	}
+
+	// Trees modified after a diff must not be compared with stale hashes
+	b.Object["a"].Value = "y"
+	b.Object["tags"].Array[0].Value = "two"
+	changes, err := d.Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 {
+		t.Errorf("Diff() after modification = %d changes, want 3: %v", len(changes), changes)
+	}
+}
+
+func TestDiff_InvalidSelector(t *testing.T) {
+	a := tree.NewObject(map[string]*tree.Node{"a": tree.NewString("x")})
+	b := tree.NewObject(map[string]*tree.Node{"a": tree.NewString("y")})
+
+	for _, opts := range []Options{
+		{IgnorePaths: []string{"/spec/containers[name=web"}},
+		{ArraySetKeys: map[string]string{"/spec/**[0]": "name"}},
+		{Tolerances: []ToleranceRule{{Path: "/a[port>big]"}}},
+	} {
+		_, err := Diff(a, b, opts)
+		var selErr *selector.Error
+		if !errors.As(err, &selErr) {
+			t.Errorf("Diff(%+v) error = %v, want *selector.Error", opts, err)
+		}
+	}
+}
+
 func TestDiff_StableOrder(t *testing.T) {
 	a := tree.NewObject(map[string]*tree.Node{
 		"z": tree.NewString("old"),
@@ -581,6 +1263,10 @@ func TestMatchPath(t *testing.T) {
 		{"/metadata/name", "/metadata/*", true},
 		{"/other/timestamp", "/metadata/*", false},
 		{"/status/conditions/0/type", "/status/*", true},
+		{"/spec/containers[0]/env", "/spec/containers[*]/env", true},
+		{"/spec/containers[name=web]/env", "/spec/containers[name=web]/env", true},
+		{"/spec/template/spec/containers[0]/image", "/**/image", true},
+		{"/spec/containers[0]/env", "/spec/containers[", false},
 	}
 
 	for _, tt := range tests {
@@ -591,3 +1277,489 @@ func TestMatchPath(t *testing.T) {
 		})
 	}
 }
+
+func TestSimilarity(t *testing.T) {
+	base := tree.NewObject(map[string]*tree.Node{
+		"a": tree.NewString("1"),
+		"b": tree.NewString("2"),
+		"c": tree.NewString("3"),
+		"d": tree.NewString("4"),
+	})
+
+	tests := []struct {
+		name string
+		b    *tree.Node
+		opts Options
+		want float64
+	}{
+		{
+			name: "identical",
+			b:    base.Clone(),
+			want: 1,
+		},
+		{
+			name: "one of four modified",
+			b: tree.NewObject(map[string]*tree.Node{
+				"a": tree.NewString("1"),
+				"b": tree.NewString("2"),
+				"c": tree.NewString("3"),
+				"d": tree.NewString("changed"),
+			}),
+			want: 0.75,
+		},
+		{
+			name: "modified value ignored",
+			b: tree.NewObject(map[string]*tree.Node{
+				"a": tree.NewString("1"),
+				"b": tree.NewString("2"),
+				"c": tree.NewString("3"),
+				"d": tree.NewString("changed"),
+			}),
+			opts: Options{IgnorePaths: []string{"/d"}},
+			want: 1,
+		},
+		{
+			name: "nothing in common",
+			b:    tree.NewObject(map[string]*tree.Node{"z": tree.NewString("1")}),
+			want: 0,
+		},
+		{
+			name: "normalized value",
+			b: tree.NewObject(map[string]*tree.Node{
+				"a": tree.NewString("1"),
+				"b": tree.NewString("2"),
+				"c": tree.NewString("3"),
+				"d": tree.NewString(" 4 "),
+			}),
+			opts: Options{
+				Normalizers: []NormalizerRule{{
+					Path:       "/d",
+					Normalizer: func(v string) (string, bool) { return strings.TrimSpace(v), true },
+				}},
+				ReportNormalized: true,
+			},
+			want: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Similarity(base, tt.b, tt.opts)
+			if err != nil {
+				t.Fatalf("Similarity() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Similarity() = %v, want %v", got, tt.want)
+			}
+
+			d, err := New(WithOptions(tt.opts))
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			got, err = d.Similarity(base, tt.b)
+			if err != nil {
+				t.Fatalf("Differ.Similarity() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Differ.Similarity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarity_KeyRenames(t *testing.T) {
+	db := func() *tree.Node {
+		return tree.NewObject(map[string]*tree.Node{
+			"host":     tree.NewString("localhost"),
+			"port":     tree.NewNumber(5432),
+			"user":     tree.NewString("app"),
+			"password": tree.NewString("secret"),
+			"name":     tree.NewString("app"),
+		})
+	}
+	a := tree.NewObject(map[string]*tree.Node{"db": db(), "other": tree.NewNumber(1)})
+	opts := Options{KeyRenameThreshold: 50}
+
+	tests := []struct {
+		name string
+		b    *tree.Node
+		want float64
+	}{
+		{
+			name: "renamed block",
+			b:    tree.NewObject(map[string]*tree.Node{"database": db(), "other": tree.NewNumber(1)}),
+			want: 1 - float64(1)/float64(6),
+		},
+		{
+			name: "renamed block with one modified value",
+			b: func() *tree.Node {
+				renamed := db()
+				renamed.Object["port"] = tree.NewNumber(5433)
+				return tree.NewObject(map[string]*tree.Node{"database": renamed, "other": tree.NewNumber(1)})
+			}(),
+			want: 1 - float64(2)/float64(6),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Similarity(a, tt.b, opts)
+			if err != nil {
+				t.Fatalf("Similarity() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Similarity() = %v, want %v", got, tt.want)
+			}
+			if got < 0.5 {
+				t.Errorf("Similarity() = %v, want a rename at -M=50", got)
+			}
+		})
+	}
+}
+
+func TestDiff_Comparators(t *testing.T) {
+	a := tree.NewObject(map[string]*tree.Node{
+		"host":    tree.NewString("API.example.com"),
+		"version": tree.NewString("1.2.0"),
+		"port":    tree.NewNumber(80),
+		"name":    tree.NewString("Web"),
+	})
+	b := tree.NewObject(map[string]*tree.Node{
+		"host":    tree.NewString("api.example.com"),
+		"version": tree.NewString("1.3.0"),
+		"port":    tree.NewString("80"),
+		"name":    tree.NewString("web"),
+	})
+
+	// Reports a domain-specific description for version bumps
+	semver := ComparatorFunc(func(path string, a, b *tree.Node) Comparison {
+		if a.Equal(b) {
+			return Comparison{Handled: true, Equal: true}
+		}
+		return Comparison{Handled: true, Description: "minor version bump"}
+	})
+
+	// Treats a numeric port and its string form as equal
+	port := ComparatorFunc(func(path string, a, b *tree.Node) Comparison {
+		if a.Kind == tree.KindNumber && b.Kind == tree.KindString && b.Value == "80" {
+			return Comparison{Handled: true, Equal: true}
+		}
+		return Comparison{}
+	})
+
+	// Never makes a decision
+	undecided := ComparatorFunc(func(path string, a, b *tree.Node) Comparison {
+		return Comparison{}
+	})
+
+	changes, err := Diff(a, b, Options{
+		StableOrder: true,
+		Comparators: []ComparatorRule{
+			{Path: "/host", Comparator: undecided},
+			{Path: "/host", Comparator: CaseInsensitive},
+			{Path: "/version", Comparator: semver},
+			{Path: "/port", Comparator: port},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	if len(changes) != 2 {
+		for _, c := range changes {
+			t.Logf("  Change: %s %s", c.Type, c.Path)
+		}
+		t.Fatalf("Diff() got %d changes, want 2", len(changes))
+	}
+	if changes[0].Path != "/name" || changes[0].Description != "" {
+		t.Errorf("changes[0] = %s (%q), want /name without description", changes[0].Path, changes[0].Description)
+	}
+	if changes[1].Path != "/version" || changes[1].Description != "minor version bump" {
+		t.Errorf("changes[1] = %s (%q), want /version with description", changes[1].Path, changes[1].Description)
+	}
+}
+
+func TestDiff_Tolerance(t *testing.T) {
+	tenth := 0.1
+	a := tree.NewObject(map[string]*tree.Node{
+		"ratio":   tree.NewNumber(tenth + 0.2),
+		"latency": tree.NewNumber(100),
+		"id":      tree.NewInt(9007199254740993),
+		"count":   tree.NewInt(10),
+	})
+	b := tree.NewObject(map[string]*tree.Node{
+		"ratio":   tree.NewNumber(0.3),
+		"latency": tree.NewNumber(104),
+		"id":      tree.NewInt(9007199254740992),
+		"count":   tree.NewNumber(10),
+	})
+
+	tests := []struct {
+		name      string
+		opts      Options
+		wantPaths []string
+	}{
+		{
+			name:      "exact comparison",
+			opts:      Options{},
+			wantPaths: []string{"/id", "/latency", "/ratio"},
+		},
+		{
+			name:      "absolute tolerance",
+			opts:      Options{Tolerance: Tolerance{Absolute: 1e-9}},
+			wantPaths: []string{"/latency"},
+		},
+		{
+			name: "per path relative tolerance",
+			opts: Options{
+				Tolerance:  Tolerance{Absolute: 1e-9},
+				Tolerances: []ToleranceRule{{Path: "/latency", Tolerance: Tolerance{Relative: 0.05}}},
+			},
+			wantPaths: []string{},
+		},
+		{
+			name: "per path rule overrides global tolerance",
+			opts: Options{
+				Tolerance:  Tolerance{Absolute: 10},
+				Tolerances: []ToleranceRule{{Path: "/latency", Tolerance: Tolerance{Relative: 0.01}}},
+			},
+			wantPaths: []string{"/latency"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.StableOrder = true
+			changes, err := Diff(a, b, tt.opts)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+
+			if len(changes) != len(tt.wantPaths) {
+				for _, c := range changes {
+					t.Logf("  Change: %s %s", c.Type, c.Path)
+				}
+				t.Fatalf("Diff() got %d changes, want %d", len(changes), len(tt.wantPaths))
+			}
+			for i, c := range changes {
+				if c.Path != tt.wantPaths[i] {
+					t.Errorf("changes[%d].Path = %s, want %s", i, c.Path, tt.wantPaths[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDiff_Equivalence(t *testing.T) {
+	a := tree.NewObject(map[string]*tree.Node{
+		"annotations": tree.NewNull(),
+		"labels":      tree.NewObject(map[string]*tree.Node{}),
+		"args":        tree.NewArray(nil),
+		"comment":     tree.NewString(""),
+		"spec": tree.NewObject(map[string]*tree.Node{
+			"selector": tree.NewNull(),
+		}),
+	})
+	b := tree.NewObject(map[string]*tree.Node{
+		"labels":  tree.NewNull(),
+		"comment": tree.NewNull(),
+		"spec":    tree.NewObject(map[string]*tree.Node{}),
+	})
+
+	tests := []struct {
+		name      string
+		opts      Options
+		wantPaths []string
+	}{
+		{
+			name:      "no equivalence",
+			opts:      Options{},
+			wantPaths: []string{"/annotations", "/args", "/comment", "/labels", "/spec/selector"},
+		},
+		{
+			name:      "null equals absent",
+			opts:      Options{Equivalence: Equivalence{NullAbsent: true}},
+			wantPaths: []string{"/args", "/comment", "/labels"},
+		},
+		{
+			name:      "empty equals absent",
+			opts:      Options{Equivalence: Equivalence{EmptyAbsent: true}},
+			wantPaths: []string{"/annotations", "/comment", "/labels", "/spec/selector"},
+		},
+		{
+			name:      "all equivalences",
+			opts:      Options{Equivalence: Equivalence{NullAbsent: true, EmptyAbsent: true, EmptyStringNull: true}},
+			wantPaths: []string{},
+		},
+		{
+			name: "per path rule",
+			opts: Options{
+				Equivalence:  Equivalence{NullAbsent: true},
+				Equivalences: []EquivalenceRule{{Path: "/spec/*", Equivalence: Equivalence{}}},
+			},
+			wantPaths: []string{"/args", "/comment", "/labels", "/spec/selector"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.StableOrder = true
+			changes, err := Diff(a, b, tt.opts)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+
+			if len(changes) != len(tt.wantPaths) {
+				for _, c := range changes {
+					t.Logf("  Change: %s %s", c.Type, c.Path)
+				}
+				t.Fatalf("Diff() got %d changes, want %d", len(changes), len(tt.wantPaths))
+			}
+			for i, c := range changes {
+				if c.Path != tt.wantPaths[i] {
+					t.Errorf("changes[%d].Path = %s, want %s", i, c.Path, tt.wantPaths[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Severity
+		wantErr bool
+	}{
+		{"info", SeverityInfo, false},
+		{"warn", SeverityWarning, false},
+		{"Warning", SeverityWarning, false},
+		{"error", SeverityError, false},
+		{"fatal", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSeverity(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSeverity(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+
+	if got := SeverityInfo.Max(SeverityError); got != SeverityError {
+		t.Errorf("Max() = %q, want error", got)
+	}
+	if got := Severity("").Max(SeverityInfo); got != SeverityInfo {
+		t.Errorf("Max() = %q, want info", got)
+	}
+}
+
+// largeTree builds a document with n resources, each with containers, labels
+// and a list of args. The resource at index changed, if any, gets a new image.
+func largeTree(n, changed int) *tree.Node {
+	items := make([]*tree.Node, n)
+	for i := range items {
+		image := "app:1.0"
+		if i == changed {
+			image = "app:2.0"
+		}
+		args := make([]*tree.Node, 10)
+		for j := range args {
+			args[j] = tree.NewString(fmt.Sprintf("--flag-%d=%d", j, i))
+		}
+		items[i] = tree.NewObject(map[string]*tree.Node{
+			"name": tree.NewString(fmt.Sprintf("resource-%d", i)),
+			"labels": tree.NewObject(map[string]*tree.Node{
+				"app":  tree.NewString("web"),
+				"tier": tree.NewString("frontend"),
+				"id":   tree.NewInt(int64(i)),
+			}),
+			"containers": tree.NewArray([]*tree.Node{
+				tree.NewObject(map[string]*tree.Node{
+					"name":  tree.NewString("main"),
+					"image": tree.NewString(image),
+					"args":  tree.NewArray(args),
+				}),
+			}),
+		})
+	}
+	return tree.NewObject(map[string]*tree.Node{"items": tree.NewArray(items)})
+}
+
+// parsedTree returns largeTree(n, changed) hashed, as package parse returns
+// trees.
+func parsedTree(n, changed int) *tree.Node {
+	root := largeTree(n, changed)
+	root.Rehash()
+	return root
+}
+
+func BenchmarkDiff_Identical(b *testing.B) {
+	a, c := parsedTree(5000, -1), parsedTree(5000, -1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Diff(a, c, Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDiff_IdenticalUnhashed(b *testing.B) {
+	a, c := largeTree(5000, -1), largeTree(5000, -1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Diff(a, c, Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDiff_OneChange(b *testing.B) {
+	a, c := parsedTree(5000, -1), parsedTree(5000, 2500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Diff(a, c, Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDiff_ArraySetKeys(b *testing.B) {
+	a, c := parsedTree(5000, -1), parsedTree(5000, 2500)
+	opts := Options{ArraySetKeys: map[string]string{"/items": "name", "/items[*]/containers": "name"}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Diff(a, c, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDiff_Unordered(b *testing.B) {
+	a, c := parsedTree(5000, -1), parsedTree(5000, 2500)
+	// Reverse the new items so every element has moved
+	items := c.Object["items"].Array
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+	c.Rehash()
+	opts := Options{UnorderedArrays: []string{"/items"}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Diff(a, c, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDiffer_Reused(b *testing.B) {
+	a, c := parsedTree(5000, -1), parsedTree(5000, 2500)
+	d, err := New(WithArraySetKey("/items", "name"), WithIgnorePaths("/**/ignored"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := d.Diff(a, c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
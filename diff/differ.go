@@ -0,0 +1,215 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/pfrederiksen/configdiff/limits"
+	"github.com/pfrederiksen/configdiff/selector"
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// Differ compares trees with fixed options. Its path patterns are compiled
+// and its options validated once, by New, so reusing a Differ for many
+// comparisons avoids doing so for each of them.
+//
+// A Differ is safe for concurrent use, provided its comparators, normalizers
+// and defaults are. It never modifies the trees it compares, so they may be
+// shared between concurrent comparisons.
+type Differ struct {
+	opts Options
+
+	// selectors holds the compiled path patterns of opts.
+	selectors map[string]*selector.Selector
+
+	// arrayKeys holds the ArraySetKeys selectors, most specific first.
+	arrayKeys []arrayKeyRule
+}
+
+// Option configures a Differ.
+type Option func(*Options)
+
+// New returns a Differ configured by opts, applied in order. It fails if a
+// path pattern is invalid or a rule is incomplete.
+func New(opts ...Option) (*Differ, error) {
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o = o.clone()
+	o.Limits = o.Limits.WithDefaults()
+
+	if err := validate(o); err != nil {
+		return nil, err
+	}
+	selectors, err := compileSelectors(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Differ{
+		opts:      o,
+		selectors: selectors,
+		arrayKeys: arrayKeyRules(o, selectors),
+	}, nil
+}
+
+// WithOptions sets all options at once, replacing those set before.
+func WithOptions(opts Options) Option {
+	return func(o *Options) { *o = opts }
+}
+
+// WithIgnorePaths adds patterns to IgnorePaths.
+func WithIgnorePaths(patterns ...string) Option {
+	return func(o *Options) { o.IgnorePaths = append(o.IgnorePaths, patterns...) }
+}
+
+// WithArraySetKey compares arrays matching pattern as sets keyed by key,
+// as in ArraySetKeys.
+func WithArraySetKey(pattern, key string) Option {
+	return func(o *Options) {
+		if o.ArraySetKeys == nil {
+			o.ArraySetKeys = make(map[string]string)
+		}
+		o.ArraySetKeys[pattern] = key
+	}
+}
+
+// WithUnorderedArrays adds patterns to UnorderedArrays.
+func WithUnorderedArrays(patterns ...string) Option {
+	return func(o *Options) { o.UnorderedArrays = append(o.UnorderedArrays, patterns...) }
+}
+
+// WithComparator adds a rule to Comparators.
+func WithComparator(pattern string, c Comparator) Option {
+	return func(o *Options) {
+		o.Comparators = append(o.Comparators, ComparatorRule{Path: pattern, Comparator: c})
+	}
+}
+
+// WithNormalizer adds a rule to Normalizers.
+func WithNormalizer(pattern string, n Normalizer) Option {
+	return func(o *Options) {
+		o.Normalizers = append(o.Normalizers, NormalizerRule{Path: pattern, Normalizer: n})
+	}
+}
+
+// WithCoercions sets Coercions.
+func WithCoercions(c Coercions) Option {
+	return func(o *Options) { o.Coercions = c }
+}
+
+// WithLimits sets Limits.
+func WithLimits(l limits.Limits) Option {
+	return func(o *Options) { o.Limits = l }
+}
+
+// WithStableOrder sets StableOrder.
+func WithStableOrder() Option {
+	return func(o *Options) { o.StableOrder = true }
+}
+
+// Options returns a copy of the options of d.
+func (d *Differ) Options() Options {
+	return d.opts.clone()
+}
+
+// Diff compares two trees and returns the detected changes, like the
+// package-level Diff.
+func (d *Differ) Diff(a, b *tree.Node) ([]Change, error) {
+	return d.DiffContext(context.Background(), a, b)
+}
+
+// DiffContext is like Diff but stops with ctx.Err() when ctx is done.
+func (d *Differ) DiffContext(ctx context.Context, a, b *tree.Node) ([]Change, error) {
+	s := d.start(ctx, a, b)
+	s.diffNodes(a, b, "/")
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if d.opts.StableOrder {
+		sort.Slice(s.changes, func(i, j int) bool {
+			return s.changes[i].Path < s.changes[j].Path
+		})
+	}
+
+	return s.changes, nil
+}
+
+// Walk passes each change between a and b to fn as soon as it is found,
+// like the package-level Walk.
+func (d *Differ) Walk(a, b *tree.Node, fn WalkFunc) error {
+	return d.WalkContext(context.Background(), a, b, fn)
+}
+
+// WalkContext is like Walk but stops with ctx.Err() when ctx is done.
+func (d *Differ) WalkContext(ctx context.Context, a, b *tree.Node, fn WalkFunc) error {
+	s := d.start(ctx, a, b)
+	s.emit = fn
+
+	s.diffNodes(a, b, "/")
+	if errors.Is(s.err, SkipAll) {
+		return nil
+	}
+	return s.err
+}
+
+// start prepares the state of one comparison of a with b.
+func (d *Differ) start(ctx context.Context, a, b *tree.Node) *differ {
+	return &differ{
+		opts:      d.opts,
+		changes:   make([]Change, 0),
+		ctx:       ctx,
+		hashes:    make(tree.Hashes),
+		oldRoot:   a,
+		newRoot:   b,
+		selectors: d.selectors,
+		arrayKeys: d.arrayKeys,
+	}
+}
+
+// validate reports rules of opts that cannot be applied.
+func validate(opts Options) error {
+	for pattern, key := range opts.ArraySetKeys {
+		if len(keyFields(key)) == 0 {
+			return fmt.Errorf("array key for %q is empty", pattern)
+		}
+	}
+	for _, rule := range opts.Comparators {
+		if rule.Comparator == nil {
+			return fmt.Errorf("comparator for %q is nil", rule.Path)
+		}
+	}
+	for _, rule := range opts.Normalizers {
+		if rule.Normalizer == nil {
+			return fmt.Errorf("normalizer for %q is nil", rule.Path)
+		}
+	}
+	if opts.KeyRenameThreshold < 0 || opts.KeyRenameThreshold > 100 {
+		return fmt.Errorf("key rename threshold %d is not between 0 and 100", opts.KeyRenameThreshold)
+	}
+	return nil
+}
+
+// clone returns a copy of opts that shares no slices or maps with it, so
+// that a Differ is not affected by later changes to the options it was
+// created with.
+func (opts Options) clone() Options {
+	opts.IgnorePaths = append([]string(nil), opts.IgnorePaths...)
+	opts.UnorderedArrays = append([]string(nil), opts.UnorderedArrays...)
+	opts.Comparators = append([]ComparatorRule(nil), opts.Comparators...)
+	opts.Normalizers = append([]NormalizerRule(nil), opts.Normalizers...)
+	opts.Tolerances = append([]ToleranceRule(nil), opts.Tolerances...)
+	opts.Equivalences = append([]EquivalenceRule(nil), opts.Equivalences...)
+	if opts.ArraySetKeys != nil {
+		keys := make(map[string]string, len(opts.ArraySetKeys))
+		for pattern, key := range opts.ArraySetKeys {
+			keys[pattern] = key
+		}
+		opts.ArraySetKeys = keys
+	}
+	return opts
+}
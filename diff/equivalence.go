@@ -0,0 +1,98 @@
+package diff
+
+import (
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// Equivalence treats different spellings of "no value" as equal, for tools
+// that disagree on whether to emit `foo: null`, `foo: []`, `foo: {}`,
+// `foo: ""` or omit the key entirely. The zero value treats them all as
+// different.
+type Equivalence struct {
+	// NullAbsent treats a null value and a missing object key as equal.
+	NullAbsent bool
+
+	// EmptyAbsent treats an empty array or object and a missing object key
+	// as equal.
+	EmptyAbsent bool
+
+	// EmptyStringNull treats an empty string and null as equal. Combined
+	// with NullAbsent, an empty string also equals a missing key.
+	EmptyStringNull bool
+}
+
+// IsZero reports whether e treats no values as equivalent.
+func (e Equivalence) IsZero() bool {
+	return e == Equivalence{}
+}
+
+// EquivalenceRule applies an Equivalence to the values at paths matching a
+// pattern.
+type EquivalenceRule struct {
+	// Path is a path pattern, matched like IgnorePaths.
+	Path string
+
+	// Equivalence is used instead of Options.Equivalence at matching paths.
+	Equivalence Equivalence
+}
+
+// equivalenceFor returns the equivalence for values at path: the first
+// matching rule wins, falling back to the global equivalence.
+func (d *differ) equivalenceFor(path string) Equivalence {
+	for _, rule := range d.opts.Equivalences {
+		if d.match(path, rule.Path) {
+			return rule.Equivalence
+		}
+	}
+	return d.opts.Equivalence
+}
+
+// equivalentToAbsent reports whether a value present on one side only is
+// equivalent to the missing object key at path.
+func (d *differ) equivalentToAbsent(n *tree.Node, path string) bool {
+	eq := d.equivalenceFor(path)
+	if eq.IsZero() {
+		return false
+	}
+	return eq.absent(n)
+}
+
+// equivalentEmpty reports whether two values at path are both "no value"
+// spellings that the configured equivalence treats as equal.
+func (d *differ) equivalentEmpty(a, b *tree.Node, path string) bool {
+	eq := d.equivalenceFor(path)
+	if eq.IsZero() {
+		return false
+	}
+
+	// Values equal to a missing key are equal to each other
+	if eq.absent(a) && eq.absent(b) {
+		return true
+	}
+
+	if eq.EmptyStringNull {
+		return isNullOrEmptyString(a) && isNullOrEmptyString(b)
+	}
+	return false
+}
+
+// absent reports whether n is equivalent to a missing key.
+func (e Equivalence) absent(n *tree.Node) bool {
+	switch n.Kind {
+	case tree.KindNull:
+		return e.NullAbsent
+	case tree.KindArray:
+		return e.EmptyAbsent && len(n.Array) == 0
+	case tree.KindObject:
+		return e.EmptyAbsent && len(n.Object) == 0
+	case tree.KindString:
+		return e.EmptyStringNull && e.NullAbsent && n.Value == ""
+	default:
+		return false
+	}
+}
+
+// isNullOrEmptyString reports whether n is null or the empty string.
+func isNullOrEmptyString(n *tree.Node) bool {
+	return n.Kind == tree.KindNull || (n.Kind == tree.KindString && n.Value == "")
+}
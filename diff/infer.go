@@ -0,0 +1,98 @@
+package diff
+
+import (
+	"sort"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// wellKnownKeys are the fields tried first when inferring array keys.
+var wellKnownKeys = []string{"name", "id", "key", "containerPort"}
+
+// inferArrayKey picks a key field for two arrays of objects: the first
+// well-known field, or else the first other field in sorted order, whose
+// value is a string, number or boolean in every element and unique on each
+// side. Other fields must also share a value between the sides, so that a
+// field that merely changes everywhere is not taken for a key.
+func inferArrayKey(a, b *tree.Node) (string, bool) {
+	if len(a.Array) == 0 && len(b.Array) == 0 {
+		return "", false
+	}
+	for _, elem := range append(append([]*tree.Node(nil), a.Array...), b.Array...) {
+		if elem == nil || elem.Kind != tree.KindObject {
+			return "", false
+		}
+	}
+
+	for _, field := range wellKnownKeys {
+		if _, _, ok := uniqueKeys(a, b, field); ok {
+			return field, true
+		}
+	}
+
+	for _, field := range candidateFields(a, b) {
+		aKeys, bKeys, ok := uniqueKeys(a, b, field)
+		if !ok || len(a.Array)+len(b.Array) < 3 {
+			continue
+		}
+		for key := range aKeys {
+			if bKeys[key] {
+				return field, true
+			}
+		}
+	}
+	return "", false
+}
+
+// candidateFields returns the fields of the first element that are not
+// well-known keys, sorted.
+func candidateFields(a, b *tree.Node) []string {
+	first := a
+	if len(first.Array) == 0 {
+		first = b
+	}
+
+	var fields []string
+	for field := range first.Array[0].Object {
+		if !isWellKnownKey(field) {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// isWellKnownKey reports whether field is one of wellKnownKeys.
+func isWellKnownKey(field string) bool {
+	for _, k := range wellKnownKeys {
+		if k == field {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueKeys returns the keys of the elements of a and b for field, and
+// reports whether every element has one and none repeats on its side.
+func uniqueKeys(a, b *tree.Node, field string) (aKeys, bKeys map[string]bool, ok bool) {
+	fields := []string{field}
+	collect := func(arr *tree.Node) (map[string]bool, bool) {
+		keys := make(map[string]bool, len(arr.Array))
+		for _, elem := range arr.Array {
+			key, ok := elementKey(elem, fields)
+			if !ok || keys[key] {
+				return nil, false
+			}
+			keys[key] = true
+		}
+		return keys, true
+	}
+
+	if aKeys, ok = collect(a); !ok {
+		return nil, nil, false
+	}
+	if bKeys, ok = collect(b); !ok {
+		return nil, nil, false
+	}
+	return aKeys, bKeys, true
+}
@@ -0,0 +1,172 @@
+package diff
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// Normalizer canonicalizes a string value before it is compared.
+// It returns false if the value is not in a form it understands, in which
+// case the value is compared as is.
+type Normalizer func(value string) (string, bool)
+
+// NormalizerRule applies a Normalizer to the string values at paths matching
+// a pattern.
+type NormalizerRule struct {
+	// Path is a path pattern, matched like IgnorePaths.
+	Path string
+
+	// Normalizer canonicalizes values at matching paths.
+	Normalizer Normalizer
+}
+
+// builtinNormalizers maps the names accepted by LookupNormalizer to the
+// built-in normalizers.
+var builtinNormalizers = map[string]Normalizer{
+	"cidr": NormalizeCIDR,
+	"ip":   NormalizeIP,
+	"url":  NormalizeURL,
+}
+
+// LookupNormalizer returns the built-in normalizer with the given name
+// ("cidr", "ip" or "url").
+func LookupNormalizer(name string) (Normalizer, error) {
+	n, ok := builtinNormalizers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown normalizer %q (valid: %s)", name, strings.Join(NormalizerNames(), ", "))
+	}
+	return n, nil
+}
+
+// NormalizerNames returns the names of the built-in normalizers, sorted.
+func NormalizerNames() []string {
+	names := make([]string, 0, len(builtinNormalizers))
+	for name := range builtinNormalizers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NormalizeIP canonicalizes IP addresses, so that "::1" equals
+// "0:0:0:0:0:0:0:1" and "::ffff:10.0.0.1" equals "10.0.0.1".
+func NormalizeIP(value string) (string, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(value))
+	if err != nil {
+		return value, false
+	}
+	return addr.Unmap().String(), true
+}
+
+// NormalizeCIDR canonicalizes CIDR blocks to their network address, so that
+// "10.0.0.1/8" equals "10.0.0.0/8". A bare IP address is treated as a
+// single-host block.
+func NormalizeCIDR(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "/") {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return value, false
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()).String(), true
+	}
+
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return value, false
+	}
+	if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	return prefix.Masked().String(), true
+}
+
+// defaultPorts lists the ports NormalizeURL drops for each scheme.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+}
+
+// NormalizeURL canonicalizes absolute URLs: the scheme and host are
+// lowercased, default ports and trailing slashes are dropped.
+func NormalizeURL(value string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return value, false
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+
+	return u.String(), true
+}
+
+// normalizedEqual reports whether two string values that differ textually
+// are equal after applying the normalizers registered for path, in order.
+// The equality is recorded as a ChangeTypeNormalized change when
+// Options.ReportNormalized is set.
+func (d *differ) normalizedEqual(a, b *tree.Node, path string) bool {
+	if a.Kind != tree.KindString || b.Kind != tree.KindString || a.Value == b.Value {
+		return false
+	}
+
+	aVal, bVal := a.Value.(string), b.Value.(string)
+	matched := false
+	for _, rule := range d.opts.Normalizers {
+		if !d.match(path, rule.Path) {
+			continue
+		}
+		matched = true
+		aVal, _ = rule.Normalizer(aVal)
+		bVal, _ = rule.Normalizer(bVal)
+	}
+
+	if !matched || aVal != bVal {
+		return false
+	}
+
+	if d.opts.ReportNormalized {
+		d.addChange(Change{
+			Type:     ChangeTypeNormalized,
+			Path:     path,
+			OldValue: a,
+			NewValue: b,
+		})
+	}
+	return true
+}
+
+// HasChanges reports whether changes contains any actual change, ignoring
+// values that were only reported as equal after normalization.
+func HasChanges(changes []Change) bool {
+	for _, c := range changes {
+		if c.Type != ChangeTypeNormalized {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+func TestNormalizers(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalizer Normalizer
+		a, b       string
+		wantEqual  bool
+	}{
+		{"cidr host bits", NormalizeCIDR, "10.0.0.0/8", "10.0.0.1/8", true},
+		{"cidr different mask", NormalizeCIDR, "10.0.0.0/8", "10.0.0.0/16", false},
+		{"cidr ipv6 compression", NormalizeCIDR, "2001:db8:0:0::/32", "2001:0db8::/32", true},
+		{"cidr bare address", NormalizeCIDR, "192.168.1.1", "192.168.1.1/32", true},
+		{"ip ipv6 compression", NormalizeIP, "::1", "0:0:0:0:0:0:0:1", true},
+		{"ip mapped ipv4", NormalizeIP, "::ffff:10.0.0.1", "10.0.0.1", true},
+		{"ip different", NormalizeIP, "10.0.0.1", "10.0.0.2", false},
+		{"url default port", NormalizeURL, "https://api.example.com:443/v1", "https://api.example.com/v1", true},
+		{"url trailing slash", NormalizeURL, "http://example.com/", "http://example.com", true},
+		{"url case", NormalizeURL, "HTTP://Example.COM/Path", "http://example.com/Path", true},
+		{"url non-default port", NormalizeURL, "http://example.com:8080", "http://example.com", false},
+		{"url path case", NormalizeURL, "http://example.com/a", "http://example.com/A", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, okA := tt.normalizer(tt.a)
+			b, okB := tt.normalizer(tt.b)
+			if !okA || !okB {
+				t.Fatalf("normalizer did not recognize %q (%v) or %q (%v)", tt.a, okA, tt.b, okB)
+			}
+			if (a == b) != tt.wantEqual {
+				t.Errorf("normalized %q and %q, equal = %v, want %v", a, b, a == b, tt.wantEqual)
+			}
+		})
+	}
+}
+
+func TestNormalizers_Unrecognized(t *testing.T) {
+	for name, n := range builtinNormalizers {
+		if got, ok := n("not a value"); ok || got != "not a value" {
+			t.Errorf("%s normalizer = %q, %v, want value unchanged", name, got, ok)
+		}
+	}
+}
+
+func TestLookupNormalizer(t *testing.T) {
+	for _, name := range []string{"cidr", "IP", "url"} {
+		if _, err := LookupNormalizer(name); err != nil {
+			t.Errorf("LookupNormalizer(%q) error = %v", name, err)
+		}
+	}
+	if _, err := LookupNormalizer("hostname"); err == nil {
+		t.Error("LookupNormalizer(\"hostname\") should fail")
+	}
+}
+
+func TestDiff_Normalizers(t *testing.T) {
+	a := tree.NewObject(map[string]*tree.Node{
+		"cidrs": tree.NewArray([]*tree.Node{
+			tree.NewString("10.0.0.1/8"),
+			tree.NewString("172.16.0.0/12"),
+		}),
+		"endpoint": tree.NewString("https://api.example.com:443/"),
+		"name":     tree.NewString("10.0.0.0/8"),
+	})
+	b := tree.NewObject(map[string]*tree.Node{
+		"cidrs": tree.NewArray([]*tree.Node{
+			tree.NewString("10.0.0.0/8"),
+			tree.NewString("172.16.0.0/16"),
+		}),
+		"endpoint": tree.NewString("https://API.example.com"),
+		"name":     tree.NewString("10.0.0.1/8"),
+	})
+
+	rules := []NormalizerRule{
+		{Path: "/cidrs[0]", Normalizer: NormalizeCIDR},
+		{Path: "/cidrs[1]", Normalizer: NormalizeCIDR},
+		{Path: "/endpoint", Normalizer: NormalizeURL},
+	}
+
+	tests := []struct {
+		name   string
+		report bool
+		want   []ChangeType
+	}{
+		{
+			name: "normalized values are equal",
+			want: []ChangeType{ChangeTypeModify, ChangeTypeModify},
+		},
+		{
+			name:   "normalized values are reported",
+			report: true,
+			want:   []ChangeType{ChangeTypeNormalized, ChangeTypeModify, ChangeTypeNormalized, ChangeTypeModify},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := Diff(a, b, Options{
+				StableOrder:      true,
+				Normalizers:      rules,
+				ReportNormalized: tt.report,
+			})
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+
+			if len(changes) != len(tt.want) {
+				for _, c := range changes {
+					t.Logf("  Change: %s %s", c.Type, c.Path)
+				}
+				t.Fatalf("Diff() got %d changes, want %d", len(changes), len(tt.want))
+			}
+			for i, c := range changes {
+				if c.Type != tt.want[i] {
+					t.Errorf("changes[%d] %s type = %s, want %s", i, c.Path, c.Type, tt.want[i])
+				}
+			}
+			if !HasChanges(changes) {
+				t.Error("HasChanges() = false, want true")
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package diff
+
+import (
+	"math"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// Tolerance allows numbers to differ by a small amount and still compare as
+// equal, e.g. to ignore floating point noise in generated configs.
+// The zero value requires numbers to be exactly equal.
+type Tolerance struct {
+	// Absolute is the largest allowed absolute difference.
+	Absolute float64
+
+	// Relative is the largest allowed difference relative to the larger
+	// magnitude of the two numbers, e.g. 0.01 for 1%.
+	Relative float64
+}
+
+// IsZero reports whether t requires exact equality.
+func (t Tolerance) IsZero() bool {
+	return t.Absolute == 0 && t.Relative == 0
+}
+
+// Within reports whether x and y differ by no more than the tolerance.
+func (t Tolerance) Within(x, y float64) bool {
+	delta := math.Abs(x - y)
+	if delta <= t.Absolute {
+		return true
+	}
+	return delta <= t.Relative*math.Max(math.Abs(x), math.Abs(y))
+}
+
+// ToleranceRule applies a Tolerance to the numbers at paths matching a pattern.
+type ToleranceRule struct {
+	// Path is a path pattern, matched like IgnorePaths.
+	Path string
+
+	// Tolerance is used instead of Options.Tolerance at matching paths.
+	Tolerance Tolerance
+}
+
+// toleranceFor returns the tolerance for numbers at path: the first matching
+// rule wins, falling back to the global tolerance.
+func (d *differ) toleranceFor(path string) Tolerance {
+	for _, rule := range d.opts.Tolerances {
+		if d.match(path, rule.Path) {
+			return rule.Tolerance
+		}
+	}
+	return d.opts.Tolerance
+}
+
+// numbersEqual compares two number nodes exactly, or within the tolerance
+// configured for path.
+func (d *differ) numbersEqual(a, b *tree.Node, path string) bool {
+	if cmp, ok := tree.CompareNumbers(a, b); ok && cmp == 0 {
+		return true
+	}
+
+	tol := d.toleranceFor(path)
+	if tol.IsZero() {
+		return false
+	}
+
+	x, _ := a.Float64()
+	y, _ := b.Float64()
+	return tol.Within(x, y)
+}
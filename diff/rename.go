@@ -0,0 +1,102 @@
+package diff
+
+import (
+	"sort"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// diffRenames reports the keys removed from and added to the objects at
+// path. Each removed key is paired with the added key whose value is most
+// similar to its own, if similar enough for KeyRenameThreshold, and reported
+// as a move followed by the changes between the two values.
+func (d *differ) diffRenames(a, b *tree.Node, path string, removed, added []string) {
+	if len(removed) > 0 && len(added) > 0 {
+		removed, added = d.pairRenames(a, b, path, removed, added)
+	}
+
+	for _, key := range removed {
+		d.diffNodes(a.Object[key], nil, joinPath(path, key))
+	}
+	for _, key := range added {
+		d.diffNodes(nil, b.Object[key], joinPath(path, key))
+	}
+}
+
+// pairRenames reports the renamed keys and returns the keys left unpaired.
+func (d *differ) pairRenames(a, b *tree.Node, path string, removed, added []string) (restRemoved, restAdded []string) {
+	// Pair in key order so the outcome does not depend on map order
+	removed = append([]string(nil), removed...)
+	added = append([]string(nil), added...)
+	sort.Strings(removed)
+	sort.Strings(added)
+
+	paired := make([]bool, len(added))
+	for _, oldKey := range removed {
+		oldPath := joinPath(path, oldKey)
+		if d.shouldIgnore(oldPath) {
+			restRemoved = append(restRemoved, oldKey)
+			continue
+		}
+
+		best, bestScore := -1, 0.0
+		for i, newKey := range added {
+			newPath := joinPath(path, newKey)
+			if paired[i] || d.shouldIgnore(newPath) {
+				continue
+			}
+			score := d.similarity(a.Object[oldKey], b.Object[newKey], newPath)
+			if score*100 >= float64(d.opts.KeyRenameThreshold) && score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			restRemoved = append(restRemoved, oldKey)
+			continue
+		}
+
+		paired[best] = true
+		newPath := joinPath(path, added[best])
+		oldVal, newVal := a.Object[oldKey], b.Object[added[best]]
+		d.addChange(Change{
+			Type:     ChangeTypeMove,
+			Path:     newPath,
+			From:     oldPath,
+			OldValue: oldVal,
+			NewValue: newVal,
+		})
+		d.diffNodes(oldVal, newVal, newPath)
+	}
+
+	for i, key := range added {
+		if !paired[i] {
+			restAdded = append(restAdded, key)
+		}
+	}
+	return restRemoved, restAdded
+}
+
+// similarity scores how alike a and b are when compared at path, from 0
+// to 1, under the differ's options.
+func (d *differ) similarity(a, b *tree.Node, path string) float64 {
+	if d.identical(a, b) {
+		return 1
+	}
+
+	sub := &differ{
+		opts:      d.opts,
+		ctx:       d.ctx,
+		hashes:    d.hashes,
+		oldRoot:   d.oldRoot,
+		newRoot:   d.newRoot,
+		selectors: d.selectors,
+		arrayKeys: d.arrayKeys,
+	}
+	sub.opts.OnInferredKey = nil
+
+	sub.diffNodes(a, b, path)
+	if sub.err != nil {
+		return 0
+	}
+	return similarity(a, b, sub.changes)
+}
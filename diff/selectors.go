@@ -0,0 +1,96 @@
+package diff
+
+import (
+	"sort"
+
+	"github.com/pfrederiksen/configdiff/selector"
+)
+
+// compileSelectors compiles every path pattern in opts, so invalid patterns
+// are reported instead of silently matching nothing.
+func compileSelectors(opts Options) (map[string]*selector.Selector, error) {
+	patterns := append([]string(nil), opts.IgnorePaths...)
+	for pattern := range opts.ArraySetKeys {
+		patterns = append(patterns, pattern)
+	}
+	patterns = append(patterns, opts.UnorderedArrays...)
+	for _, rule := range opts.Comparators {
+		patterns = append(patterns, rule.Path)
+	}
+	for _, rule := range opts.Normalizers {
+		patterns = append(patterns, rule.Path)
+	}
+	for _, rule := range opts.Tolerances {
+		patterns = append(patterns, rule.Path)
+	}
+	for _, rule := range opts.Equivalences {
+		patterns = append(patterns, rule.Path)
+	}
+
+	selectors := make(map[string]*selector.Selector, len(patterns))
+	for _, pattern := range patterns {
+		if _, ok := selectors[pattern]; ok {
+			continue
+		}
+		s, err := selector.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		selectors[pattern] = s
+	}
+	return selectors, nil
+}
+
+// match reports whether path matches pattern. Predicates on array elements
+// are evaluated against both compared documents.
+func (d *differ) match(path, pattern string) bool {
+	s, ok := d.selectors[pattern]
+	if !ok {
+		var err error
+		if s, err = selector.Compile(pattern); err != nil {
+			return false
+		}
+	}
+	return s.MatchIn(path, d.oldRoot, d.newRoot)
+}
+
+// arrayKeyRule is a compiled ArraySetKeys entry.
+type arrayKeyRule struct {
+	selector *selector.Selector
+	key      string
+}
+
+// arrayKeyRules orders the ArraySetKeys entries from the most to the least
+// specific selector, ties broken by pattern.
+func arrayKeyRules(opts Options, selectors map[string]*selector.Selector) []arrayKeyRule {
+	rules := make([]arrayKeyRule, 0, len(opts.ArraySetKeys))
+	for pattern, key := range opts.ArraySetKeys {
+		rules = append(rules, arrayKeyRule{selector: selectors[pattern], key: key})
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		a, b := rules[i].selector, rules[j].selector
+		if a.Specificity() != b.Specificity() {
+			return a.Specificity() > b.Specificity()
+		}
+		return a.String() < b.String()
+	})
+	return rules
+}
+
+// arraySetKey returns the key field of the array at path: an exact
+// ArraySetKeys entry, or else that of the most specific matching selector.
+// Selectors may reach into keyed elements, e.g.
+// "/spec/containers[*]/env" matches "/spec/containers[name=web]/env".
+func (d *differ) arraySetKey(path string) (string, bool) {
+	if key, ok := d.opts.ArraySetKeys[path]; ok {
+		return key, true
+	}
+
+	for _, rule := range d.arrayKeys {
+		if rule.selector.MatchIn(path, d.oldRoot, d.newRoot) {
+			return rule.key, true
+		}
+	}
+	return "", false
+}
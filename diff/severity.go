@@ -0,0 +1,57 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity ranks how much attention a change needs.
+type Severity string
+
+const (
+	// SeverityInfo marks a change worth knowing about.
+	SeverityInfo Severity = "info"
+
+	// SeverityWarning marks a change that should be reviewed.
+	SeverityWarning Severity = "warning"
+
+	// SeverityError marks a change that breaks the configuration, e.g. one
+	// that makes a valid document fail schema validation.
+	SeverityError Severity = "error"
+)
+
+// ParseSeverity parses a severity name. "warn" is accepted for warning.
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "info":
+		return SeverityInfo, nil
+	case "warn", "warning":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	default:
+		return "", fmt.Errorf("unknown severity %q, expected info, warning or error", s)
+	}
+}
+
+// Rank orders severities from 0 for none to 3 for error.
+func (s Severity) Rank() int {
+	switch s {
+	case SeverityInfo:
+		return 1
+	case SeverityWarning:
+		return 2
+	case SeverityError:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Max returns the higher of two severities.
+func (s Severity) Max(other Severity) Severity {
+	if other.Rank() > s.Rank() {
+		return other
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// Similarity reports how alike two trees are, from 0 (nothing in common) to
+// 1 (no changes under opts).
+//
+// The score is the share of leaves in the larger tree that are not touched by
+// any change, so a single modified value in a large document still scores
+// close to 1 while a rewritten document scores close to 0.
+func Similarity(a, b *tree.Node, opts Options) (float64, error) {
+	d, err := New(WithOptions(opts))
+	if err != nil {
+		return 0, err
+	}
+	return d.Similarity(a, b)
+}
+
+// Similarity reports how alike two trees are under the options of d, like
+// the package-level Similarity.
+func (d *Differ) Similarity(a, b *tree.Node) (float64, error) {
+	changes, err := d.Diff(a, b)
+	if err != nil {
+		return 0, err
+	}
+	return similarity(a, b, changes), nil
+}
+
+// similarity scores how alike a and b are given the changes between them.
+//
+// A change counts the leaves of the larger of its values, except that a
+// move counts as a single leaf, since it is a renamed key whose value is
+// compared by the changes under it, and normalized changes count nothing.
+// Changes under a path already counted in full are not counted again.
+func similarity(a, b *tree.Node, changes []Change) float64 {
+	size := max(a.LeafCount(), b.LeafCount())
+	if size == 0 || len(changes) == 0 {
+		return 1
+	}
+
+	changed := 0
+	var counted []string
+	for _, c := range changes {
+		switch {
+		case c.Type == ChangeTypeNormalized:
+			continue
+		case c.Type == ChangeTypeMove:
+			changed++
+			continue
+		case withinAny(c.Path, counted):
+			continue
+		}
+		counted = append(counted, c.Path)
+		changed += max(c.OldValue.LeafCount(), c.NewValue.LeafCount())
+	}
+	if changed >= size {
+		return 0
+	}
+
+	return 1 - float64(changed)/float64(size)
+}
+
+// withinAny reports whether path is one of parents or lies under one of
+// them.
+func withinAny(path string, parents []string) bool {
+	for _, parent := range parents {
+		if path == parent || parent == "/" {
+			return true
+		}
+		if strings.HasPrefix(path, parent) && (path[len(parent)] == '/' || path[len(parent)] == '[') {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"fmt"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// isUnordered reports whether the array at path matches an UnorderedArrays
+// selector.
+func (d *differ) isUnordered(path string) bool {
+	for _, pattern := range d.opts.UnorderedArrays {
+		if d.match(path, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// pairUnordered pairs elem with the first unpaired element of b with the
+// same structural hash, and reports whether there was one.
+func (d *differ) pairUnordered(elem *tree.Node, unpaired map[uint64][]int, paired []bool) bool {
+	h := d.hashes.Of(elem)
+	js := unpaired[h]
+	if len(js) == 0 {
+		return false
+	}
+	paired[js[0]] = true
+	unpaired[h] = js[1:]
+	return true
+}
+
+// diffArrayUnordered compares arrays as multisets: each element of a is
+// paired with an equal element of b, found by structural hash, as many times as
+// it occurs in both.
+// Unpaired elements are reported as removed at their old index or added at
+// their new index.
+func (d *differ) diffArrayUnordered(a, b *tree.Node, path string) {
+	// Index the new elements by structural hash, in array order
+	unpaired := make(map[uint64][]int)
+	for j, elem := range b.Array {
+		h := d.hashes.Of(elem)
+		unpaired[h] = append(unpaired[h], j)
+	}
+
+	paired := make([]bool, len(b.Array))
+	var removed []int
+	for i, elem := range a.Array {
+		if !d.pairUnordered(elem, unpaired, paired) {
+			removed = append(removed, i)
+		}
+	}
+
+	var changes []Change
+	for _, i := range removed {
+		changes = append(changes, Change{
+			Type:       ChangeTypeRemove,
+			Path:       fmt.Sprintf("%s[%d]", path, i),
+			OldValue:   a.Array[i],
+			ArrayIndex: i,
+		})
+	}
+	for j, ok := range paired {
+		if !ok {
+			changes = append(changes, Change{
+				Type:       ChangeTypeAdd,
+				Path:       fmt.Sprintf("%s[%d]", path, j),
+				NewValue:   b.Array[j],
+				ArrayIndex: j,
+			})
+		}
+	}
+
+	for _, c := range changes {
+		if !d.shouldIgnore(c.Path) {
+			d.addChange(c)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package diff
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// SkipAll is returned by a WalkFunc to stop the walk. Walk then returns nil.
+var SkipAll = errors.New("skip all remaining changes")
+
+// WalkFunc is called by Walk for each change as it is found. Returning
+// SkipAll stops the walk without error; returning any other error stops it
+// and makes Walk return that error.
+type WalkFunc func(c Change) error
+
+// Walk compares two trees like Diff but passes each change to fn as soon as
+// it is found instead of collecting them, so callers can stop early, e.g.
+// on the first change, or start printing before the whole diff is done.
+//
+// Changes are reported in the order they are found. With StableOrder, keys
+// and keyed array elements are visited in sorted order, but the changes are
+// not sorted by path afterwards as Diff sorts them.
+func Walk(a, b *tree.Node, opts Options, fn WalkFunc) error {
+	return WalkContext(context.Background(), a, b, opts, fn)
+}
+
+// WalkContext is like Walk but stops with ctx.Err() when ctx is done.
+func WalkContext(ctx context.Context, a, b *tree.Node, opts Options, fn WalkFunc) error {
+	d, err := New(WithOptions(opts))
+	if err != nil {
+		return err
+	}
+	return d.WalkContext(ctx, a, b, fn)
+}
@@ -0,0 +1,127 @@
+package configdiff
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/parse"
+	"github.com/pfrederiksen/configdiff/patch"
+	"github.com/pfrederiksen/configdiff/report"
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// Option configures a Differ. See the With functions.
+type Option = diff.Option
+
+// Options for New, re-exported from package diff.
+var (
+	WithOptions         = diff.WithOptions
+	WithIgnorePaths     = diff.WithIgnorePaths
+	WithArraySetKey     = diff.WithArraySetKey
+	WithUnorderedArrays = diff.WithUnorderedArrays
+	WithComparator      = diff.WithComparator
+	WithNormalizer      = diff.WithNormalizer
+	WithCoercions       = diff.WithCoercions
+	WithLimits          = diff.WithLimits
+	WithStableOrder     = diff.WithStableOrder
+)
+
+// Differ compares configurations with fixed options, validated and
+// compiled once by New. It is safe for concurrent use under the conditions
+// of diff.Differ.
+type Differ struct {
+	d *diff.Differ
+}
+
+// New returns a Differ configured by opts, applied in order.
+//
+//	d, err := configdiff.New(
+//		configdiff.WithIgnorePaths("/metadata/**"),
+//		configdiff.WithArraySetKey("/spec/containers", "name"),
+//	)
+func New(opts ...Option) (*Differ, error) {
+	d, err := diff.New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Differ{d: d}, nil
+}
+
+// Options returns a copy of the options of d.
+func (d *Differ) Options() Options {
+	return d.d.Options()
+}
+
+// DiffBytes compares two configuration byte slices, like the package-level
+// DiffBytes.
+func (d *Differ) DiffBytes(a []byte, aFormat string, b []byte, bFormat string) (*Result, error) {
+	return d.DiffBytesContext(context.Background(), a, aFormat, b, bFormat)
+}
+
+// DiffBytesContext is like DiffBytes, but stops with ctx.Err() when ctx is
+// done, and enforces the Limits option on parsing as well as diffing.
+func (d *Differ) DiffBytesContext(ctx context.Context, a []byte, aFormat string, b []byte, bFormat string) (*Result, error) {
+	lim := d.d.Options().Limits
+
+	// Parse format a
+	aTree, err := parse.ParseContext(ctx, a, parse.Format(aFormat), lim)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse format %s: %w", aFormat, err)
+	}
+
+	// Parse format b
+	var bTree *tree.Node
+	bTree, err = parse.ParseContext(ctx, b, parse.Format(bFormat), lim)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse format %s: %w", bFormat, err)
+	}
+
+	return d.DiffTreesContext(ctx, aTree, bTree)
+}
+
+// DiffTrees compares two normalized tree nodes, like the package-level
+// DiffTrees.
+func (d *Differ) DiffTrees(a, b *tree.Node) (*Result, error) {
+	return d.DiffTreesContext(context.Background(), a, b)
+}
+
+// DiffTreesContext is like DiffTrees but stops with ctx.Err() when ctx is
+// done.
+func (d *Differ) DiffTreesContext(ctx context.Context, a, b *tree.Node) (*Result, error) {
+	// Compute the diff
+	changes, err := d.d.DiffContext(ctx, a, b)
+	if err != nil {
+		return nil, fmt.Errorf("diff failed: %w", err)
+	}
+
+	// Generate patch from changes
+	var patchObj *patch.Patch
+	patchObj, err = patch.FromChanges(changes)
+	if err != nil {
+		return nil, fmt.Errorf("patch generation failed: %w", err)
+	}
+
+	// Generate pretty report
+	reportText := report.GenerateDetailed(changes)
+
+	// Build result
+	result := &Result{
+		Changes: changes,
+		Patch:   patchObj,
+		Report:  reportText,
+	}
+
+	return result, nil
+}
+
+// Walk passes each change between a and b to fn as it is found, like the
+// package-level Walk.
+func (d *Differ) Walk(a, b *tree.Node, fn WalkFunc) error {
+	return d.d.Walk(a, b, fn)
+}
+
+// WalkContext is like Walk but stops with ctx.Err() when ctx is done.
+func (d *Differ) WalkContext(ctx context.Context, a, b *tree.Node, fn WalkFunc) error {
+	return d.d.WalkContext(ctx, a, b, fn)
+}
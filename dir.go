@@ -0,0 +1,323 @@
+package configdiff
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/pfrederiksen/configdiff/internal/fileset"
+	"github.com/pfrederiksen/configdiff/parse"
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// FileStatus describes what happened to a file in a directory comparison.
+type FileStatus string
+
+const (
+	// FileUnchanged indicates the file exists on both sides without changes.
+	FileUnchanged FileStatus = "unchanged"
+
+	// FileModified indicates the file exists on both sides with changes.
+	FileModified FileStatus = "modified"
+
+	// FileAdded indicates the file only exists in the new directory.
+	FileAdded FileStatus = "added"
+
+	// FileRemoved indicates the file only exists in the old directory.
+	FileRemoved FileStatus = "removed"
+
+	// FileRenamed indicates the file was moved to a different path.
+	FileRenamed FileStatus = "renamed"
+
+	// FileError indicates the file could not be read, parsed or diffed.
+	FileError FileStatus = "error"
+)
+
+// DirOptions configures a directory comparison.
+type DirOptions struct {
+	// Options configures how each pair of files is diffed.
+	Options
+
+	// OldFormat and NewFormat force the input format of every file on the
+	// respective side. Empty or "auto" detects the format from the extension.
+	OldFormat string
+	NewFormat string
+
+	// Include limits the comparison to files matching these doublestar globs.
+	Include []string
+
+	// Exclude skips files and directories matching these doublestar globs.
+	Exclude []string
+
+	// UseGitignore honors .gitignore files in addition to .configdiffignore files.
+	UseGitignore bool
+
+	// RenameThreshold enables rename detection: a removed and an added file are
+	// paired when at least this percentage of their values is unchanged.
+	// 0 disables rename detection.
+	RenameThreshold int
+
+	// Workers bounds the number of files diffed concurrently.
+	// 0 uses one worker per CPU.
+	Workers int
+}
+
+// FileResult is the outcome of comparing a single file.
+type FileResult struct {
+	// Path is the slash-separated path relative to the compared directories.
+	// For renamed files it is the path in the new directory.
+	Path string
+
+	// OldPath is the path in the old directory for renamed files.
+	OldPath string
+
+	// Status describes what happened to the file.
+	Status FileStatus
+
+	// Similarity is the share of unchanged values for renamed files (0-1).
+	Similarity float64
+
+	// Result holds the diff for files present on both sides. It is nil for
+	// added, removed and failed files.
+	Result *Result
+
+	// Err is set when Status is FileError.
+	Err error
+}
+
+// DirResult contains the output of a directory comparison.
+type DirResult struct {
+	// Files holds one entry per file, sorted by Path.
+	Files []FileResult
+}
+
+// DirSummary counts files by status.
+type DirSummary struct {
+	Compared  int
+	Unchanged int
+	Modified  int
+	Added     int
+	Removed   int
+	Renamed   int
+	Errors    int
+}
+
+// Summary counts the files in the result by status.
+func (r *DirResult) Summary() DirSummary {
+	var s DirSummary
+	for _, f := range r.Files {
+		switch f.Status {
+		case FileUnchanged:
+			s.Compared++
+			s.Unchanged++
+		case FileModified:
+			s.Compared++
+			s.Modified++
+		case FileAdded:
+			s.Added++
+		case FileRemoved:
+			s.Removed++
+		case FileRenamed:
+			s.Renamed++
+		case FileError:
+			s.Errors++
+		}
+	}
+	return s
+}
+
+// HasChanges returns true if any file was modified, added, removed or renamed.
+func (r *DirResult) HasChanges() bool {
+	for _, f := range r.Files {
+		switch f.Status {
+		case FileModified, FileAdded, FileRemoved, FileRenamed:
+			return true
+		}
+	}
+	return false
+}
+
+// DiffDirs recursively compares the configuration files in two directories.
+//
+// Files are paired by relative path and diffed concurrently. Files that fail to
+// parse are reported with FileError instead of aborting the comparison.
+func DiffDirs(oldDir, newDir string, opts DirOptions) (*DirResult, error) {
+	for _, dir := range []string{oldDir, newDir} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%q is not a directory", dir)
+		}
+	}
+	return DiffFS(os.DirFS(oldDir), os.DirFS(newDir), opts)
+}
+
+// DiffFS compares the configuration files in two file systems, as DiffDirs.
+func DiffFS(oldFS, newFS fs.FS, opts DirOptions) (*DirResult, error) {
+	selection := fileset.Options{
+		Include:      opts.Include,
+		Exclude:      opts.Exclude,
+		UseGitignore: opts.UseGitignore,
+	}
+
+	oldFiles, err := fileset.Collect(oldFS, selection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan old directory: %w", err)
+	}
+	newFiles, err := fileset.Collect(newFS, selection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan new directory: %w", err)
+	}
+
+	inNew := make(map[string]bool, len(newFiles))
+	for _, p := range newFiles {
+		inNew[p] = true
+	}
+
+	var jobs []FileResult
+	var removed, added []string
+	for _, p := range oldFiles {
+		if inNew[p] {
+			jobs = append(jobs, FileResult{Path: p, OldPath: p})
+			delete(inNew, p)
+		} else {
+			removed = append(removed, p)
+		}
+	}
+	for _, p := range newFiles {
+		if inNew[p] {
+			added = append(added, p)
+		}
+	}
+
+	// Compile the options once for all files
+	differ, err := New(WithOptions(opts.Options))
+	if err != nil {
+		return nil, err
+	}
+	loader := &dirLoader{oldFS: oldFS, newFS: newFS, opts: opts, differ: differ}
+
+	if opts.RenameThreshold > 0 {
+		for _, r := range loader.detectRenames(removed, added) {
+			removed = without(removed, r.OldPath)
+			added = without(added, r.Path)
+			jobs = append(jobs, r)
+		}
+	}
+
+	loader.run(jobs)
+
+	files := jobs
+	for _, p := range added {
+		files = append(files, FileResult{Path: p, Status: FileAdded})
+	}
+	for _, p := range removed {
+		files = append(files, FileResult{Path: p, Status: FileRemoved})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return &DirResult{Files: files}, nil
+}
+
+// dirLoader parses and diffs files from the two sides of a comparison.
+type dirLoader struct {
+	oldFS fs.FS
+	newFS fs.FS
+	opts  DirOptions
+
+	// differ compares each pair of files under opts.Options.
+	differ *Differ
+}
+
+// run diffs every job with a bounded pool of workers, filling in its result.
+func (l *dirLoader) run(jobs []FileResult) {
+	workers := l.opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				l.diffFile(&jobs[i])
+			}
+		}()
+	}
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// diffFile diffs the old and new versions of a file and records the outcome.
+func (l *dirLoader) diffFile(f *FileResult) {
+	oldTree, err := l.load(l.oldFS, f.OldPath, l.opts.OldFormat)
+	if err != nil {
+		f.Status, f.Err = FileError, err
+		return
+	}
+	newTree, err := l.load(l.newFS, f.Path, l.opts.NewFormat)
+	if err != nil {
+		f.Status, f.Err = FileError, err
+		return
+	}
+
+	result, err := l.differ.DiffTrees(oldTree, newTree)
+	if err != nil {
+		f.Status, f.Err = FileError, err
+		return
+	}
+	f.Result = result
+
+	switch {
+	case f.Status == FileRenamed:
+		// Keep the rename status, the result holds the content changes
+	case result.HasChanges():
+		f.Status = FileModified
+	default:
+		f.Status = FileUnchanged
+	}
+}
+
+// load reads and parses a file, using formatHint unless it is empty or "auto".
+func (l *dirLoader) load(fsys fs.FS, name, formatHint string) (*tree.Node, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %q: %w", name, err)
+	}
+
+	format := formatHint
+	if format == "" || format == "auto" {
+		format = fileset.Format(name)
+	}
+
+	node, err := parse.ParseContext(context.Background(), data, parse.Format(format), l.opts.Limits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", name, err)
+	}
+	return node, nil
+}
+
+// without returns paths with the first occurrence of p removed.
+func without(paths []string, p string) []string {
+	for i, existing := range paths {
+		if existing == p {
+			return append(paths[:i], paths[i+1:]...)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,223 @@
+package configdiff
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTree creates files under root from a map of relative path to content.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+}
+
+func TestDiffDirs(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir := filepath.Join(tmpDir, "old")
+	newDir := filepath.Join(tmpDir, "new")
+
+	writeTree(t, oldDir, map[string]string{
+		"app.yaml":      "replicas: 2",
+		"same.json":     `{"a": 1}`,
+		"broken.yaml":   "a: 1",
+		"old-only.yaml": "x: 1",
+	})
+	writeTree(t, newDir, map[string]string{
+		"app.yaml":      "replicas: 3",
+		"same.json":     `{"a": 1}`,
+		"broken.yaml":   "a: [unclosed",
+		"new-only.toml": "x = 1",
+	})
+
+	result, err := DiffDirs(oldDir, newDir, DirOptions{Workers: 2})
+	if err != nil {
+		t.Fatalf("DiffDirs() error = %v", err)
+	}
+
+	want := []struct {
+		path   string
+		status FileStatus
+	}{
+		{"app.yaml", FileModified},
+		{"broken.yaml", FileError},
+		{"new-only.toml", FileAdded},
+		{"old-only.yaml", FileRemoved},
+		{"same.json", FileUnchanged},
+	}
+
+	if len(result.Files) != len(want) {
+		t.Fatalf("DiffDirs() returned %d files, want %d", len(result.Files), len(want))
+	}
+	for i, w := range want {
+		f := result.Files[i]
+		if f.Path != w.path || f.Status != w.status {
+			t.Errorf("Files[%d] = %s (%s), want %s (%s)", i, f.Path, f.Status, w.path, w.status)
+		}
+	}
+
+	if result.Files[0].Result == nil || len(result.Files[0].Result.Changes) != 1 {
+		t.Errorf("modified file should carry its diff result")
+	}
+	if result.Files[1].Err == nil {
+		t.Errorf("broken file should carry its parse error")
+	}
+
+	summary := result.Summary()
+	if summary.Compared != 2 || summary.Modified != 1 || summary.Added != 1 || summary.Removed != 1 || summary.Errors != 1 {
+		t.Errorf("Summary() = %+v", summary)
+	}
+	if !result.HasChanges() {
+		t.Error("HasChanges() = false, want true")
+	}
+}
+
+func TestDiffDirs_NotADirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "file.yaml")
+	writeTree(t, tmpDir, map[string]string{"file.yaml": "a: 1"})
+
+	if _, err := DiffDirs(tmpDir, file, DirOptions{}); err == nil {
+		t.Error("DiffDirs() with a file should return an error")
+	}
+}
+
+func TestDiffDirs_Renames(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir := filepath.Join(tmpDir, "old")
+	newDir := filepath.Join(tmpDir, "new")
+
+	deployment := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: %d\n  image: nginx:1\n  port: 80\n"
+
+	writeTree(t, oldDir, map[string]string{
+		"base/app.yaml": fmt.Sprintf(deployment, 2),
+		"settings.yaml": "a: 1\nb: 2\nc: 3\nd: 4\n",
+		"gone.yaml":     "x: 1\n",
+	})
+	writeTree(t, newDir, map[string]string{
+		"apps/app/app.yaml": fmt.Sprintf(deployment, 3),
+		"config.json":       `{"a": 1, "b": 2, "c": 3, "d": 5}`,
+		"fresh.yaml":        "y: 2\n",
+	})
+
+	tests := []struct {
+		name      string
+		threshold int
+		want      map[string]string
+	}{
+		{
+			name:      "default threshold",
+			threshold: 50,
+			want: map[string]string{
+				"apps/app/app.yaml": "base/app.yaml",
+				"config.json":       "settings.yaml",
+			},
+		},
+		{
+			name:      "strict threshold keeps kubernetes identity",
+			threshold: 90,
+			want: map[string]string{
+				"apps/app/app.yaml": "base/app.yaml",
+			},
+		},
+		{
+			name:      "disabled",
+			threshold: 0,
+			want:      map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := DiffDirs(oldDir, newDir, DirOptions{RenameThreshold: tt.threshold})
+			if err != nil {
+				t.Fatalf("DiffDirs() error = %v", err)
+			}
+
+			renamed := 0
+			for _, f := range result.Files {
+				if f.Status != FileRenamed {
+					continue
+				}
+				renamed++
+				if tt.want[f.Path] != f.OldPath {
+					t.Errorf("%s renamed from %s, want %s", f.Path, f.OldPath, tt.want[f.Path])
+				}
+				if f.Result == nil {
+					t.Errorf("renamed file %s should carry its diff result", f.Path)
+				}
+			}
+			if renamed != len(tt.want) {
+				t.Errorf("found %d renames, want %d", renamed, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestDiffDirs_RenamesWithKeyRenames(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir := filepath.Join(tmpDir, "old")
+	newDir := filepath.Join(tmpDir, "new")
+
+	db := "  host: localhost\n  port: 5432\n  user: app\n  password: secret\n  name: app\n"
+	writeTree(t, oldDir, map[string]string{"app.yaml": "db:\n" + db + "other: 1\n"})
+	writeTree(t, newDir, map[string]string{"service.yaml": "database:\n" + db + "other: 1\n"})
+
+	opts := DirOptions{RenameThreshold: 50}
+	opts.KeyRenameThreshold = 50
+	result, err := DiffDirs(oldDir, newDir, opts)
+	if err != nil {
+		t.Fatalf("DiffDirs() error = %v", err)
+	}
+
+	if len(result.Files) != 1 || result.Files[0].Status != FileRenamed {
+		t.Fatalf("Files = %+v, want service.yaml renamed from app.yaml", result.Files)
+	}
+	if got := result.Files[0].Result.Changes; len(got) != 1 || got[0].Type != ChangeTypeMove {
+		t.Errorf("Changes = %+v, want the db key renamed to database", got)
+	}
+}
+
+func TestDiffDirs_RenameCandidateFails(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir := filepath.Join(tmpDir, "old")
+	newDir := filepath.Join(tmpDir, "new")
+
+	// Comparing the two files fails: an element lacks its strict array key
+	writeTree(t, oldDir, map[string]string{
+		"a.yaml":    "items:\n  - {name: x, v: 1}\n  - {v: 2}\n",
+		"same.yaml": "k: 1\n",
+	})
+	writeTree(t, newDir, map[string]string{
+		"b.yaml":    "items:\n  - {name: x, v: 1}\n  - {name: y, v: 2}\n",
+		"same.yaml": "k: 2\n",
+	})
+
+	opts := DirOptions{RenameThreshold: 50}
+	opts.ArraySetKeys = map[string]string{"/items": "name"}
+	opts.StrictArrayKeys = true
+	result, err := DiffDirs(oldDir, newDir, opts)
+	if err != nil {
+		t.Fatalf("DiffDirs() error = %v", err)
+	}
+
+	status := make(map[string]FileStatus)
+	for _, f := range result.Files {
+		status[f.Path] = f.Status
+	}
+	want := map[string]FileStatus{"a.yaml": FileRemoved, "b.yaml": FileAdded, "same.yaml": FileModified}
+	for path, s := range want {
+		if status[path] != s {
+			t.Errorf("%s status = %v, want %v", path, status[path], s)
+		}
+	}
+}
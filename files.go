@@ -0,0 +1,172 @@
+package configdiff
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pfrederiksen/configdiff/internal/fileset"
+	"github.com/pfrederiksen/configdiff/limits"
+)
+
+// DetectFormat returns the format of a configuration document: the one
+// implied by the extension of name ("yaml", "json", "hcl" or "toml"), or
+// else "json" for data starting with { or [ and "yaml" for anything else,
+// as the CLI always has. name may be empty, or "-" for stdin, to detect the
+// format from data alone.
+//
+// Only the first non-blank byte of data is looked at, so detection is cheap
+// whatever the size of data; data that is not valid in the detected format
+// fails when it is parsed. HCL and TOML are only detected by extension.
+func DetectFormat(name string, data []byte) (string, error) {
+	if name != "" && name != "-" {
+		if format := fileset.Format(name); format != "" {
+			return format, nil
+		}
+	}
+
+	trimmed := bytes.TrimLeft(data, " \t\n\r")
+	if len(trimmed) == 0 {
+		return "", fmt.Errorf("unable to detect format of empty input")
+	}
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		return "json", nil
+	}
+	return "yaml", nil
+}
+
+// DiffReaders reads two configuration documents and compares them like
+// DiffBytes. An empty or "auto" format is detected from the content with
+// DetectFormat.
+func DiffReaders(a io.Reader, aFormat string, b io.Reader, bFormat string, opts Options) (*Result, error) {
+	d, err := New(WithOptions(opts))
+	if err != nil {
+		return nil, fmt.Errorf("diff failed: %w", err)
+	}
+	return d.DiffReaders(a, aFormat, b, bFormat)
+}
+
+// DiffFiles reads two configuration files and compares them like
+// DiffBytes, detecting the format of each with DetectFormat. Files larger
+// than opts.Limits.MaxInputBytes are rejected before they are read.
+func DiffFiles(aPath, bPath string, opts Options) (*Result, error) {
+	return DiffFilesContext(context.Background(), aPath, bPath, opts)
+}
+
+// DiffFilesContext is like DiffFiles but stops with ctx.Err() when ctx is
+// done.
+func DiffFilesContext(ctx context.Context, aPath, bPath string, opts Options) (*Result, error) {
+	d, err := New(WithOptions(opts))
+	if err != nil {
+		return nil, fmt.Errorf("diff failed: %w", err)
+	}
+	return d.DiffFilesContext(ctx, aPath, bPath)
+}
+
+// DiffReaders reads two configuration documents and compares them, like
+// the package-level DiffReaders.
+func (d *Differ) DiffReaders(a io.Reader, aFormat string, b io.Reader, bFormat string) (*Result, error) {
+	lim := d.d.Options().Limits
+
+	aData, err := readAll(a, lim)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	if aFormat, err = resolveFormat("", aData, aFormat); err != nil {
+		return nil, err
+	}
+
+	bData, err := readAll(b, lim)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	if bFormat, err = resolveFormat("", bData, bFormat); err != nil {
+		return nil, err
+	}
+
+	return d.DiffBytesContext(context.Background(), aData, aFormat, bData, bFormat)
+}
+
+// DiffFiles reads two configuration files and compares them, like the
+// package-level DiffFiles.
+func (d *Differ) DiffFiles(aPath, bPath string) (*Result, error) {
+	return d.DiffFilesContext(context.Background(), aPath, bPath)
+}
+
+// DiffFilesContext is like DiffFiles but stops with ctx.Err() when ctx is
+// done.
+func (d *Differ) DiffFilesContext(ctx context.Context, aPath, bPath string) (*Result, error) {
+	lim := d.d.Options().Limits
+
+	aData, aFormat, err := readFile(aPath, lim)
+	if err != nil {
+		return nil, err
+	}
+	bData, bFormat, err := readFile(bPath, lim)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.DiffBytesContext(ctx, aData, aFormat, bData, bFormat)
+}
+
+// readFile reads a configuration file and detects its format. Files larger
+// than lim.MaxInputBytes fail with a *limits.Error, before they are read
+// when their size is known.
+func readFile(path string, lim limits.Limits) ([]byte, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read file %q: %w", path, err)
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		if err := limits.Check(limits.InputBytes, info.Size(), lim.WithDefaults().MaxInputBytes); err != nil {
+			return nil, "", fmt.Errorf("%q: %w", path, err)
+		}
+	}
+
+	data, err := readAll(f, lim)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read file %q: %w", path, err)
+	}
+
+	format, err := resolveFormat(path, data, "")
+	if err != nil {
+		return nil, "", err
+	}
+	return data, format, nil
+}
+
+// resolveFormat returns format, or the detected format of data if format
+// is empty or "auto".
+func resolveFormat(name string, data []byte, format string) (string, error) {
+	if format != "" && format != "auto" {
+		return format, nil
+	}
+	detected, err := DetectFormat(name, data)
+	if err != nil && name != "" {
+		return "", fmt.Errorf("%q: %w", name, err)
+	}
+	return detected, err
+}
+
+// readAll reads r, failing with a *limits.Error as soon as it yields more
+// than lim.MaxInputBytes.
+func readAll(r io.Reader, lim limits.Limits) ([]byte, error) {
+	lim = lim.WithDefaults()
+	if lim.MaxInputBytes < 0 {
+		return io.ReadAll(r)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, lim.MaxInputBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if err := limits.Check(limits.InputBytes, int64(len(data)), lim.MaxInputBytes); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,151 @@
+package configdiff
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pfrederiksen/configdiff/limits"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{name: "yaml extension", path: "test.yaml", data: "name: test", want: "yaml"},
+		{name: "yml extension", path: "TEST.YML", data: "name: test", want: "yaml"},
+		{name: "json extension", path: "test.json", data: `{"name": "test"}`, want: "json"},
+		{name: "terraform extension", path: "main.tf", data: `a = 1`, want: "hcl"},
+		{name: "json content", path: "test.txt", data: `{"name": "test"}`, want: "json"},
+		{name: "json array content", path: "", data: `["item1", "item2"]`, want: "json"},
+		{name: "broken json content", path: "-", data: `["a", "b"`, want: "json"},
+		{name: "toml extension", path: "config.toml", data: "[server]\nport = 8080", want: "toml"},
+		{name: "toml content is not sniffed", path: "config", data: "[server]\nport = 8080", want: "json"},
+		{name: "yaml content", path: "test.txt", data: "name: test\nvalue: 123", want: "yaml"},
+		{name: "yaml flow mapping is json", path: "", data: "{name: test}", want: "json"},
+		{name: "anything else is yaml", path: "", data: "port = 8080", want: "yaml"},
+		{name: "stdin ignores name", path: "-", data: "name: test", want: "yaml"},
+		{name: "empty", path: "", data: " \n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectFormat(tt.path, []byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DetectFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DetectFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffReaders(t *testing.T) {
+	tests := []struct {
+		name             string
+		a, aFormat       string
+		b, bFormat       string
+		opts             Options
+		wantChanges      int
+		wantErr          string
+		wantLimitReached bool
+	}{
+		{name: "detected formats", a: `{"port": 80}`, b: "port: 8080", wantChanges: 1},
+		{name: "explicit formats", a: "port: 80", aFormat: "yaml", b: `{"port": 80}`, bFormat: "json"},
+		{name: "auto format", a: "port: 80", aFormat: "auto", b: "port: 81", bFormat: "auto", wantChanges: 1},
+		{name: "undetectable", a: "", b: "port: 80", wantErr: "unable to detect format"},
+		{
+			name:             "input too large",
+			a:                "port: 80",
+			b:                "port: 80 # a longer document",
+			opts:             Options{Limits: limits.Limits{MaxInputBytes: 10}},
+			wantLimitReached: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := DiffReaders(strings.NewReader(tt.a), tt.aFormat, strings.NewReader(tt.b), tt.bFormat, tt.opts)
+			if tt.wantLimitReached {
+				if !errors.Is(err, limits.ErrExceeded) {
+					t.Fatalf("DiffReaders() error = %v, want limit exceeded", err)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DiffReaders() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DiffReaders() error = %v", err)
+			}
+			if len(result.Changes) != tt.wantChanges {
+				t.Errorf("DiffReaders() = %d changes, want %d", len(result.Changes), tt.wantChanges)
+			}
+		})
+	}
+}
+
+func TestDiffFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"old.yaml":   "replicas: 2\nimage: app:1.0",
+		"new.json":   `{"replicas": 3, "image": "app:1.0"}`,
+		"new.config": "replicas: 3\nimage: app:1.0",
+		"new.toml":   "replicas = 3\nimage = \"app:1.0\"",
+	})
+
+	tests := []struct {
+		name    string
+		a, b    string
+		want    int
+		wantErr bool
+	}{
+		{"yaml to json", "old.yaml", "new.json", 1, false},
+		{"content detection", "old.yaml", "new.config", 1, false},
+		{"toml extension", "old.yaml", "new.toml", 1, false},
+		{"missing file", "old.yaml", "missing.yaml", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := DiffFiles(filepath.Join(dir, tt.a), filepath.Join(dir, tt.b), Options{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DiffFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(result.Changes) != tt.want {
+				t.Errorf("DiffFiles() = %d changes, want %d", len(result.Changes), tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffFiles_Limits(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"old.yaml": "replicas: 2",
+		"new.yaml": "replicas: 3\nimage: app:1.0",
+	})
+	oldPath, newPath := filepath.Join(dir, "old.yaml"), filepath.Join(dir, "new.yaml")
+
+	_, err := DiffFiles(oldPath, newPath, Options{Limits: limits.Limits{MaxInputBytes: 16}})
+	var limitErr *limits.Error
+	if !errors.As(err, &limitErr) || limitErr.Limit != limits.InputBytes {
+		t.Errorf("DiffFiles() error = %v, want the input size limit exceeded", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := DiffFilesContext(ctx, oldPath, newPath, Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("DiffFilesContext() error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,196 @@
+// Package archive exposes tarballs and zip files as read-only file systems so
+// that their contents can be compared like directories without extracting
+// them to disk.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/internal/fileset"
+	"github.com/pfrederiksen/configdiff/limits"
+)
+
+// Kind identifies an archive format.
+type Kind string
+
+const (
+	// KindTar is an uncompressed tarball (.tar).
+	KindTar Kind = "tar"
+
+	// KindTarGz is a gzip-compressed tarball (.tar.gz, .tgz), as used by Helm charts.
+	KindTarGz Kind = "tar.gz"
+
+	// KindZip is a zip archive (.zip).
+	KindZip Kind = "zip"
+)
+
+// Detect returns the archive kind implied by the file name, or "" if the name
+// does not look like a supported archive.
+func Detect(name string) Kind {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return KindTarGz
+	case strings.HasSuffix(lower, ".tar"):
+		return KindTar
+	case strings.HasSuffix(lower, ".zip"):
+		return KindZip
+	default:
+		return ""
+	}
+}
+
+// IsArchive reports whether the file name looks like a supported archive.
+func IsArchive(name string) bool {
+	return Detect(name) != ""
+}
+
+// Open opens the archive at path as a file system. Archives are read into
+// memory, up to maxBytes of decompressed files in total (see ReadTar and
+// ReadZip). The returned closer must be called once the file system is no
+// longer used.
+func Open(name string, maxBytes int64) (fs.FS, io.Closer, error) {
+	switch Detect(name) {
+	case KindZip:
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open zip archive %q: %w", name, err)
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open zip archive %q: %w", name, err)
+		}
+		fsys, err := ReadZip(f, info.Size(), maxBytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read zip archive %q: %w", name, err)
+		}
+		return fsys, nopCloser{}, nil
+
+	case KindTar, KindTarGz:
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open archive %q: %w", name, err)
+		}
+		defer f.Close()
+
+		fsys, err := ReadTar(f, Detect(name) == KindTarGz, maxBytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read archive %q: %w", name, err)
+		}
+		return fsys, nopCloser{}, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported archive %q", name)
+	}
+}
+
+// ReadTar reads a tarball into an in-memory file system, decompressing it with
+// gzip first if gzipped is true. Only configuration files and ignore files
+// are kept; other entries, and entries whose names would escape the archive
+// root, are skipped. Reading fails with a *limits.Error once the kept files
+// exceed maxBytes in total, unless maxBytes is 0, and fails if an entry is
+// both a file and the directory of another.
+func ReadTar(r io.Reader, gzipped bool, maxBytes int64) (fs.FS, error) {
+	if gzipped {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	fsys := newMemFS()
+	var total int64
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name, ok := keptName(hdr.Name)
+		if !ok {
+			continue
+		}
+		if err := fsys.read(name, tr, hdr.ModTime, &total, maxBytes); err != nil {
+			return nil, err
+		}
+	}
+
+	return fsys, nil
+}
+
+// ReadZip reads the zip archive r of size bytes into an in-memory file
+// system. It keeps and skips entries like ReadTar, and fails with a
+// *limits.Error once the kept files exceed maxBytes in total once
+// decompressed, unless maxBytes is 0. Entries whose declared size exceeds
+// the limit are rejected before they are decompressed.
+func ReadZip(r io.ReaderAt, size int64, maxBytes int64) (fs.FS, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := newMemFS()
+	var total int64
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		name, ok := keptName(zf.Name)
+		if !ok {
+			continue
+		}
+		if maxBytes > 0 && zf.UncompressedSize64 > uint64(maxBytes-total) {
+			return nil, &limits.Error{Limit: limits.InputBytes, Max: maxBytes}
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		err = fsys.read(name, rc, zf.Modified, &total, maxBytes)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return fsys, nil
+}
+
+// keptName returns the cleaned name of an archive entry, and whether the
+// entry is kept: a configuration or ignore file inside the archive root.
+func keptName(entry string) (string, bool) {
+	name := path.Clean(strings.TrimPrefix(entry, "/"))
+	if !fs.ValidPath(name) || name == "." {
+		return "", false
+	}
+	if !fileset.IsConfigFile(name) && !fileset.IsIgnoreFile(name) {
+		return "", false
+	}
+	return name, true
+}
+
+// nopCloser is returned for archives that are fully read into memory.
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
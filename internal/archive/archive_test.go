@@ -0,0 +1,380 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pfrederiksen/configdiff/limits"
+)
+
+var testFiles = map[string]string{
+	"mychart/Chart.yaml":             "name: mychart\nversion: 1.0.0",
+	"mychart/values.yaml":            "replicas: 2",
+	"mychart/templates/service.yaml": "kind: Service",
+}
+
+// writeTar writes testFiles as a tarball, optionally gzipped, plus entries
+// that must be skipped.
+func writeTar(t *testing.T, path string, gzipped bool) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "mychart/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range testFiles {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Entries escaping the archive root and links are ignored
+	if err := tw.WriteHeader(&tar.Header{Name: "../evil.yaml", Typeflag: tar.TypeReg, Mode: 0644, Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "mychart/link.yaml", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	if gzipped {
+		var gzBuf bytes.Buffer
+		gw := gzip.NewWriter(&gzBuf)
+		if _, err := gw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := gw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data = gzBuf.Bytes()
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeZip writes testFiles as a zip archive.
+func writeZip(t *testing.T, path string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range testFiles {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := []struct {
+		name  string
+		file  string
+		write func(t *testing.T, path string)
+	}{
+		{"tar", "chart.tar", func(t *testing.T, p string) { writeTar(t, p, false) }},
+		{"tgz", "mychart-1.0.0.tgz", func(t *testing.T, p string) { writeTar(t, p, true) }},
+		{"tar.gz", "bundle.tar.gz", func(t *testing.T, p string) { writeTar(t, p, true) }},
+		{"zip", "bundle.zip", writeZip},
+	}
+
+	want := make([]string, 0, len(testFiles))
+	for name := range testFiles {
+		want = append(want, name)
+	}
+	sort.Strings(want)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, tt.file)
+			tt.write(t, path)
+
+			fsys, closer, err := Open(path, 0)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer closer.Close()
+
+			var got []string
+			err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() {
+					got = append(got, p)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("WalkDir() error = %v", err)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("files = %v, want %v", got, want)
+			}
+
+			data, err := fs.ReadFile(fsys, "mychart/values.yaml")
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			if string(data) != testFiles["mychart/values.yaml"] {
+				t.Errorf("ReadFile() = %q, want %q", data, testFiles["mychart/values.yaml"])
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		want Kind
+	}{
+		{"chart.tar", KindTar},
+		{"chart-1.0.0.tgz", KindTarGz},
+		{"bundle.TAR.GZ", KindTarGz},
+		{"bundle.zip", KindZip},
+		{"values.yaml", ""},
+		{"-", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.name); got != tt.want {
+				t.Errorf("Detect(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen_Errors(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	notGzip := filepath.Join(tmpDir, "broken.tgz")
+	if err := os.WriteFile(notGzip, []byte("not gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{notGzip, filepath.Join(tmpDir, "missing.zip"), filepath.Join(tmpDir, "plain.yaml")} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			if _, _, err := Open(path, 0); err == nil {
+				t.Errorf("Open(%q) should fail", path)
+			}
+		})
+	}
+}
+
+// buildTar returns a tarball of regular files, in the order given.
+func buildTar(t *testing.T, files [][2]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f[0], Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f[1]))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// buildZip returns a zip archive of files, given as name and content pairs.
+func buildZip(t *testing.T, files [][2]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadTar(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     [][2]string
+		maxBytes  int64
+		want      []string
+		wantLimit bool
+		wantErr   string
+	}{
+		{
+			name: "only config and ignore files are kept",
+			files: [][2]string{
+				{"chart/values.yaml", "a: 1"},
+				{"chart/README.md", "# chart"},
+				{"chart/logo.png", "\x89PNG"},
+				{"chart/.configdiffignore", "*.json"},
+			},
+			want: []string{"chart/.configdiffignore", "chart/values.yaml"},
+		},
+		{
+			name:     "skipped files do not count",
+			files:    [][2]string{{"values.yaml", "a: 1"}, {"big.bin", strings.Repeat("x", 100)}},
+			maxBytes: 10,
+			want:     []string{"values.yaml"},
+		},
+		{
+			name:      "total size over the limit",
+			files:     [][2]string{{"a.yaml", "a: 1"}, {"b.yaml", "b: 12345678"}},
+			maxBytes:  10,
+			wantLimit: true,
+		},
+		{
+			name:    "file then directory",
+			files:   [][2]string{{"a.yaml", "a: 1"}, {"a.yaml/b.yaml", "b: 1"}},
+			wantErr: `"a.yaml" is both a file and a directory`,
+		},
+		{
+			name:    "directory then file",
+			files:   [][2]string{{"a.yaml/b.yaml", "b: 1"}, {"a.yaml", "a: 1"}},
+			wantErr: `"a.yaml" is both a file and a directory`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, err := ReadTar(bytes.NewReader(buildTar(t, tt.files)), false, tt.maxBytes)
+			switch {
+			case tt.wantLimit:
+				if !errors.Is(err, limits.ErrExceeded) {
+					t.Fatalf("ReadTar() error = %v, want limit exceeded", err)
+				}
+				return
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReadTar() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			case err != nil:
+				t.Fatalf("ReadTar() error = %v", err)
+			}
+
+			var got []string
+			err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					got = append(got, p)
+				}
+				return err
+			})
+			if err != nil {
+				t.Fatalf("WalkDir() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadZip(t *testing.T) {
+	// Compresses to a few hundred bytes, like a zip bomb
+	bomb := "a: " + strings.Repeat("x", 1<<20)
+
+	tests := []struct {
+		name      string
+		files     [][2]string
+		maxBytes  int64
+		want      []string
+		wantLimit bool
+	}{
+		{
+			name: "only config and ignore files are kept",
+			files: [][2]string{
+				{"chart/values.yaml", "a: 1"},
+				{"chart/README.md", "# chart"},
+				{"chart/.configdiffignore", "*.json"},
+			},
+			want: []string{"chart/.configdiffignore", "chart/values.yaml"},
+		},
+		{
+			name:      "oversized entry",
+			files:     [][2]string{{"values.yaml", bomb}},
+			maxBytes:  1024,
+			wantLimit: true,
+		},
+		{
+			name:      "total size over the limit",
+			files:     [][2]string{{"a.yaml", "a: 1"}, {"b.yaml", "b: 12345678"}},
+			maxBytes:  10,
+			wantLimit: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildZip(t, tt.files)
+			fsys, err := ReadZip(bytes.NewReader(data), int64(len(data)), tt.maxBytes)
+			if tt.wantLimit {
+				if !errors.Is(err, limits.ErrExceeded) {
+					t.Fatalf("ReadZip() error = %v, want limit exceeded", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadZip() error = %v", err)
+			}
+
+			var got []string
+			err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					got = append(got, p)
+				}
+				return err
+			})
+			if err != nil {
+				t.Fatalf("WalkDir() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
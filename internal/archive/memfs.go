@@ -0,0 +1,162 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/pfrederiksen/configdiff/limits"
+)
+
+// memFS is a read-only in-memory file system built from archive entries.
+type memFS struct {
+	files map[string]*memEntry
+	dirs  map[string]map[string]*memEntry
+}
+
+// memEntry is a file or directory in a memFS.
+type memEntry struct {
+	name    string
+	data    []byte
+	modTime time.Time
+	dir     bool
+}
+
+func newMemFS() *memFS {
+	return &memFS{
+		files: make(map[string]*memEntry),
+		dirs:  map[string]map[string]*memEntry{".": {}},
+	}
+}
+
+// add stores a file, creating its parent directories as needed. A later
+// file replaces an earlier one of the same name, as when extracting; a name
+// that is both a file and a directory is an error.
+func (m *memFS) add(name string, data []byte, modTime time.Time) error {
+	if _, ok := m.dirs[name]; ok {
+		return fmt.Errorf("%q is both a file and a directory", name)
+	}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if _, ok := m.files[dir]; ok {
+			return fmt.Errorf("%q is both a file and a directory", dir)
+		}
+	}
+
+	m.files[name] = &memEntry{name: path.Base(name), data: data, modTime: modTime}
+
+	child := m.files[name]
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		if _, ok := m.dirs[dir]; !ok {
+			m.dirs[dir] = make(map[string]*memEntry)
+		}
+		m.dirs[dir][child.name] = child
+		if dir == "." {
+			return nil
+		}
+		child = &memEntry{name: path.Base(dir), modTime: modTime, dir: true}
+	}
+}
+
+// read reads the file name from r and adds it. total counts the bytes read
+// into m so far; reading fails with a *limits.Error once it exceeds
+// maxBytes, unless maxBytes is 0, reading at most one byte past the limit
+// whatever the archive declares.
+func (m *memFS) read(name string, r io.Reader, modTime time.Time, total *int64, maxBytes int64) error {
+	if maxBytes > 0 {
+		r = io.LimitReader(r, maxBytes-*total+1)
+	}
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, r)
+	if err != nil {
+		return err
+	}
+	*total += n
+	if err := limits.Check(limits.InputBytes, *total, maxBytes); err != nil {
+		return err
+	}
+	return m.add(name, buf.Bytes(), modTime)
+}
+
+// Open implements fs.FS.
+func (m *memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if f, ok := m.files[name]; ok {
+		return &memFile{entry: f, Reader: bytes.NewReader(f.data)}, nil
+	}
+	if _, ok := m.dirs[name]; ok {
+		return &memFile{entry: &memEntry{name: path.Base(name), dir: true}, Reader: bytes.NewReader(nil)}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (m *memFS) ReadFile(name string) ([]byte, error) {
+	f, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+// Stat implements fs.StatFS.
+func (m *memFS) Stat(name string) (fs.FileInfo, error) {
+	if f, ok := m.files[name]; ok {
+		return f, nil
+	}
+	if _, ok := m.dirs[name]; ok {
+		return &memEntry{name: path.Base(name), dir: true}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir implements fs.ReadDirFS, returning entries sorted by name.
+func (m *memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	children, ok := m.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, c := range children {
+		entries = append(entries, fs.FileInfoToDirEntry(c))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// memFile is an open memFS file.
+type memFile struct {
+	entry *memEntry
+	*bytes.Reader
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.entry, nil }
+func (f *memFile) Close() error               { return nil }
+
+func (f *memFile) Read(p []byte) (int, error) {
+	if f.entry.dir {
+		return 0, &fs.PathError{Op: "read", Path: f.entry.name, Err: fs.ErrInvalid}
+	}
+	return f.Reader.Read(p)
+}
+
+// memEntry implements fs.FileInfo.
+func (e *memEntry) Name() string       { return e.name }
+func (e *memEntry) Size() int64        { return int64(len(e.data)) }
+func (e *memEntry) ModTime() time.Time { return e.modTime }
+func (e *memEntry) IsDir() bool        { return e.dir }
+func (e *memEntry) Sys() interface{}   { return nil }
+
+func (e *memEntry) Mode() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"github.com/pfrederiksen/configdiff"
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/policy"
+)
+
+// ClassifyChanges assigns categories and severities to the changes of
+// result. The result's patch and report are regenerated to carry them.
+func ClassifyChanges(c *policy.Classifier, result *configdiff.Result) error {
+	c.Classify(result.Changes)
+	return refreshResult(result)
+}
+
+// ClassifyDirChanges classifies the changes of every file of a directory
+// comparison. Added and removed files are classified as a change adding or
+// removing the whole document (see fileResult).
+func ClassifyDirChanges(c *policy.Classifier, result *configdiff.DirResult) error {
+	for i := range result.Files {
+		r := fileResult(&result.Files[i])
+		if r == nil {
+			continue
+		}
+		if err := ClassifyChanges(c, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FilterBySeverity returns a copy of result holding only the changes of at
+// least severity min, for display. result is returned as is when min is "".
+func FilterBySeverity(result *configdiff.Result, min diff.Severity) (*configdiff.Result, error) {
+	if min == "" {
+		return result, nil
+	}
+
+	filtered := &configdiff.Result{Changes: []configdiff.Change{}}
+	for _, c := range result.Changes {
+		if c.Severity.Rank() >= min.Rank() {
+			filtered.Changes = append(filtered.Changes, c)
+		}
+	}
+	if err := refreshResult(filtered); err != nil {
+		return nil, err
+	}
+	return filtered, nil
+}
+
+// FilterDirBySeverity applies FilterBySeverity to every compared file of a
+// directory comparison.
+func FilterDirBySeverity(result *configdiff.DirResult, min diff.Severity) (*configdiff.DirResult, error) {
+	if min == "" {
+		return result, nil
+	}
+
+	filtered := &configdiff.DirResult{Files: make([]configdiff.FileResult, len(result.Files))}
+	for i, f := range result.Files {
+		if f.Result != nil {
+			r, err := FilterBySeverity(f.Result, min)
+			if err != nil {
+				return nil, err
+			}
+			f.Result = r
+		}
+		filtered.Files[i] = f
+	}
+	return filtered, nil
+}
@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff"
+	"github.com/pfrederiksen/configdiff/changeset"
+)
+
+// dirJSON is the JSON document produced for a directory comparison, with
+// the policy member requested
+type dirJSON struct {
+	*changeset.DirDocument
+	Policy *policyJSON `json:"policy,omitempty"`
+}
+
+// FormatDirOutput formats a directory comparison according to the specified
+// options. Text formats render each file under its own header followed by a
+// summary line; json and patch produce a single document for the whole tree.
+// Policy violations of all files are reported once, after the files.
+func FormatDirOutput(result *configdiff.DirResult, opts OutputOptions) (string, error) {
+	switch opts.Format {
+	case "json", "patch":
+		return formatDirJSON(result, opts)
+	case "git-diff":
+		output, err := formatDirGitDiff(result)
+		if err != nil || opts.PolicyFile == "" {
+			return output, err
+		}
+		return output + formatViolations(opts.Violations, opts.PolicyFile, "# "), nil
+	}
+
+	var b strings.Builder
+	for _, f := range result.Files {
+		switch f.Status {
+		case configdiff.FileAdded:
+			fmt.Fprintf(&b, "\n+++ %s (added)%s\n", f.Path, fileTag(f))
+
+		case configdiff.FileRemoved:
+			fmt.Fprintf(&b, "\n--- %s (removed)%s\n", f.Path, fileTag(f))
+
+		case configdiff.FileError:
+			fmt.Fprintf(&b, "\n=== %s ===\n", f.Path)
+			fmt.Fprintf(&b, "Error: %v\n", f.Err)
+
+		default:
+			if f.Status == configdiff.FileRenamed {
+				fmt.Fprintf(&b, "\n=== %s → %s (renamed, %d%% similar) ===\n",
+					f.OldPath, f.Path, f.SimilarityPercent())
+			} else {
+				fmt.Fprintf(&b, "\n=== %s ===\n", f.Path)
+			}
+
+			fileOpts := opts
+			fileOpts.OldFile = f.OldPath
+			fileOpts.NewFile = f.Path
+			fileOpts.Violations = nil
+			fileOpts.PolicyFile = ""
+			output, err := FormatOutput(f.Result, fileOpts)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(output)
+			b.WriteString("\n")
+		}
+	}
+
+	s := result.Summary()
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "Summary: %d files compared", s.Compared)
+	if s.Renamed > 0 {
+		fmt.Fprintf(&b, ", %d renamed", s.Renamed)
+	}
+	fmt.Fprintf(&b, ", %d added, %d removed", s.Added, s.Removed)
+	if s.Errors > 0 {
+		fmt.Fprintf(&b, ", %d errors", s.Errors)
+	}
+	b.WriteString("\n")
+
+	if opts.PolicyFile != "" {
+		b.WriteString("\n")
+		b.WriteString(formatViolations(opts.Violations, opts.PolicyFile, ""))
+	}
+
+	return b.String(), nil
+}
+
+// fileTag returns the severity and category classifiers gave an added or
+// removed file, e.g. " [warning, deleted]", or "" for none.
+func fileTag(f configdiff.FileResult) string {
+	if f.Result == nil || len(f.Result.Changes) != 1 {
+		return ""
+	}
+
+	c := f.Result.Changes[0]
+	var parts []string
+	if c.Severity != "" {
+		parts = append(parts, string(c.Severity))
+	}
+	if c.Category != "" {
+		parts = append(parts, c.Category)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(parts, ", ") + "]"
+}
+
+// formatDirJSON renders a directory comparison as one JSON document, with
+// either the changes or the patch operations of each file
+func formatDirJSON(result *configdiff.DirResult, opts OutputOptions) (string, error) {
+	operations := opts.Format == "patch"
+	s := result.Summary()
+	doc := dirJSON{DirDocument: &changeset.DirDocument{
+		SchemaVersion: changeset.SchemaVersion,
+		Files:         make([]changeset.File, 0, len(result.Files)),
+		Summary: changeset.DirSummary{
+			Compared: s.Compared,
+			Modified: s.Modified,
+			Added:    s.Added,
+			Removed:  s.Removed,
+			Renamed:  s.Renamed,
+			Errors:   s.Errors,
+		},
+	}}
+
+	for _, f := range result.Files {
+		entry := changeset.File{
+			Path:       f.Path,
+			Status:     string(f.Status),
+			Similarity: f.Similarity,
+		}
+		if f.Status == configdiff.FileRenamed {
+			entry.OldPath = f.OldPath
+		}
+		if f.Err != nil {
+			entry.Error = f.Err.Error()
+		}
+		if f.Result != nil {
+			if operations {
+				entry.Operations = f.Result.Patch.Operations
+			} else {
+				changes, err := changeset.FromChanges(f.Result.Changes)
+				if err != nil {
+					return "", fmt.Errorf("%s: %w", f.Path, err)
+				}
+				entry.Changes = changes.Changes
+			}
+		}
+		doc.Files = append(doc.Files, entry)
+	}
+
+	if opts.PolicyFile != "" {
+		p := policyDocument(opts.Violations, opts.PolicyFile)
+		doc.Policy = &p
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal directory result to JSON: %w", err)
+	}
+	return string(data), nil
+}
+
+// formatDirGitDiff renders a directory comparison as a multi-file git diff
+func formatDirGitDiff(result *configdiff.DirResult) (string, error) {
+	var b strings.Builder
+	for _, f := range result.Files {
+		switch f.Status {
+		case configdiff.FileAdded:
+			fmt.Fprintf(&b, "diff --configdiff a/%s b/%s\nnew file\n", f.Path, f.Path)
+
+		case configdiff.FileRemoved:
+			fmt.Fprintf(&b, "diff --configdiff a/%s b/%s\ndeleted file\n", f.Path, f.Path)
+
+		case configdiff.FileError:
+			fmt.Fprintf(&b, "diff --configdiff a/%s b/%s\n# error: %v\n", f.Path, f.Path, f.Err)
+
+		case configdiff.FileRenamed:
+			fmt.Fprintf(&b, "diff --configdiff a/%s b/%s\n", f.OldPath, f.Path)
+			fmt.Fprintf(&b, "similarity index %d%%\nrename from %s\nrename to %s\n",
+				f.SimilarityPercent(), f.OldPath, f.Path)
+			// Drop the per-file header, it was written above
+			output, err := FormatOutput(f.Result, OutputOptions{Format: "git-diff", OldFile: f.OldPath, NewFile: f.Path})
+			if err != nil {
+				return "", err
+			}
+			if _, body, ok := strings.Cut(output, "\n"); ok {
+				b.WriteString(body)
+			}
+
+		case configdiff.FileModified:
+			output, err := FormatOutput(f.Result, OutputOptions{Format: "git-diff", OldFile: f.Path, NewFile: f.Path})
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(output)
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+)
+
+// elementSelector matches the element selectors of a path, e.g. [0] or
+// [name=web].
+var elementSelector = regexp.MustCompile(`\[[^\]]*\]`)
+
+// InferredKeyReporter returns an OnInferredKey callback that writes each
+// inferred array key to w once, as an --array-key value that pins it.
+// Element selectors in the path become [*], so one line covers the arrays
+// of every element. It is safe for concurrent use.
+func InferredKeyReporter(w io.Writer) func(path, key string) {
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	return func(path, key string) {
+		spec := elementSelector.ReplaceAllString(path, "[*]") + "=" + key
+
+		mu.Lock()
+		defer mu.Unlock()
+		if seen[spec] {
+			return
+		}
+		seen[spec] = true
+		fmt.Fprintf(w, "Inferred array key: --array-key %s\n", spec)
+	}
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInferredKeyReporter(t *testing.T) {
+	var buf bytes.Buffer
+	report := InferredKeyReporter(&buf)
+
+	report("/spec/containers", "name")
+	report("/spec/containers[name=web]/ports", "containerPort")
+	report("/spec/containers[name=db]/ports", "containerPort")
+	report("/items[0]/env", "name")
+
+	want := "Inferred array key: --array-key /spec/containers=name\n" +
+		"Inferred array key: --array-key /spec/containers[*]/ports=containerPort\n" +
+		"Inferred array key: --array-key /items[*]/env=name\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
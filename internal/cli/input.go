@@ -4,14 +4,14 @@
 package cli
 
 import (
-	"bytes"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
-	"path/filepath"
-	"strings"
 
-	"github.com/pfrederiksen/configdiff/parse"
+	"github.com/pfrederiksen/configdiff"
+	"github.com/pfrederiksen/configdiff/internal/archive"
+	"github.com/pfrederiksen/configdiff/limits"
 )
 
 // InputSource represents a configuration input (file or stdin)
@@ -26,6 +26,11 @@ func ReadInput(path string, formatHint string) (*InputSource, error) {
 	var data []byte
 	var err error
 
+	// Archives hold many files and are compared like directories
+	if path != "-" && archive.IsArchive(path) {
+		return nil, fmt.Errorf("%q is an archive, it can only be compared with a directory or another archive", path)
+	}
+
 	// Read from stdin or file
 	if path == "-" {
 		data, err = io.ReadAll(os.Stdin)
@@ -42,8 +47,8 @@ func ReadInput(path string, formatHint string) (*InputSource, error) {
 	// Determine format
 	format := formatHint
 	if format == "" || format == "auto" {
-		format = detectFormat(path, data)
-		if format == "" {
+		format, err = configdiff.DetectFormat(path, data)
+		if err != nil {
 			return nil, fmt.Errorf("unable to detect format for %q\nHint: Specify format explicitly with --format", path)
 		}
 	}
@@ -55,45 +60,28 @@ func ReadInput(path string, formatHint string) (*InputSource, error) {
 	}, nil
 }
 
-// detectFormat attempts to detect the configuration format
-func detectFormat(path string, data []byte) string {
-	// First, try to detect from file extension
-	if path != "-" {
-		ext := strings.ToLower(filepath.Ext(path))
-		switch ext {
-		case ".yaml", ".yml":
-			return "yaml"
-		case ".json":
-			return "json"
-		case ".hcl", ".tf":
-			return "hcl"
-		case ".toml":
-			return "toml"
-		}
-	}
-
-	// Try to detect from content
-	return detectFromContent(data)
+// IsArchive reports whether path names a supported archive
+// (.tar, .tar.gz, .tgz or .zip)
+func IsArchive(path string) bool {
+	return path != "-" && archive.IsArchive(path)
 }
 
-// detectFromContent attempts to detect format from content
-func detectFromContent(data []byte) string {
-	// Trim leading whitespace
-	trimmed := bytes.TrimLeft(data, " \t\n\r")
-	if len(trimmed) == 0 {
-		return ""
+// OpenTree opens a directory or archive as a read-only file system for
+// recursive comparison. Archives are read in place without being extracted;
+// tarballs are read into memory, so their configuration files are capped at
+// the default input size limit in total.
+// The returned closer must be called when the file system is no longer used.
+func OpenTree(path string) (fs.FS, io.Closer, error) {
+	if IsArchive(path) {
+		return archive.Open(path, limits.Default().MaxInputBytes)
 	}
 
-	// JSON starts with { or [
-	if trimmed[0] == '{' || trimmed[0] == '[' {
-		return "json"
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	// Try parsing as YAML (most permissive)
-	// YAML is the default fallback since it's the most common
-	if _, err := parse.ParseYAML(data); err == nil {
-		return "yaml"
+	if !info.IsDir() {
+		return nil, nil, fmt.Errorf("%q is not a directory or archive", path)
 	}
-
-	return ""
+	return os.DirFS(path), io.NopCloser(nil), nil
 }
@@ -36,6 +36,12 @@ func TestReadInput(t *testing.T) {
 			wantFormat: "json",
 			wantErr:    false,
 		},
+		{
+			name:       "archive",
+			path:       filepath.Join(tmpDir, "chart-1.0.0.tgz"),
+			formatHint: "auto",
+			wantErr:    true,
+		},
 		{
 			name:       "non-existent file",
 			path:       "/nonexistent/file.yaml",
@@ -63,83 +69,3 @@ func TestReadInput(t *testing.T) {
 	}
 }
 
-func TestDetectFormat(t *testing.T) {
-	tests := []struct {
-		name    string
-		path    string
-		data    []byte
-		want    string
-	}{
-		{
-			name: "yaml extension",
-			path: "test.yaml",
-			data: []byte("name: test"),
-			want: "yaml",
-		},
-		{
-			name: "json extension",
-			path: "test.json",
-			data: []byte(`{"name": "test"}`),
-			want: "json",
-		},
-		{
-			name: "json content",
-			path: "test.txt",
-			data: []byte(`{"name": "test"}`),
-			want: "json",
-		},
-		{
-			name: "yaml content",
-			path: "test.txt",
-			data: []byte("name: test\nvalue: 123"),
-			want: "yaml",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := detectFormat(tt.path, tt.data)
-			if got != tt.want {
-				t.Errorf("detectFormat() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestDetectFromContent(t *testing.T) {
-	tests := []struct {
-		name string
-		data []byte
-		want string
-	}{
-		{
-			name: "json object",
-			data: []byte(`{"key": "value"}`),
-			want: "json",
-		},
-		{
-			name: "json array",
-			data: []byte(`["item1", "item2"]`),
-			want: "json",
-		},
-		{
-			name: "yaml",
-			data: []byte("key: value\nother: test"),
-			want: "yaml",
-		},
-		{
-			name: "empty",
-			data: []byte(""),
-			want: "",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := detectFromContent(tt.data)
-			if got != tt.want {
-				t.Errorf("detectFromContent() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
@@ -2,42 +2,68 @@ package cli
 
 import (
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/pfrederiksen/configdiff"
+	"github.com/pfrederiksen/configdiff/diff"
 	"github.com/pfrederiksen/configdiff/internal/config"
+	"github.com/pfrederiksen/configdiff/internal/fileset"
+	"github.com/pfrederiksen/configdiff/limits"
+	"github.com/pfrederiksen/configdiff/schema"
 )
 
 // CLIOptions holds all CLI flag values
 type CLIOptions struct {
-	OldFile        string
-	NewFile        string
-	Format         string
-	OldFormat      string
-	NewFormat      string
-	IgnorePaths    []string
-	ArrayKeys      []string
-	NumericStrings bool
-	BoolStrings    bool
-	StableOrder    bool
-	OutputFormat   string
-	NoColor        bool
-	MaxValueLength int
-	Quiet          bool
-	ExitCode       bool
+	OldFile         string
+	NewFile         string
+	Format          string
+	OldFormat       string
+	NewFormat       string
+	IgnorePaths     []string
+	ArrayKeys       []string
+	Normalize       []string
+	Tolerance       []string
+	NullAbsent      bool
+	EmptyAbsent     bool
+	EmptyStringNull bool
+	Equivalences    map[string][]string
+	Defaults        []string
+	Schema          string
+	Policy          string
+	StrictArrayKeys bool
+	UnorderedArrays []string
+	InferArrayKeys  bool
+	KeyRenames      int
+	Classify        []string
+	MinSeverity     string
+	NumericStrings  bool
+	BoolStrings     bool
+	StableOrder     bool
+	OutputFormat    string
+	NoColor         bool
+	MaxValueLength  int
+	Quiet           bool
+	ExitCode        bool
+	Include         []string
+	Exclude         []string
+	UseGitignore    bool
 }
 
 // ToLibraryOptions converts CLI options to configdiff library options
 func (c *CLIOptions) ToLibraryOptions() (configdiff.Options, error) {
 	// Parse array keys from "path=key" format
 	arraySetKeys := make(map[string]string)
-	for _, keySpec := range c.ArrayKeys {
-		parts := strings.SplitN(keySpec, "=", 2)
-		if len(parts) != 2 {
+	for _, keySpec := range joinKeyFields(joinSelectors(c.ArrayKeys)) {
+		// Split at the last "=", selectors like [name=web] may contain one
+		i := strings.LastIndex(keySpec, "=")
+		if i <= 0 || i == len(keySpec)-1 {
 			return configdiff.Options{}, fmt.Errorf("invalid array-key format %q, expected path=key", keySpec)
 		}
-		path := parts[0]
-		key := parts[1]
+		path := keySpec[:i]
+		key := keySpec[i+1:]
 
 		// Ensure path starts with /
 		if !strings.HasPrefix(path, "/") {
@@ -47,17 +73,231 @@ func (c *CLIOptions) ToLibraryOptions() (configdiff.Options, error) {
 		arraySetKeys[path] = key
 	}
 
+	// Parse normalizers from "path=kind" format
+	var normalizers []configdiff.NormalizerRule
+	for _, spec := range joinSelectors(c.Normalize) {
+		i := strings.LastIndex(spec, "=")
+		if i < 0 {
+			return configdiff.Options{}, fmt.Errorf("invalid normalize format %q, expected path=kind", spec)
+		}
+		path := spec[:i]
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+
+		normalizer, err := diff.LookupNormalizer(spec[i+1:])
+		if err != nil {
+			return configdiff.Options{}, err
+		}
+		normalizers = append(normalizers, configdiff.NormalizerRule{Path: path, Normalizer: normalizer})
+	}
+
+	// Parse numeric tolerances from "value" or "path=value" format
+	var tolerance configdiff.Tolerance
+	var tolerances []configdiff.ToleranceRule
+	for _, spec := range joinSelectors(c.Tolerance) {
+		path, tol, err := parseTolerance(spec)
+		if err != nil {
+			return configdiff.Options{}, err
+		}
+		if path == "" {
+			tolerance = mergeTolerance(tolerance, tol)
+			continue
+		}
+
+		merged := false
+		for i := range tolerances {
+			if tolerances[i].Path == path {
+				tolerances[i].Tolerance = mergeTolerance(tolerances[i].Tolerance, tol)
+				merged = true
+			}
+		}
+		if !merged {
+			tolerances = append(tolerances, configdiff.ToleranceRule{Path: path, Tolerance: tol})
+		}
+	}
+
+	// Parse per-path equivalences, longest (most specific) patterns first
+	paths := make([]string, 0, len(c.Equivalences))
+	for path := range c.Equivalences {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) > len(paths[j])
+		}
+		return paths[i] < paths[j]
+	})
+
+	var equivalences []configdiff.EquivalenceRule
+	for _, path := range paths {
+		eq, err := parseEquivalence(c.Equivalences[path])
+		if err != nil {
+			return configdiff.Options{}, fmt.Errorf("invalid equivalences for %s: %w", path, err)
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		equivalences = append(equivalences, configdiff.EquivalenceRule{Path: path, Equivalence: eq})
+	}
+
+	// Load schema defaults from files or the built-in preset
+	var defaults diff.Defaults
+	if len(c.Defaults) > 0 {
+		set, err := schema.LoadAll(c.Defaults)
+		if err != nil {
+			return configdiff.Options{}, err
+		}
+		defaults = set
+	}
+
 	return configdiff.Options{
-		IgnorePaths:  c.IgnorePaths,
-		ArraySetKeys: arraySetKeys,
+		IgnorePaths:     joinSelectors(c.IgnorePaths),
+		ArraySetKeys:    arraySetKeys,
+		StrictArrayKeys: c.StrictArrayKeys,
+		UnorderedArrays: joinSelectors(c.UnorderedArrays),
+		InferArrayKeys:  c.InferArrayKeys,
 		Coercions: configdiff.Coercions{
 			NumericStrings: c.NumericStrings,
 			BoolStrings:    c.BoolStrings,
 		},
-		StableOrder: c.StableOrder,
+		Normalizers:      normalizers,
+		ReportNormalized: len(normalizers) > 0,
+		Tolerance:        tolerance,
+		Tolerances:       tolerances,
+		Equivalence: configdiff.Equivalence{
+			NullAbsent:      c.NullAbsent,
+			EmptyAbsent:     c.EmptyAbsent,
+			EmptyStringNull: c.EmptyStringNull,
+		},
+		Equivalences:       equivalences,
+		Defaults:           defaults,
+		KeyRenameThreshold: c.KeyRenames,
+		Limits:             limits.Default(),
+		StableOrder:        c.StableOrder,
 	}, nil
 }
 
+// joinSelectors rejoins selectors whose predicates were split by the
+// comma-separated flag parser: "/items[name=a", "port=80]" becomes
+// "/items[name=a,port=80]".
+func joinSelectors(specs []string) []string {
+	var joined []string
+	open := false
+	for _, spec := range specs {
+		if open {
+			joined[len(joined)-1] += "," + spec
+		} else {
+			joined = append(joined, spec)
+		}
+		open = unclosedBracket(joined[len(joined)-1])
+	}
+	return joined
+}
+
+// unclosedBracket reports whether s opens a [ that it does not close,
+// ignoring escaped brackets and brackets quoted within predicates.
+func unclosedBracket(s string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case depth > 0 && (c == '"' || c == '\''):
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']' && depth > 0:
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// joinKeyFields rejoins composite keys split by the comma-separated flag
+// parser: "/ports=containerPort", "protocol" becomes
+// "/ports=containerPort,protocol".
+func joinKeyFields(specs []string) []string {
+	var joined []string
+	for _, spec := range specs {
+		if len(joined) > 0 && !strings.Contains(spec, "=") {
+			joined[len(joined)-1] += "," + spec
+			continue
+		}
+		joined = append(joined, spec)
+	}
+	return joined
+}
+
+// parseTolerance parses a tolerance of the form "value" or "path=value", where
+// value is an absolute difference such as 0.001 or a relative one such as 1%.
+func parseTolerance(spec string) (string, configdiff.Tolerance, error) {
+	var path string
+	value := spec
+	if i := strings.LastIndex(spec, "="); i >= 0 {
+		path, value = spec[:i], spec[i+1:]
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+
+	var tol configdiff.Tolerance
+	percent := strings.HasSuffix(value, "%")
+	n, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return "", tol, fmt.Errorf("invalid tolerance %q, expected [path=]value or [path=]percent%%", spec)
+	}
+
+	if percent {
+		tol.Relative = n / 100
+	} else {
+		tol.Absolute = n
+	}
+	return path, tol, nil
+}
+
+// parseEquivalence parses equivalence names: null-absent, empty-absent and
+// empty-string-null.
+func parseEquivalence(names []string) (configdiff.Equivalence, error) {
+	var eq configdiff.Equivalence
+	for _, name := range names {
+		switch name {
+		case "null-absent":
+			eq.NullAbsent = true
+		case "empty-absent":
+			eq.EmptyAbsent = true
+		case "empty-string-null":
+			eq.EmptyStringNull = true
+		default:
+			return eq, fmt.Errorf("unknown equivalence %q (valid: null-absent, empty-absent, empty-string-null)", name)
+		}
+	}
+	return eq, nil
+}
+
+// mergeTolerance combines the absolute and relative parts of two tolerances.
+func mergeTolerance(a, b configdiff.Tolerance) configdiff.Tolerance {
+	return configdiff.Tolerance{
+		Absolute: math.Max(a.Absolute, b.Absolute),
+		Relative: math.Max(a.Relative, b.Relative),
+	}
+}
+
+// FileSetOptions returns the file selection options for directory comparison
+func (c *CLIOptions) FileSetOptions() fileset.Options {
+	return fileset.Options{
+		Include:      c.Include,
+		Exclude:      c.Exclude,
+		UseGitignore: c.UseGitignore,
+	}
+}
+
 // GetOldFormat returns the format for the old file
 func (c *CLIOptions) GetOldFormat() string {
 	if c.OldFormat != "" {
@@ -74,6 +314,16 @@ func (c *CLIOptions) GetNewFormat() string {
 	return c.Format
 }
 
+// GetMinSeverity returns the lowest severity of changes to show, or "" to
+// show all changes. Validate checks the value beforehand.
+func (c *CLIOptions) GetMinSeverity() diff.Severity {
+	if c.MinSeverity == "" {
+		return ""
+	}
+	s, _ := diff.ParseSeverity(c.MinSeverity)
+	return s
+}
+
 // ApplyConfigDefaults applies configuration file defaults to unset CLI options.
 // CLI flags always take precedence over config file values.
 func (c *CLIOptions) ApplyConfigDefaults(cfg *config.Config) {
@@ -105,6 +355,27 @@ func (c *CLIOptions) ApplyConfigDefaults(cfg *config.Config) {
 		}
 	}
 
+	// Merge unordered arrays (config file + CLI)
+	c.UnorderedArrays = append(c.UnorderedArrays, cfg.UnorderedArrays...)
+
+	// Merge normalizers (config file + CLI)
+	for path, kind := range cfg.Normalize {
+		c.Normalize = append(c.Normalize, fmt.Sprintf("%s=%s", path, kind))
+	}
+
+	// Merge numeric tolerances (config file + CLI)
+	c.Tolerance = append(c.Tolerance, cfg.Tolerance...)
+
+	// Merge schema defaults (config file + CLI)
+	c.Defaults = append(c.Defaults, cfg.Defaults...)
+
+	// Merge classification rules (config file + CLI)
+	c.Classify = append(c.Classify, cfg.Classify...)
+
+	// Merge include/exclude globs (config file + CLI)
+	c.Include = append(c.Include, cfg.Include...)
+	c.Exclude = append(c.Exclude, cfg.Exclude...)
+
 	// Apply config defaults only if CLI flag wasn't set
 	// For bool flags, we need to check if they were explicitly set
 	// For now, we'll apply config if the CLI value is false (default)
@@ -120,6 +391,39 @@ func (c *CLIOptions) ApplyConfigDefaults(cfg *config.Config) {
 	if !c.NoColor && cfg.NoColor {
 		c.NoColor = cfg.NoColor
 	}
+	if !c.NullAbsent && cfg.NullEqualsAbsent {
+		c.NullAbsent = cfg.NullEqualsAbsent
+	}
+	if !c.EmptyAbsent && cfg.EmptyEqualsAbsent {
+		c.EmptyAbsent = cfg.EmptyEqualsAbsent
+	}
+	if !c.EmptyStringNull && cfg.EmptyStringEqualsNull {
+		c.EmptyStringNull = cfg.EmptyStringEqualsNull
+	}
+	if len(cfg.Equivalences) > 0 && c.Equivalences == nil {
+		c.Equivalences = cfg.Equivalences
+	}
+	if c.Schema == "" && cfg.Schema != "" {
+		c.Schema = cfg.Schema
+	}
+	if c.Policy == "" && cfg.Policy != "" {
+		c.Policy = cfg.Policy
+	}
+	if c.MinSeverity == "" && cfg.MinSeverity != "" {
+		c.MinSeverity = cfg.MinSeverity
+	}
+	if !c.StrictArrayKeys && cfg.StrictArrayKeys {
+		c.StrictArrayKeys = cfg.StrictArrayKeys
+	}
+	if !c.InferArrayKeys && cfg.InferArrayKeys {
+		c.InferArrayKeys = cfg.InferArrayKeys
+	}
+	if c.KeyRenames == 0 && cfg.KeyRenames != 0 {
+		c.KeyRenames = cfg.KeyRenames
+	}
+	if !c.UseGitignore && cfg.Gitignore {
+		c.UseGitignore = cfg.Gitignore
+	}
 
 	// Apply string defaults if not set
 	if (c.OutputFormat == "" || c.OutputFormat == "report") && cfg.OutputFormat != "" {
@@ -166,5 +470,22 @@ func (c *CLIOptions) Validate() error {
 		return fmt.Errorf("invalid new-format %q, must be one of: auto, yaml, json, hcl, toml", c.NewFormat)
 	}
 
+	// Validate key rename threshold
+	if c.KeyRenames < 0 || c.KeyRenames > 100 {
+		return fmt.Errorf("invalid --find-key-renames value %d, must be between 0 and 100", c.KeyRenames)
+	}
+
+	// Validate minimum severity
+	if c.MinSeverity != "" {
+		if _, err := diff.ParseSeverity(c.MinSeverity); err != nil {
+			return fmt.Errorf("invalid min-severity: %w", err)
+		}
+	}
+
+	// Validate directory include/exclude globs
+	if err := c.FileSetOptions().Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/pfrederiksen/configdiff/internal/config"
+	"github.com/pfrederiksen/configdiff/limits"
 )
 
 func TestCLIOptions_ToLibraryOptions(t *testing.T) {
@@ -29,6 +30,52 @@ func TestCLIOptions_ToLibraryOptions(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "normalizers",
+			opts: CLIOptions{
+				Normalize: []string{"/spec/podCIDR=cidr", "endpoint=URL"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown normalizer",
+			opts: CLIOptions{
+				Normalize: []string{"/endpoint=hostname"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid normalize format",
+			opts: CLIOptions{
+				Normalize: []string{"/endpoint"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "equivalences",
+			opts: CLIOptions{
+				NullAbsent: true,
+				Equivalences: map[string][]string{
+					"/metadata/*": {"null-absent", "empty-absent"},
+					"/spec":       {"empty-string-null"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown equivalence",
+			opts: CLIOptions{
+				Equivalences: map[string][]string{"/spec": {"zero-absent"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "composite array key split by the flag parser",
+			opts: CLIOptions{
+				ArrayKeys: []string{"/ports=containerPort", "protocol", "/volumes=name"},
+			},
+			wantErr: false,
+		},
 		{
 			name: "invalid array key format",
 			opts: CLIOptions{
@@ -46,12 +93,30 @@ func TestCLIOptions_ToLibraryOptions(t *testing.T) {
 				return
 			}
 			if err == nil {
+				if libOpts.Limits != limits.Default() {
+					t.Errorf("Limits = %+v, want limits.Default()", libOpts.Limits)
+				}
 				if libOpts.StableOrder != tt.opts.StableOrder {
 					t.Errorf("StableOrder = %v, want %v", libOpts.StableOrder, tt.opts.StableOrder)
 				}
 				if libOpts.Coercions.NumericStrings != tt.opts.NumericStrings {
 					t.Errorf("NumericStrings = %v, want %v", libOpts.Coercions.NumericStrings, tt.opts.NumericStrings)
 				}
+				if len(libOpts.Normalizers) != len(tt.opts.Normalize) {
+					t.Errorf("Normalizers = %d rules, want %d", len(libOpts.Normalizers), len(tt.opts.Normalize))
+				}
+				if libOpts.ReportNormalized != (len(tt.opts.Normalize) > 0) {
+					t.Errorf("ReportNormalized = %v", libOpts.ReportNormalized)
+				}
+				if libOpts.Equivalence.NullAbsent != tt.opts.NullAbsent {
+					t.Errorf("Equivalence.NullAbsent = %v, want %v", libOpts.Equivalence.NullAbsent, tt.opts.NullAbsent)
+				}
+				if len(libOpts.Equivalences) != len(tt.opts.Equivalences) {
+					t.Errorf("Equivalences = %d rules, want %d", len(libOpts.Equivalences), len(tt.opts.Equivalences))
+				}
+				if len(libOpts.Equivalences) > 1 && libOpts.Equivalences[0].Path != "/metadata/*" {
+					t.Errorf("Equivalences[0].Path = %s, want the longest pattern first", libOpts.Equivalences[0].Path)
+				}
 			}
 		})
 	}
@@ -143,6 +208,24 @@ func TestCLIOptions_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid min severity",
+			opts: CLIOptions{
+				Format:       "yaml",
+				OutputFormat: "report",
+				MinSeverity:  "warn",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid min severity",
+			opts: CLIOptions{
+				Format:       "yaml",
+				OutputFormat: "report",
+				MinSeverity:  "fatal",
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid old format",
 			opts: CLIOptions{
@@ -364,3 +447,80 @@ func containsAll(actual, expected []string) bool {
 	}
 	return len(expectedMap) == 0
 }
+
+func TestParseTolerance(t *testing.T) {
+	tests := []struct {
+		spec     string
+		wantPath string
+		wantAbs  float64
+		wantRel  float64
+		wantErr  bool
+	}{
+		{spec: "0.001", wantAbs: 0.001},
+		{spec: "1%", wantRel: 0.01},
+		{spec: "/metrics/latency=5", wantPath: "/metrics/latency", wantAbs: 5},
+		{spec: "spec/items[name=a]/weight=2.5%", wantPath: "/spec/items[name=a]/weight", wantRel: 0.025},
+		{spec: "-1", wantErr: true},
+		{spec: "/a=abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			path, tol, err := parseTolerance(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTolerance() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if path != tt.wantPath || tol.Absolute != tt.wantAbs || tol.Relative != tt.wantRel {
+				t.Errorf("parseTolerance() = %q, %+v, want %q, {%v %v}", path, tol, tt.wantPath, tt.wantAbs, tt.wantRel)
+			}
+		})
+	}
+}
+
+func TestJoinSelectors(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []string
+		want  []string
+	}{
+		{name: "separate selectors", specs: []string{"/a", "/b[0]"}, want: []string{"/a", "/b[0]"}},
+		{name: "split predicates", specs: []string{"/items[name=a", "port=80]", "/b"}, want: []string{"/items[name=a,port=80]", "/b"}},
+		{name: "three predicates", specs: []string{"/x[a=1", "b=2", "c=3]/y"}, want: []string{"/x[a=1,b=2,c=3]/y"}},
+		{name: "quoted bracket", specs: []string{`/x[name="]"`, "port=80]"}, want: []string{`/x[name="]",port=80]`}},
+		{name: "escaped bracket", specs: []string{`/a\[b`, "/c"}, want: []string{`/a\[b`, "/c"}},
+		{name: "path=value specs", specs: []string{"/items[name=a", "port=80]/cidr=cidr"}, want: []string{"/items[name=a,port=80]/cidr=cidr"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := joinSelectors(tt.specs)
+			if len(got) != len(tt.want) || !containsAll(got, tt.want) {
+				t.Errorf("joinSelectors() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinKeyFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []string
+		want  []string
+	}{
+		{name: "single keys", specs: []string{"/a=name", "/b=id"}, want: []string{"/a=name", "/b=id"}},
+		{name: "composite key", specs: []string{"/ports=containerPort", "protocol", "/b=id"}, want: []string{"/ports=containerPort,protocol", "/b=id"}},
+		{name: "leading field kept", specs: []string{"protocol", "/a=name"}, want: []string{"protocol", "/a=name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := joinKeyFields(tt.specs)
+			if len(got) != len(tt.want) || !containsAll(got, tt.want) {
+				t.Errorf("joinKeyFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
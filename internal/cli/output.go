@@ -1,11 +1,20 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
 
 	"github.com/pfrederiksen/configdiff"
+	"github.com/pfrederiksen/configdiff/changeset"
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/parse"
+	"github.com/pfrederiksen/configdiff/policy"
 	"github.com/pfrederiksen/configdiff/report"
+	"github.com/pfrederiksen/configdiff/schema"
+	"github.com/pfrederiksen/configdiff/tree"
 )
 
 // OutputOptions controls how output is formatted
@@ -13,12 +22,125 @@ type OutputOptions struct {
 	Format         string
 	NoColor        bool
 	MaxValueLength int
-	OldFile        string // For git-diff format
-	NewFile        string // For git-diff format
+	OldFile        string // For git-diff and json formats
+	NewFile        string // For git-diff and json formats
+	OldFormat      string // For json format
+	NewFormat      string // For json format
+
+	// Validation holds schema validation results to include, if any
+	Validation *schema.Validation
+	SchemaFile string
+
+	// Violations holds policy violations to include when PolicyFile is set
+	Violations []policy.Violation
+	PolicyFile string
 }
 
-// FormatOutput formats the diff result according to the specified options
+// FormatOutput formats the diff result according to the specified options.
+// Schema validation results and policy violations are appended as sections
+// to text formats and as "validation" and "policy" members to json and
+// patch output.
 func FormatOutput(result *configdiff.Result, opts OutputOptions) (string, error) {
+	output, err := formatChanges(result, opts)
+	if err != nil {
+		return "", err
+	}
+
+	switch opts.Format {
+	case "json", "patch":
+		return output, nil
+	case "git-diff":
+		return output + formatSections(opts, "# "), nil
+	}
+
+	sections := formatSections(opts, "")
+	if sections == "" {
+		return output, nil
+	}
+	if !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	if !strings.HasSuffix(output, "\n\n") {
+		output += "\n"
+	}
+	return output + sections, nil
+}
+
+// formatSections renders the validation and policy sections requested by
+// opts, each line starting with prefix
+func formatSections(opts OutputOptions, prefix string) string {
+	var sections []string
+	if opts.Validation != nil {
+		sections = append(sections, formatValidation(opts.Validation, opts, prefix))
+	}
+	if opts.PolicyFile != "" {
+		sections = append(sections, formatViolations(opts.Violations, opts.PolicyFile, prefix))
+	}
+
+	separator := "\n"
+	if prefix != "" {
+		separator = ""
+	}
+	return strings.Join(sections, separator)
+}
+
+// hasSections reports whether opts request validation or policy results
+func hasSections(opts OutputOptions) bool {
+	return opts.Validation != nil || opts.PolicyFile != ""
+}
+
+// withSections wraps a json or patch document with the validation and
+// policy members requested by opts, or returns it unchanged
+func withSections(key string, body interface{}, opts OutputOptions) interface{} {
+	if !hasSections(opts) {
+		return body
+	}
+
+	doc := map[string]interface{}{key: body}
+	if opts.Validation != nil {
+		doc["validation"] = validationDocument(opts.Validation, opts.SchemaFile)
+	}
+	if opts.PolicyFile != "" {
+		doc["policy"] = policyDocument(opts.Violations, opts.PolicyFile)
+	}
+	return doc
+}
+
+// changesJSON is the json output: a change set with the validation and
+// policy members requested
+type changesJSON struct {
+	*changeset.Document
+	Validation *validationJSON `json:"validation,omitempty"`
+	Policy     *policyJSON     `json:"policy,omitempty"`
+}
+
+// changesDocument builds the json output for result
+func changesDocument(result *configdiff.Result, opts OutputOptions) (*changesJSON, error) {
+	doc, err := changeset.FromChanges(result.Changes)
+	if err != nil {
+		return nil, err
+	}
+	if opts.OldFile != "" {
+		doc.Old = &changeset.Input{Path: opts.OldFile, Format: opts.OldFormat}
+	}
+	if opts.NewFile != "" {
+		doc.New = &changeset.Input{Path: opts.NewFile, Format: opts.NewFormat}
+	}
+
+	out := &changesJSON{Document: doc}
+	if opts.Validation != nil {
+		v := validationDocument(opts.Validation, opts.SchemaFile)
+		out.Validation = &v
+	}
+	if opts.PolicyFile != "" {
+		p := policyDocument(opts.Violations, opts.PolicyFile)
+		out.Policy = &p
+	}
+	return out, nil
+}
+
+// formatChanges renders the changes of result in the requested format
+func formatChanges(result *configdiff.Result, opts OutputOptions) (string, error) {
 	switch opts.Format {
 	case "report":
 		// Detailed report with values
@@ -38,16 +160,24 @@ func FormatOutput(result *configdiff.Result, opts OutputOptions) (string, error)
 		}), nil
 
 	case "json":
-		// JSON serialized changes
-		data, err := json.MarshalIndent(result.Changes, "", "  ")
+		// Versioned change set, with validation and policy results if any
+		doc, err := changesDocument(result, opts)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.MarshalIndent(doc, "", "  ")
 		if err != nil {
 			return "", fmt.Errorf("failed to marshal changes to JSON: %w", err)
 		}
 		return string(data), nil
 
 	case "patch":
-		// JSON Patch format
-		data, err := result.Patch.ToJSONIndent()
+		// JSON Patch format, with validation and policy results if any
+		var doc interface{} = result.Patch
+		if hasSections(opts) {
+			doc = withSections("operations", result.Patch.Operations, opts)
+		}
+		data, err := json.MarshalIndent(doc, "", "  ")
 		if err != nil {
 			return "", fmt.Errorf("failed to marshal patch to JSON: %w", err)
 		}
@@ -75,5 +205,60 @@ func FormatOutput(result *configdiff.Result, opts OutputOptions) (string, error)
 
 // HasChanges returns true if there are any changes in the result
 func HasChanges(result *configdiff.Result) bool {
-	return len(result.Changes) > 0
+	return result.HasChanges()
+}
+
+// AnyChange reports whether the inputs differ under opts, stopping at the
+// first change instead of computing the whole diff. Values equal after
+// normalization do not count.
+func AnyChange(oldInput, newInput *InputSource, opts configdiff.Options) (bool, error) {
+	oldTree, newTree, err := ParseInputs(oldInput, newInput, opts)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	err = diff.Walk(oldTree, newTree, opts, func(c diff.Change) error {
+		if c.Type == diff.ChangeTypeNormalized {
+			return nil
+		}
+		changed = true
+		return diff.SkipAll
+	})
+	return changed, err
+}
+
+// WriteGitDiff writes the changes between the inputs to w in git-diff
+// format as they are found, instead of once the whole diff is done, and
+// reports whether any change other than a normalized value was found.
+// Changes are written in the order they are found, not sorted by path.
+func WriteGitDiff(w io.Writer, oldInput, newInput *InputSource, opts configdiff.Options, oldFile, newFile string) (bool, error) {
+	oldTree, newTree, err := ParseInputs(oldInput, newInput, opts)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	gw := report.NewGitDiffWriter(w, oldFile, newFile)
+	err = diff.Walk(oldTree, newTree, opts, func(c diff.Change) error {
+		if c.Type != diff.ChangeTypeNormalized {
+			changed = true
+		}
+		return gw.WriteChange(c)
+	})
+	return changed, err
+}
+
+// ParseInputs parses both inputs within opts.Limits, so the trees can be
+// diffed and validated without parsing the documents again.
+func ParseInputs(oldInput, newInput *InputSource, opts configdiff.Options) (*tree.Node, *tree.Node, error) {
+	oldTree, err := parse.ParseContext(context.Background(), oldInput.Data, parse.Format(oldInput.Format), opts.Limits)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse format %s: %w", oldInput.Format, err)
+	}
+	newTree, err := parse.ParseContext(context.Background(), newInput.Data, parse.Format(newInput.Format), opts.Limits)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse format %s: %w", newInput.Format, err)
+	}
+	return oldTree, newTree, nil
 }
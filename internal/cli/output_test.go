@@ -1,12 +1,19 @@
 package cli
 
 import (
+	"bytes"
+	"errors"
 	"strings"
 	"testing"
 
 	"github.com/pfrederiksen/configdiff"
+	"github.com/pfrederiksen/configdiff/changeset"
 	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/limits"
+	"github.com/pfrederiksen/configdiff/parse"
 	"github.com/pfrederiksen/configdiff/patch"
+	"github.com/pfrederiksen/configdiff/policy"
+	"github.com/pfrederiksen/configdiff/schema"
 	"github.com/pfrederiksen/configdiff/tree"
 )
 
@@ -66,7 +73,7 @@ func TestFormatOutput(t *testing.T) {
 			},
 			wantErr: false,
 			check: func(s string) bool {
-				return strings.Contains(s, "\"Type\"") || strings.Contains(s, "\"type\"")
+				return strings.Contains(s, `"schema_version": 1`) && strings.Contains(s, `"type": "modify"`)
 			},
 		},
 		{
@@ -144,3 +151,391 @@ func TestHasChanges(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatDirOutput(t *testing.T) {
+	changes := []diff.Change{
+		{
+			Type:     diff.ChangeTypeModify,
+			Path:     "/replicas",
+			OldValue: tree.NewNumber(2),
+			NewValue: tree.NewNumber(3),
+		},
+	}
+	filePatch, _ := patch.FromChanges(changes)
+
+	result := &configdiff.DirResult{
+		Files: []configdiff.FileResult{
+			{Path: "app.yaml", OldPath: "app.yaml", Status: configdiff.FileModified, Result: &configdiff.Result{Changes: changes, Patch: filePatch}},
+			{Path: "new.yaml", Status: configdiff.FileAdded},
+			{Path: "moved/db.yaml", OldPath: "db.yaml", Status: configdiff.FileRenamed, Similarity: 1, Result: &configdiff.Result{Patch: &patch.Patch{}}},
+		},
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"report", []string{"=== app.yaml ===", "~ /replicas: 2 → 3", "+++ new.yaml (added)", "=== db.yaml → moved/db.yaml (renamed, 100% similar) ===", "Summary: 1 files compared, 1 renamed, 1 added, 0 removed"}},
+		{"compact", []string{"=== app.yaml ===", "/replicas"}},
+		{"stat", []string{"=== app.yaml ===", "1 paths changed"}},
+		{"json", []string{`"path": "app.yaml"`, `"status": "modified"`, `"old_path": "db.yaml"`, `"summary"`}},
+		{"patch", []string{`"operations"`, `"op": "replace"`}},
+		{"git-diff", []string{"diff --configdiff a/app.yaml b/app.yaml", "new file", "rename from db.yaml", "rename to moved/db.yaml"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			output, err := FormatDirOutput(result, OutputOptions{Format: tt.format, NoColor: true})
+			if err != nil {
+				t.Fatalf("FormatDirOutput() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("FormatDirOutput() output missing %q, got:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatDirOutput_JSONSchema(t *testing.T) {
+	validator, err := schema.CompileValidator(changeset.JSONSchema)
+	if err != nil {
+		t.Fatalf("CompileValidator() error = %v", err)
+	}
+
+	changes := []diff.Change{{Type: diff.ChangeTypeModify, Path: "/replicas", OldValue: tree.NewNumber(2), NewValue: tree.NewNumber(3)}}
+	filePatch, _ := patch.FromChanges(changes)
+	result := &configdiff.DirResult{
+		Files: []configdiff.FileResult{
+			{Path: "app.yaml", Status: configdiff.FileModified, Result: &configdiff.Result{Changes: changes, Patch: filePatch}},
+			{Path: "new.yaml", Status: configdiff.FileAdded},
+			{Path: "moved/db.yaml", OldPath: "db.yaml", Status: configdiff.FileRenamed, Similarity: 0.8, Result: &configdiff.Result{Patch: &patch.Patch{}}},
+			{Path: "broken.yaml", Status: configdiff.FileError, Err: errors.New("parse failed")},
+		},
+	}
+
+	for _, format := range []string{"json", "patch"} {
+		t.Run(format, func(t *testing.T) {
+			output, err := FormatDirOutput(result, OutputOptions{Format: format, PolicyFile: "policy.yaml"})
+			if err != nil {
+				t.Fatalf("FormatDirOutput() error = %v", err)
+			}
+			doc, err := parse.ParseJSON([]byte(output))
+			if err != nil {
+				t.Fatalf("ParseJSON() error = %v", err)
+			}
+			if errs := validator.Validate(doc); len(errs) > 0 {
+				t.Errorf("directory output does not match the schema: %v\n%s", errs, output)
+			}
+		})
+	}
+}
+
+func TestFormatOutput_Validation(t *testing.T) {
+	validator, err := schema.CompileValidator([]byte(`{"type": "object", "properties": {"replicas": {"minimum": 1}}}`))
+	if err != nil {
+		t.Fatalf("CompileValidator() error = %v", err)
+	}
+
+	result, err := configdiff.DiffYAML([]byte("replicas: 2"), []byte("replicas: 0"), configdiff.Options{})
+	if err != nil {
+		t.Fatalf("DiffYAML() error = %v", err)
+	}
+
+	oldTree := &tree.Node{Kind: tree.KindObject, Object: map[string]*tree.Node{"replicas": tree.NewInt(2)}}
+	newTree := &tree.Node{Kind: tree.KindObject, Object: map[string]*tree.Node{"replicas": tree.NewInt(0)}}
+	validation := validator.Compare(oldTree, newTree)
+	validation.FlagChanges(result.Changes)
+	result.Patch, _ = patch.FromChanges(result.Changes)
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"report", []string{"~ /replicas: 2 → 0 [error]", "old.yaml: valid", "✗ /replicas: minimum: got 0, want 1 (introduced)", "makes a valid document invalid"}},
+		{"compact", []string{"/replicas [error]", "new.yaml: 1 error"}},
+		{"stat", []string{"[error]", "Validation against schema.json:"}},
+		{"side-by-side", []string{"/replicas [error]", "new.yaml: 1 error"}},
+		{"git-diff", []string{"# severity: error", "#     ✗ /replicas: minimum: got 0, want 1"}},
+		{"json", []string{`"severity": "error"`, `"introduced": [`, `"path": "/replicas"`, `"schema_version": 1`}},
+		{"patch", []string{`"severity": "error"`, `"old": []`, `"schema": "schema.json"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			output, err := FormatOutput(result, OutputOptions{
+				Format:     tt.format,
+				NoColor:    true,
+				OldFile:    "old.yaml",
+				NewFile:    "new.yaml",
+				Validation: validation,
+				SchemaFile: "schema.json",
+			})
+			if err != nil {
+				t.Fatalf("FormatOutput() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("output missing %q, got:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatOutput_Policy(t *testing.T) {
+	pol, err := policy.Parse([]byte("rules: [{name: no-scale-down, path: /replicas, when: new < old}]"))
+	if err != nil {
+		t.Fatalf("policy.Parse() error = %v", err)
+	}
+
+	result, err := configdiff.DiffYAML([]byte("replicas: 3\nname: a"), []byte("replicas: 2\nname: b"), configdiff.Options{})
+	if err != nil {
+		t.Fatalf("DiffYAML() error = %v", err)
+	}
+
+	violations, err := ApplyPolicy(pol, result)
+	if err != nil {
+		t.Fatalf("ApplyPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"report", []string{"~ /replicas: 3 → 2 [error]", "Policy violations (policy.yaml):", "error   /replicas: change where new < old (no-scale-down)"}},
+		{"git-diff", []string{"# severity: error", "# Policy violations (policy.yaml):"}},
+		{"json", []string{`"policy": {`, `"rule": "no-scale-down"`, `"severity": "error"`}},
+		{"patch", []string{`"operations": [`, `"policy": {`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			output, err := FormatOutput(result, OutputOptions{
+				Format:     tt.format,
+				NoColor:    true,
+				Violations: violations,
+				PolicyFile: "policy.yaml",
+			})
+			if err != nil {
+				t.Fatalf("FormatOutput() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("output missing %q, got:\n%s", want, output)
+				}
+			}
+		})
+	}
+
+	t.Run("no violations", func(t *testing.T) {
+		output, err := FormatOutput(result, OutputOptions{Format: "compact", NoColor: true, PolicyFile: "policy.yaml"})
+		if err != nil {
+			t.Fatalf("FormatOutput() error = %v", err)
+		}
+		if !strings.Contains(output, "Policy policy.yaml: no violations") {
+			t.Errorf("output missing policy result, got:\n%s", output)
+		}
+	})
+}
+
+func TestFormatOutput_MinSeverity(t *testing.T) {
+	classifier, err := policy.ParseClassifier([]byte(`
+rules:
+  - {path: /replicas, category: scaling, severity: warning}
+  - {path: /name, category: metadata, severity: info}
+`))
+	if err != nil {
+		t.Fatalf("policy.ParseClassifier() error = %v", err)
+	}
+
+	result, err := configdiff.DiffYAML([]byte("replicas: 3\nname: a\nport: 80"), []byte("replicas: 2\nname: b\nport: 81"), configdiff.Options{})
+	if err != nil {
+		t.Fatalf("DiffYAML() error = %v", err)
+	}
+	if err := ClassifyChanges(classifier, result); err != nil {
+		t.Fatalf("ClassifyChanges() error = %v", err)
+	}
+
+	tests := []struct {
+		min     diff.Severity
+		want    []string
+		notWant []string
+	}{
+		{"", []string{"/replicas", "/name", "/port"}, nil},
+		{diff.SeverityInfo, []string{"/replicas [warning, scaling]", "/name [info, metadata]"}, []string{"/port"}},
+		{diff.SeverityWarning, []string{"/replicas"}, []string{"/name", "/port"}},
+		{diff.SeverityError, []string{"No changes detected."}, []string{"/replicas"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.min), func(t *testing.T) {
+			shown, err := FilterBySeverity(result, tt.min)
+			if err != nil {
+				t.Fatalf("FilterBySeverity() error = %v", err)
+			}
+			output, err := FormatOutput(shown, OutputOptions{Format: "compact", NoColor: true})
+			if err != nil {
+				t.Fatalf("FormatOutput() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("output missing %q, got:\n%s", want, output)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(output, notWant) {
+					t.Errorf("output contains %q, got:\n%s", notWant, output)
+				}
+			}
+		})
+	}
+
+	if len(result.Changes) != 3 {
+		t.Errorf("FilterBySeverity() modified the result, %d changes left", len(result.Changes))
+	}
+}
+
+func TestAnyChange(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		opts configdiff.Options
+		want bool
+	}{
+		{name: "identical", old: "a: 1\nb: 2\n", new: "b: 2\na: 1\n", want: false},
+		{name: "changed", old: "a: 1\nb: 2\n", new: "a: 1\nb: 3\n", want: true},
+		{
+			name: "ignored",
+			old:  "a: 1\nb: 2\n",
+			new:  "a: 1\nb: 3\n",
+			opts: configdiff.Options{IgnorePaths: []string{"/b"}},
+			want: false,
+		},
+		{
+			name: "equal after normalization",
+			old:  "cidr: 10.0.0.1/8\n",
+			new:  "cidr: 10.0.0.0/8\n",
+			opts: configdiff.Options{
+				Normalizers:      []configdiff.NormalizerRule{{Path: "/cidr", Normalizer: diff.NormalizeCIDR}},
+				ReportNormalized: true,
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AnyChange(
+				&InputSource{Data: []byte(tt.old), Format: "yaml"},
+				&InputSource{Data: []byte(tt.new), Format: "yaml"},
+				tt.opts,
+			)
+			if err != nil {
+				t.Fatalf("AnyChange() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("AnyChange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteGitDiff(t *testing.T) {
+	oldInput := &InputSource{Data: []byte("image: web:1\nitems: [a, b]\nreplicas: 2\n"), Format: "yaml"}
+	newInput := &InputSource{Data: []byte("image: web:2\nitems: [a, c]\nreplicas: 2\n"), Format: "yaml"}
+	opts := configdiff.Options{StableOrder: true}
+
+	var buf bytes.Buffer
+	changed, err := WriteGitDiff(&buf, oldInput, newInput, opts, "a.yaml", "b.yaml")
+	if err != nil {
+		t.Fatalf("WriteGitDiff() error = %v", err)
+	}
+	if !changed {
+		t.Error("WriteGitDiff() changed = false, want true")
+	}
+
+	// Written as found, the output matches the collected git-diff output
+	result, err := configdiff.DiffBytes(oldInput.Data, "yaml", newInput.Data, "yaml", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := FormatOutput(result, OutputOptions{Format: "git-diff", OldFile: "a.yaml", NewFile: "b.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("WriteGitDiff() =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// Values equal after normalization are written but are not changes
+	buf.Reset()
+	changed, err = WriteGitDiff(&buf,
+		&InputSource{Data: []byte("cidr: 10.0.0.1/8\n"), Format: "yaml"},
+		&InputSource{Data: []byte("cidr: 10.0.0.0/8\n"), Format: "yaml"},
+		configdiff.Options{
+			Normalizers:      []configdiff.NormalizerRule{{Path: "/cidr", Normalizer: diff.NormalizeCIDR}},
+			ReportNormalized: true,
+		}, "a.yaml", "b.yaml")
+	if err != nil {
+		t.Fatalf("WriteGitDiff() error = %v", err)
+	}
+	if changed || !strings.Contains(buf.String(), "equal after normalization") {
+		t.Errorf("WriteGitDiff() = %v, %q, want only a normalized value", changed, buf.String())
+	}
+}
+
+func TestAnyChange_Limits(t *testing.T) {
+	_, err := AnyChange(
+		&InputSource{Data: []byte("a: {b: {c: 1}}\n"), Format: "yaml"},
+		&InputSource{Data: []byte("a: 1\n"), Format: "yaml"},
+		configdiff.Options{Limits: limits.Limits{MaxDepth: 2}},
+	)
+	if !errors.Is(err, limits.ErrExceeded) {
+		t.Errorf("AnyChange() error = %v, want the depth limit exceeded", err)
+	}
+}
+
+func TestParseInputs(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     string
+		limits  limits.Limits
+		wantErr error
+	}{
+		{
+			name: "within limits",
+			old:  "a: {b: {c: 1}}\n",
+		},
+		{
+			name:    "depth exceeded",
+			old:     "a: {b: {c: 1}}\n",
+			limits:  limits.Limits{MaxDepth: 2},
+			wantErr: limits.ErrExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldTree, newTree, err := ParseInputs(
+				&InputSource{Data: []byte(tt.old), Format: "yaml"},
+				&InputSource{Data: []byte("a: 1\n"), Format: "yaml"},
+				configdiff.Options{Limits: tt.limits},
+			)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ParseInputs() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseInputs() error = %v", err)
+			}
+			if !oldTree.Hashed() || !newTree.Hashed() {
+				t.Error("ParseInputs() trees have no cached hashes")
+			}
+		})
+	}
+}
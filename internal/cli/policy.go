@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff"
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/policy"
+)
+
+// policyJSON is the "policy" member of json and patch output
+type policyJSON struct {
+	File       string             `json:"file"`
+	Severity   diff.Severity      `json:"severity,omitempty"`
+	Violations []policy.Violation `json:"violations"`
+}
+
+// ApplyPolicy evaluates the policy against the changes of result and
+// raises the severity of violating changes. The result's patch and report
+// are regenerated to carry the severities.
+func ApplyPolicy(p *policy.Policy, result *configdiff.Result) ([]policy.Violation, error) {
+	violations := p.Apply(result.Changes)
+	if err := refreshResult(result); err != nil {
+		return nil, err
+	}
+	return violations, nil
+}
+
+// ApplyDirPolicy evaluates the policy against every file of a directory
+// comparison. Added and removed files are evaluated as a change adding or
+// removing the whole document (see fileResult). Violations carry the path
+// of their file.
+func ApplyDirPolicy(p *policy.Policy, result *configdiff.DirResult) ([]policy.Violation, error) {
+	var violations []policy.Violation
+	for i := range result.Files {
+		f := &result.Files[i]
+		r := fileResult(f)
+		if r == nil {
+			continue
+		}
+		fileViolations, err := ApplyPolicy(p, r)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range fileViolations {
+			v.File = f.Path
+			violations = append(violations, v)
+		}
+	}
+	return violations, nil
+}
+
+// fileResult returns the result rules are applied to for a file of a
+// directory comparison. Added and removed files have no diff, so they are
+// given one holding a single root-level add or remove; the severities and
+// categories rules assign to it are kept with the file.
+func fileResult(f *configdiff.FileResult) *configdiff.Result {
+	if f.Result != nil {
+		return f.Result
+	}
+
+	var ct diff.ChangeType
+	switch f.Status {
+	case configdiff.FileAdded:
+		ct = diff.ChangeTypeAdd
+	case configdiff.FileRemoved:
+		ct = diff.ChangeTypeRemove
+	default:
+		return nil
+	}
+	f.Result = &configdiff.Result{Changes: []configdiff.Change{{Type: ct, Path: "/"}}}
+	return f.Result
+}
+
+// formatViolations renders policy violations as a text section. Every line
+// starts with prefix, which lets git-diff output carry the section as
+// comments.
+func formatViolations(violations []policy.Violation, policyFile, prefix string) string {
+	var b strings.Builder
+
+	if len(violations) == 0 {
+		fmt.Fprintf(&b, "%sPolicy %s: no violations\n", prefix, policyFile)
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "%sPolicy violations (%s):\n", prefix, policyFile)
+	for _, v := range violations {
+		path := v.Path
+		if v.File != "" {
+			path = v.File + ":" + path
+		}
+		fmt.Fprintf(&b, "%s  %-7s %s: %s (%s)\n", prefix, v.Severity, path, v.Message, v.Rule)
+	}
+	return b.String()
+}
+
+// policyDocument builds the JSON form of policy violations
+func policyDocument(violations []policy.Violation, policyFile string) policyJSON {
+	if violations == nil {
+		violations = []policy.Violation{}
+	}
+	return policyJSON{
+		File:       policyFile,
+		Severity:   policy.MaxSeverity(violations),
+		Violations: violations,
+	}
+}
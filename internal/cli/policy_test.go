@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pfrederiksen/configdiff"
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/policy"
+)
+
+func TestApplyDirPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		status configdiff.FileStatus
+		want   []string
+	}{
+		{
+			name:   "added file",
+			policy: "rules: [{name: no-new-files, change: add}]",
+			status: configdiff.FileAdded,
+			want:   []string{"no-new-files"},
+		},
+		{
+			name:   "removed file",
+			policy: "rules: [{name: no-deletes, change: remove}]",
+			status: configdiff.FileRemoved,
+			want:   []string{"no-deletes"},
+		},
+		{
+			name:   "removed file allowed by change type",
+			policy: "rules: [{name: no-new-files, change: add}]",
+			status: configdiff.FileRemoved,
+		},
+		{
+			name:   "added file outside allow list",
+			policy: "rules: [{name: data-only, allow: [/data/*]}]",
+			status: configdiff.FileAdded,
+			want:   []string{"data-only"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pol, err := policy.Parse([]byte(tt.policy))
+			if err != nil {
+				t.Fatalf("policy.Parse() error = %v", err)
+			}
+			result := &configdiff.DirResult{Files: []configdiff.FileResult{{Path: "app.yaml", Status: tt.status}}}
+
+			violations, err := ApplyDirPolicy(pol, result)
+			if err != nil {
+				t.Fatalf("ApplyDirPolicy() error = %v", err)
+			}
+			if len(violations) != len(tt.want) {
+				t.Fatalf("ApplyDirPolicy() = %+v, want rules %v", violations, tt.want)
+			}
+			for i, v := range violations {
+				if v.Rule != tt.want[i] || v.File != "app.yaml" || v.Path != "/" {
+					t.Errorf("violation %d = %+v, want %s at app.yaml:/", i, v, tt.want[i])
+				}
+			}
+
+			if len(tt.want) > 0 {
+				changes := result.Files[0].Result.Changes
+				if len(changes) != 1 || changes[0].Severity != diff.SeverityError {
+					t.Errorf("file changes = %+v, want one root change with severity error", changes)
+				}
+			}
+		})
+	}
+}
+
+func TestClassifyDirChanges(t *testing.T) {
+	c, err := policy.ParseClassifier([]byte(`
+rules:
+  - change: add
+    category: new-file
+    severity: info
+  - change: remove
+    category: deleted-file
+    severity: warn
+`))
+	if err != nil {
+		t.Fatalf("ParseClassifier() error = %v", err)
+	}
+
+	result := &configdiff.DirResult{Files: []configdiff.FileResult{
+		{Path: "added.yaml", Status: configdiff.FileAdded},
+		{Path: "removed.yaml", Status: configdiff.FileRemoved},
+		{Path: "broken.yaml", Status: configdiff.FileError},
+	}}
+	if err := ClassifyDirChanges(c, result); err != nil {
+		t.Fatalf("ClassifyDirChanges() error = %v", err)
+	}
+
+	tests := []struct {
+		category string
+		severity diff.Severity
+		typ      diff.ChangeType
+	}{
+		{"new-file", diff.SeverityInfo, diff.ChangeTypeAdd},
+		{"deleted-file", diff.SeverityWarning, diff.ChangeTypeRemove},
+	}
+	for i, tt := range tests {
+		f := result.Files[i]
+		if f.Result == nil || len(f.Result.Changes) != 1 {
+			t.Fatalf("%s: changes = %+v, want one root-level change", f.Path, f.Result)
+		}
+		got := f.Result.Changes[0]
+		if got.Type != tt.typ || got.Path != "/" || got.Category != tt.category || got.Severity != tt.severity {
+			t.Errorf("%s: change = %+v, want %s of / as (%s, %s)", f.Path, got, tt.typ, tt.category, tt.severity)
+		}
+	}
+	if result.Files[2].Result != nil {
+		t.Errorf("failed file was classified: %+v", result.Files[2].Result)
+	}
+
+	result.Files = result.Files[:2]
+	output, err := FormatDirOutput(result, OutputOptions{Format: "report", NoColor: true})
+	if err != nil {
+		t.Fatalf("FormatDirOutput() error = %v", err)
+	}
+	for _, want := range []string{"+++ added.yaml (added) [info, new-file]", "--- removed.yaml (removed) [warning, deleted-file]"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q, got:\n%s", want, output)
+		}
+	}
+}
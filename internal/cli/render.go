@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/pfrederiksen/configdiff"
+	"github.com/pfrederiksen/configdiff/changeset"
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/patch"
+	"github.com/pfrederiksen/configdiff/report"
+)
+
+// ChangeSet is a comparison read back from its json output
+type ChangeSet struct {
+	// Result holds the changes between two files, or is nil
+	Result *configdiff.Result
+
+	// Dir holds the files of a directory comparison, or is nil
+	Dir *configdiff.DirResult
+
+	// Old and New describe the compared files, when known
+	Old, New changeset.Input
+}
+
+// ReadChangeSet decodes a document written by -o json, for two files or
+// two directories, so it can be rendered with FormatOutput or
+// FormatDirOutput. Validation and policy results in the document are not
+// read.
+func ReadChangeSet(data []byte) (*ChangeSet, error) {
+	doc, dirDoc, err := changeset.DecodeAny(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if dirDoc != nil {
+		dir, err := dirResult(dirDoc)
+		if err != nil {
+			return nil, err
+		}
+		return &ChangeSet{Dir: dir}, nil
+	}
+
+	changes, err := doc.DiffChanges()
+	if err != nil {
+		return nil, err
+	}
+	result, err := resultFromChanges(changes)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &ChangeSet{Result: result}
+	if doc.Old != nil {
+		cs.Old = *doc.Old
+	}
+	if doc.New != nil {
+		cs.New = *doc.New
+	}
+	return cs, nil
+}
+
+// dirResult rebuilds a directory comparison from its document
+func dirResult(doc *changeset.DirDocument) (*configdiff.DirResult, error) {
+	result := &configdiff.DirResult{Files: make([]configdiff.FileResult, 0, len(doc.Files))}
+	for i := range doc.Files {
+		entry := &doc.Files[i]
+		f := configdiff.FileResult{
+			Path:       entry.Path,
+			OldPath:    entry.OldPath,
+			Status:     configdiff.FileStatus(entry.Status),
+			Similarity: entry.Similarity,
+		}
+		if f.OldPath == "" {
+			f.OldPath = f.Path
+		}
+
+		switch f.Status {
+		case configdiff.FileError:
+			f.Err = errors.New(entry.Error)
+		case configdiff.FileModified, configdiff.FileRenamed, configdiff.FileUnchanged:
+			changes, err := entry.DiffChanges()
+			if err != nil {
+				return nil, err
+			}
+			r, err := resultFromChanges(changes)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", entry.Path, err)
+			}
+			f.Result = r
+		}
+		result.Files = append(result.Files, f)
+	}
+	return result, nil
+}
+
+// resultFromChanges rebuilds a diff result from decoded changes
+func resultFromChanges(changes []diff.Change) (*configdiff.Result, error) {
+	p, err := patch.FromChanges(changes)
+	if err != nil {
+		return nil, err
+	}
+	return &configdiff.Result{
+		Changes: changes,
+		Patch:   p,
+		Report:  report.GenerateDetailed(changes),
+	}, nil
+}
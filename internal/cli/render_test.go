@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pfrederiksen/configdiff"
+	"github.com/pfrederiksen/configdiff/changeset"
+)
+
+func TestReadChangeSet(t *testing.T) {
+	result, err := configdiff.DiffYAML([]byte("replicas: 2\nimage: app:1.0"), []byte("replicas: 3\nimage: app:1.0\ndebug: true"), configdiff.Options{StableOrder: true})
+	if err != nil {
+		t.Fatalf("DiffYAML() error = %v", err)
+	}
+	saved, err := FormatOutput(result, OutputOptions{Format: "json", OldFile: "old.yaml", NewFile: "new.yaml", OldFormat: "yaml", NewFormat: "yaml"})
+	if err != nil {
+		t.Fatalf("FormatOutput() error = %v", err)
+	}
+
+	cs, err := ReadChangeSet([]byte(saved))
+	if err != nil {
+		t.Fatalf("ReadChangeSet() error = %v", err)
+	}
+	if cs.Dir != nil || cs.Old.Path != "old.yaml" || cs.New.Format != "yaml" {
+		t.Errorf("ReadChangeSet() = %+v, want a file change set from old.yaml", cs)
+	}
+
+	for _, format := range []string{"report", "compact", "stat", "side-by-side", "git-diff", "patch", "json"} {
+		t.Run(format, func(t *testing.T) {
+			opts := OutputOptions{Format: format, NoColor: true, OldFile: "old.yaml", NewFile: "new.yaml", OldFormat: "yaml", NewFormat: "yaml"}
+			want, err := FormatOutput(result, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := FormatOutput(cs.Result, opts)
+			if err != nil {
+				t.Fatalf("FormatOutput() error = %v", err)
+			}
+			if got != want {
+				t.Errorf("rendered change set =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestReadChangeSet_Dir(t *testing.T) {
+	input := `{
+  "schema_version": 1,
+  "files": [
+    {"path": "a.yaml", "status": "modified", "changes": [{"type": "modify", "path": "/x", "old_value": 1, "new_value": 2}]},
+    {"path": "b.yaml", "old_path": "c.yaml", "status": "renamed", "similarity": 1},
+    {"path": "d.yaml", "status": "error", "error": "failed to parse"},
+    {"path": "e.yaml", "status": "added"}
+  ],
+  "summary": {"compared": 1, "modified": 1, "added": 1, "removed": 0, "renamed": 1, "errors": 1}
+}`
+
+	cs, err := ReadChangeSet([]byte(input))
+	if err != nil {
+		t.Fatalf("ReadChangeSet() error = %v", err)
+	}
+	if cs.Result != nil || cs.Dir == nil || len(cs.Dir.Files) != 4 {
+		t.Fatalf("ReadChangeSet() = %+v, want a directory change set of 4 files", cs)
+	}
+
+	output, err := FormatDirOutput(cs.Dir, OutputOptions{Format: "report", NoColor: true})
+	if err != nil {
+		t.Fatalf("FormatDirOutput() error = %v", err)
+	}
+	for _, want := range []string{
+		"=== a.yaml ===",
+		"~ /x: 1 → 2",
+		"=== c.yaml → b.yaml (renamed, 100% similar) ===",
+		"Error: failed to parse",
+		"+++ e.yaml (added)",
+		"Summary: 1 files compared, 1 renamed, 1 added, 0 removed, 1 errors",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestReadChangeSet_DirRoundTrip(t *testing.T) {
+	result, err := configdiff.DiffYAML([]byte("replicas: 2"), []byte("replicas: 3\ndebug: true"), configdiff.Options{StableOrder: true})
+	if err != nil {
+		t.Fatalf("DiffYAML() error = %v", err)
+	}
+	dir := &configdiff.DirResult{Files: []configdiff.FileResult{
+		{Path: "app.yaml", OldPath: "app.yaml", Status: configdiff.FileModified, Result: result},
+		{Path: "new.yaml", Status: configdiff.FileAdded},
+	}}
+	saved, err := FormatDirOutput(dir, OutputOptions{Format: "json"})
+	if err != nil {
+		t.Fatalf("FormatDirOutput() error = %v", err)
+	}
+
+	changes, err := changeset.ReadChanges(strings.NewReader(saved))
+	if err != nil {
+		t.Fatalf("changeset.ReadChanges() error = %v", err)
+	}
+	if len(changes) != len(result.Changes) {
+		t.Errorf("changeset.ReadChanges() = %d changes, want %d", len(changes), len(result.Changes))
+	}
+
+	cs, err := ReadChangeSet([]byte(saved))
+	if err != nil {
+		t.Fatalf("ReadChangeSet() error = %v", err)
+	}
+	for _, format := range []string{"report", "json"} {
+		want, err := FormatDirOutput(dir, OutputOptions{Format: format, NoColor: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := FormatDirOutput(cs.Dir, OutputOptions{Format: format, NoColor: true})
+		if err != nil {
+			t.Fatalf("FormatDirOutput() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("%s: rendered change set =\n%s\nwant\n%s", format, got, want)
+		}
+	}
+}
+
+func TestReadChangeSet_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"not json", "a: 1", "failed to decode change set"},
+		{"unversioned changes", `[{"Type": "add", "Path": "/a"}]`, "failed to decode change set"},
+		{"patch output", `{"operations": []}`, "no schema_version"},
+		{"newer version", `{"schema_version": 2, "changes": []}`, "newer than the supported version 1"},
+		{"newer directory version", `{"schema_version": 2, "files": []}`, "newer than the supported version 1"},
+		{"directory patch output", `{"schema_version": 1, "files": [{"path": "a.yaml", "status": "modified", "operations": [{"op": "remove", "path": "/a"}]}]}`, "patch operations cannot be read"},
+		{"invalid value", `{"schema_version": 1, "changes": [{"type": "add", "path": "/a", "new_value": {"x": NaN}}]}`, "failed to decode change set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadChangeSet([]byte(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ReadChangeSet() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
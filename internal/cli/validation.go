@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff"
+	"github.com/pfrederiksen/configdiff/patch"
+	"github.com/pfrederiksen/configdiff/report"
+	"github.com/pfrederiksen/configdiff/schema"
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// validationJSON is the "validation" member of json and patch output
+type validationJSON struct {
+	Schema     string                   `json:"schema"`
+	Old        []schema.ValidationError `json:"old"`
+	New        []schema.ValidationError `json:"new"`
+	Introduced []schema.ValidationError `json:"introduced"`
+}
+
+// ValidateInputs validates the parsed inputs against the JSON Schema in
+// schemaFile and flags the changes in result that introduce violations.
+// The result's patch and report are regenerated to carry the flags.
+func ValidateInputs(schemaFile string, oldTree, newTree *tree.Node, result *configdiff.Result) (*schema.Validation, error) {
+	validator, err := schema.LoadValidator(schemaFile)
+	if err != nil {
+		return nil, err
+	}
+
+	validation := validator.Compare(oldTree, newTree)
+	validation.FlagChanges(result.Changes)
+	if err := refreshResult(result); err != nil {
+		return nil, err
+	}
+	return validation, nil
+}
+
+// refreshResult regenerates the patch and report of result after the
+// severities of its changes were updated
+func refreshResult(result *configdiff.Result) error {
+	p, err := patch.FromChanges(result.Changes)
+	if err != nil {
+		return fmt.Errorf("patch generation failed: %w", err)
+	}
+	result.Patch = p
+	result.Report = report.GenerateDetailed(result.Changes)
+	return nil
+}
+
+// formatValidation renders the validation results as a text section. Every
+// line starts with prefix, which lets git-diff output carry the section as
+// comments.
+func formatValidation(v *schema.Validation, opts OutputOptions, prefix string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%sValidation against %s:\n", prefix, opts.SchemaFile)
+	writeValidationSide(&b, prefix, displayName(opts.OldFile, "old"), v.Old, nil)
+
+	introduced := make(map[schema.ValidationError]bool)
+	for _, e := range v.Introduced() {
+		introduced[e] = true
+	}
+	writeValidationSide(&b, prefix, displayName(opts.NewFile, "new"), v.New, introduced)
+
+	if v.Breaking() {
+		fmt.Fprintf(&b, "%s  The change makes a valid document invalid.\n", prefix)
+	}
+	return b.String()
+}
+
+// writeValidationSide renders the violations of one document. Violations
+// in introduced are marked as new.
+func writeValidationSide(b *strings.Builder, prefix, name string, errs []schema.ValidationError, introduced map[schema.ValidationError]bool) {
+	switch len(errs) {
+	case 0:
+		fmt.Fprintf(b, "%s  %s: valid\n", prefix, name)
+		return
+	case 1:
+		fmt.Fprintf(b, "%s  %s: 1 error\n", prefix, name)
+	default:
+		fmt.Fprintf(b, "%s  %s: %d errors\n", prefix, name, len(errs))
+	}
+
+	for _, e := range errs {
+		line := fmt.Sprintf("%s    ✗ %s", prefix, e)
+		if introduced[e] {
+			line += " (introduced)"
+		}
+		b.WriteString(line + "\n")
+	}
+}
+
+// validationDocument builds the JSON form of the validation results
+func validationDocument(v *schema.Validation, schemaFile string) validationJSON {
+	return validationJSON{
+		Schema:     schemaFile,
+		Old:        nonNilErrors(v.Old),
+		New:        nonNilErrors(v.New),
+		Introduced: nonNilErrors(v.Introduced()),
+	}
+}
+
+// nonNilErrors returns errs, or an empty slice so JSON shows [] instead of null
+func nonNilErrors(errs []schema.ValidationError) []schema.ValidationError {
+	if errs == nil {
+		return []schema.ValidationError{}
+	}
+	return errs
+}
+
+// displayName returns the file name to show, or fallback for unnamed input
+func displayName(path, fallback string) string {
+	if path == "" {
+		return fallback
+	}
+	if path == "-" {
+		return "stdin"
+	}
+	return path
+}
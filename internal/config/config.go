@@ -14,8 +14,62 @@ type Config struct {
 	IgnorePaths []string `yaml:"ignore_paths"`
 
 	// ArrayKeys maps paths to key fields for array-as-set behavior.
+	// Composite keys list several fields, e.g. "containerPort,protocol".
 	ArrayKeys map[string]string `yaml:"array_keys"`
 
+	// StrictArrayKeys fails when an element of a keyed array lacks its key
+	// or repeats one, instead of comparing such elements by position.
+	StrictArrayKeys bool `yaml:"strict_array_keys"`
+
+	// UnorderedArrays lists array selectors compared as multisets, ignoring
+	// the order of their elements.
+	UnorderedArrays []string `yaml:"unordered_arrays"`
+
+	// InferArrayKeys compares arrays of objects as sets keyed by an
+	// inferred field when no array key is configured for them.
+	InferArrayKeys bool `yaml:"infer_array_keys"`
+
+	// KeyRenames reports a removed and an added key of an object as a
+	// rename when at least this percentage of their values is unchanged.
+	KeyRenames int `yaml:"find_key_renames"`
+
+	// Normalize maps paths to built-in normalizers (cidr, ip, url).
+	Normalize map[string]string `yaml:"normalize"`
+
+	// Tolerance lists numeric tolerances as "value" or "path=value",
+	// where value is absolute (0.001) or relative (1%).
+	Tolerance []string `yaml:"tolerance"`
+
+	// NullEqualsAbsent treats null values and missing keys as equal.
+	NullEqualsAbsent bool `yaml:"null_equals_absent"`
+
+	// EmptyEqualsAbsent treats empty arrays and objects and missing keys as equal.
+	EmptyEqualsAbsent bool `yaml:"empty_equals_absent"`
+
+	// EmptyStringEqualsNull treats empty strings and null as equal.
+	EmptyStringEqualsNull bool `yaml:"empty_string_equals_null"`
+
+	// Equivalences maps path patterns to the equivalences applied there
+	// (null-absent, empty-absent, empty-string-null), replacing the global ones.
+	Equivalences map[string][]string `yaml:"equivalences"`
+
+	// Defaults lists JSON Schema or OpenAPI files, or the "kubernetes"
+	// preset, whose default values are not reported when omitted.
+	Defaults []string `yaml:"defaults"`
+
+	// Schema is a JSON Schema file both compared files are validated against.
+	Schema string `yaml:"schema"`
+
+	// Policy is a policy file whose rules are evaluated against the changes.
+	Policy string `yaml:"policy"`
+
+	// Classify lists classification rule files, or the "kubernetes" and
+	// "terraform" rule packs, that assign categories and severities.
+	Classify []string `yaml:"classify"`
+
+	// MinSeverity hides changes below this severity (info, warning, error).
+	MinSeverity string `yaml:"min_severity"`
+
 	// NumericStrings enables treating string numbers as numbers.
 	NumericStrings bool `yaml:"numeric_strings"`
 
@@ -33,6 +87,15 @@ type Config struct {
 
 	// NoColor disables colored output.
 	NoColor bool `yaml:"no_color"`
+
+	// Include limits directory comparisons to files matching these globs.
+	Include []string `yaml:"include"`
+
+	// Exclude skips files and directories matching these globs in directory comparisons.
+	Exclude []string `yaml:"exclude"`
+
+	// Gitignore honors .gitignore files in directory comparisons.
+	Gitignore bool `yaml:"gitignore"`
 }
 
 // Load attempts to load configuration from standard locations.
@@ -159,6 +159,26 @@ func TestLoadFile(t *testing.T) {
 		}
 	})
 
+	t.Run("equivalences", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "equivalences.yaml")
+		content := "null_equals_absent: true\nequivalences:\n  /metadata/*: [null-absent, empty-absent]\n"
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		cfg, err := loadFile(path)
+		if err != nil {
+			t.Fatalf("loadFile() error = %v, want nil", err)
+		}
+		if !cfg.NullEqualsAbsent {
+			t.Error("NullEqualsAbsent = false, want true")
+		}
+		if len(cfg.Equivalences["/metadata/*"]) != 2 {
+			t.Errorf("Equivalences = %v, want 2 entries for /metadata/*", cfg.Equivalences)
+		}
+	})
+
 	t.Run("file not found", func(t *testing.T) {
 		_, err := loadFile("/nonexistent/file.yaml")
 		if err == nil {
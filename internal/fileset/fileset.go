@@ -0,0 +1,146 @@
+// Package fileset selects the configuration files taking part in a directory
+// comparison, applying include/exclude globs and gitignore-style ignore files.
+package fileset
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// IgnoreFileName is the name of the per-directory ignore file, written in
+// gitignore syntax.
+const IgnoreFileName = ".configdiffignore"
+
+// gitignoreFileName is the name of git's per-directory ignore file.
+const gitignoreFileName = ".gitignore"
+
+// extensionFormats maps configuration file extensions to their input format.
+var extensionFormats = map[string]string{
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".json": "json",
+	".hcl":  "hcl",
+	".tf":   "hcl",
+	".toml": "toml",
+}
+
+// Options controls which files are collected.
+type Options struct {
+	// Include limits collection to files matching at least one of these
+	// doublestar globs, relative to the root (e.g. "apps/**/*.yaml").
+	// An empty list includes every configuration file.
+	Include []string
+
+	// Exclude skips files and directories matching any of these doublestar
+	// globs, relative to the root (e.g. "**/node_modules", "charts/**").
+	Exclude []string
+
+	// UseGitignore also honors .gitignore files, in addition to
+	// .configdiffignore files which are always honored.
+	UseGitignore bool
+}
+
+// Validate checks that all glob patterns are well-formed.
+func (o Options) Validate() error {
+	for _, p := range o.Include {
+		if !doublestar.ValidatePattern(p) {
+			return fmt.Errorf("invalid include pattern %q", p)
+		}
+	}
+	for _, p := range o.Exclude {
+		if !doublestar.ValidatePattern(p) {
+			return fmt.Errorf("invalid exclude pattern %q", p)
+		}
+	}
+	return nil
+}
+
+// Collect walks fsys and returns the slash-separated paths of all
+// configuration files selected by opts, in sorted order.
+func Collect(fsys fs.FS, opts Options) ([]string, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	ignoreFiles := []string{IgnoreFileName}
+	if opts.UseGitignore {
+		ignoreFiles = append(ignoreFiles, gitignoreFileName)
+	}
+
+	// rules holds the ignore rules in effect for each visited directory
+	rules := make(map[string][]ignoreRule)
+
+	var files []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		inherited := rules[path.Dir(p)]
+
+		if d.IsDir() {
+			if p != "." && (matchAny(opts.Exclude, p) || ignored(inherited, p, true)) {
+				return fs.SkipDir
+			}
+
+			dirRules, err := loadIgnoreRules(fsys, p, ignoreFiles)
+			if err != nil {
+				return err
+			}
+			rules[p] = append(append([]ignoreRule(nil), inherited...), dirRules...)
+			return nil
+		}
+
+		if !IsConfigFile(p) {
+			return nil
+		}
+		if len(opts.Include) > 0 && !matchAny(opts.Include, p) {
+			return nil
+		}
+		if matchAny(opts.Exclude, p) || ignored(inherited, p, false) {
+			return nil
+		}
+
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// IsConfigFile reports whether the file name has a configuration extension.
+func IsConfigFile(name string) bool {
+	return Format(name) != ""
+}
+
+// IsIgnoreFile reports whether the file name is that of an ignore file
+// Collect may read, .configdiffignore or .gitignore.
+func IsIgnoreFile(name string) bool {
+	base := path.Base(name)
+	return base == IgnoreFileName || base == gitignoreFileName
+}
+
+// Format returns the input format implied by the file extension
+// ("yaml", "json", "hcl" or "toml"), or "" if the extension is not recognized.
+func Format(name string) string {
+	return extensionFormats[strings.ToLower(path.Ext(name))]
+}
+
+// matchAny reports whether p matches any of the doublestar patterns.
+func matchAny(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if doublestar.MatchUnvalidated(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package fileset
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCollect(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.yaml":                          {Data: []byte("a: 1")},
+		"README.md":                         {Data: []byte("docs")},
+		"apps/web/deploy.yaml":              {Data: []byte("a: 1")},
+		"apps/web/values.json":              {Data: []byte("{}")},
+		"apps/web/node_modules/pkg/x.json":  {Data: []byte("{}")},
+		"charts/redis/values.yaml":          {Data: []byte("a: 1")},
+		"generated/lock.json":               {Data: []byte("{}")},
+		"generated/keep.json":               {Data: []byte("{}")},
+		"tmp/scratch.yaml":                  {Data: []byte("a: 1")},
+		".configdiffignore":                 {Data: []byte("# comment\ngenerated/*\n!generated/keep.json\n")},
+		"apps/.configdiffignore":            {Data: []byte("values.json\n")},
+		".gitignore":                        {Data: []byte("tmp/\n")},
+		"apps/web/node_modules/.gitignore":  {Data: []byte("")},
+		"charts/redis/templates/svc.yaml":   {Data: []byte("a: 1")},
+		"charts/redis/templates/.gitignore": {Data: []byte("")},
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{
+			name: "configdiffignore only",
+			opts: Options{},
+			want: []string{
+				"app.yaml",
+				"apps/web/deploy.yaml",
+				"apps/web/node_modules/pkg/x.json",
+				"charts/redis/templates/svc.yaml",
+				"charts/redis/values.yaml",
+				"generated/keep.json",
+				"tmp/scratch.yaml",
+			},
+		},
+		{
+			name: "honor gitignore",
+			opts: Options{UseGitignore: true},
+			want: []string{
+				"app.yaml",
+				"apps/web/deploy.yaml",
+				"apps/web/node_modules/pkg/x.json",
+				"charts/redis/templates/svc.yaml",
+				"charts/redis/values.yaml",
+				"generated/keep.json",
+			},
+		},
+		{
+			name: "exclude globs prune directories",
+			opts: Options{Exclude: []string{"**/node_modules", "charts/**"}},
+			want: []string{
+				"app.yaml",
+				"apps/web/deploy.yaml",
+				"generated/keep.json",
+				"tmp/scratch.yaml",
+			},
+		},
+		{
+			name: "include globs",
+			opts: Options{Include: []string{"apps/**/*.yaml", "*.yaml"}},
+			want: []string{
+				"app.yaml",
+				"apps/web/deploy.yaml",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Collect(fsys, tt.opts)
+			if err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Collect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{"valid", Options{Include: []string{"**/*.yaml"}, Exclude: []string{"vendor/**"}}, false},
+		{"invalid include", Options{Include: []string{"[abc"}}, true},
+		{"invalid exclude", Options{Exclude: []string{"{a,b"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	rules := parseIgnoreRules([]byte("*.lock.json\n/build\ncache/\n!important.lock.json\n"), ".")
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"deps.lock.json", false, true},
+		{"nested/deps.lock.json", false, true},
+		{"important.lock.json", false, false},
+		{"build", true, true},
+		{"nested/build", true, false},
+		{"cache", true, true},
+		{"cache", false, false},
+		{"app.yaml", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := ignored(rules, tt.path, tt.isDir); got != tt.want {
+				t.Errorf("ignored(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectExtensions(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Create test files
+	testFiles := []string{
+		"config.yaml",
+		"config.yml",
+		"data.json",
+		"terraform.tf",
+		"vars.hcl",
+		"Cargo.toml",
+		"subdir/nested.yaml",
+		"README.md", // Should not be collected
+		"script.sh", // Should not be collected
+	}
+
+	for _, f := range testFiles {
+		path := filepath.Join(tmpDir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("test"), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", f, err)
+		}
+	}
+
+	files, err := Collect(os.DirFS(tmpDir), Options{})
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	want := []string{
+		"Cargo.toml",
+		"config.yaml",
+		"config.yml",
+		"data.json",
+		"subdir/nested.yaml",
+		"terraform.tf",
+		"vars.hcl",
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("Collect() = %v, want %v", files, want)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"a.yaml", "yaml"},
+		{"a.YML", "yaml"},
+		{"a.json", "json"},
+		{"main.tf", "hcl"},
+		{"a.hcl", "hcl"},
+		{"Cargo.toml", "toml"},
+		{"README.md", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Format(tt.name); got != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
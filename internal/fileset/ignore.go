@@ -0,0 +1,107 @@
+package fileset
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ignoreRule is a single pattern from a gitignore-style file.
+type ignoreRule struct {
+	// pattern is a doublestar glob relative to the root of the walk.
+	pattern string
+
+	// negate re-includes paths matched by an earlier rule ("!pattern").
+	negate bool
+
+	// dirOnly restricts the rule to directories ("pattern/").
+	dirOnly bool
+}
+
+// loadIgnoreRules reads the ignore files present in dir, in order.
+// Missing files are not an error.
+func loadIgnoreRules(fsys fs.FS, dir string, names []string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path.Join(dir, name), err)
+		}
+		rules = append(rules, parseIgnoreRules(data, dir)...)
+	}
+	return rules, nil
+}
+
+// parseIgnoreRules converts gitignore syntax into rules anchored at dir.
+//
+// Patterns without a slash match at any depth below dir, patterns containing
+// a slash are relative to dir, a trailing slash matches only directories and
+// a leading "!" re-includes a previously ignored path.
+func parseIgnoreRules(data []byte, dir string) []ignoreRule {
+	var rules []ignoreRule
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		// A backslash escapes a leading "#" or "!"
+		if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if !anchored && !strings.HasPrefix(line, "**") {
+			line = "**/" + line
+		}
+		if dir != "." {
+			line = dir + "/" + line
+		}
+
+		if !doublestar.ValidatePattern(line) {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// ignored reports whether p is ignored by rules. The last matching rule wins.
+func ignored(rules []ignoreRule, p string, isDir bool) bool {
+	result := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if doublestar.MatchUnvalidated(rule.pattern, p) {
+			result = !rule.negate
+		}
+	}
+	return result
+}
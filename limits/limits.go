@@ -0,0 +1,112 @@
+// Package limits bounds the resources spent on parsing and diffing
+// configuration files, for services that accept untrusted input.
+//
+// Zero fields of a Limits take their value from Default, which accepts large
+// real-world files but rejects pathological ones; negative fields impose no
+// limit:
+//
+//	opts := configdiff.Options{Limits: limits.Limits{MaxInputBytes: 1 << 20}}
+//	result, err := configdiff.DiffBytesContext(ctx, a, "yaml", b, "yaml", opts)
+//	if errors.Is(err, limits.ErrExceeded) {
+//		// reject the input
+//	}
+package limits
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Limits bounds parsing and diffing. A zero field takes its value from
+// Default and a negative one imposes no limit.
+type Limits struct {
+	// MaxInputBytes bounds the size of each parsed document.
+	MaxInputBytes int64
+
+	// MaxDepth bounds the nesting of objects and arrays in a document.
+	MaxDepth int
+
+	// MaxNodes bounds the number of values in a document, counting aliased
+	// values each time they are expanded.
+	MaxNodes int
+
+	// MaxAliases bounds the number of YAML alias expansions in a document,
+	// guarding against "billion laughs" alias bombs.
+	MaxAliases int
+
+	// MaxChanges bounds the number of changes a diff reports.
+	MaxChanges int
+}
+
+// Default returns limits that accept large real-world configuration files,
+// such as cluster dumps, but reject pathological ones.
+func Default() Limits {
+	return Limits{
+		MaxInputBytes: 64 << 20,
+		MaxDepth:      1000,
+		MaxNodes:      10_000_000,
+		MaxAliases:    10_000,
+		MaxChanges:    1_000_000,
+	}
+}
+
+// WithDefaults returns l with each zero field set to its value in Default.
+func (l Limits) WithDefaults() Limits {
+	def := Default()
+	if l.MaxInputBytes == 0 {
+		l.MaxInputBytes = def.MaxInputBytes
+	}
+	if l.MaxDepth == 0 {
+		l.MaxDepth = def.MaxDepth
+	}
+	if l.MaxNodes == 0 {
+		l.MaxNodes = def.MaxNodes
+	}
+	if l.MaxAliases == 0 {
+		l.MaxAliases = def.MaxAliases
+	}
+	if l.MaxChanges == 0 {
+		l.MaxChanges = def.MaxChanges
+	}
+	return l
+}
+
+// The limits an Error can report.
+const (
+	InputBytes = "input bytes"
+	Depth      = "depth"
+	Nodes      = "nodes"
+	Aliases    = "aliases"
+	Changes    = "changes"
+)
+
+// ErrExceeded matches every *Error with errors.Is.
+var ErrExceeded = errors.New("limit exceeded")
+
+// Error reports an exceeded limit.
+type Error struct {
+	// Limit names the exceeded limit, e.g. Depth.
+	Limit string
+
+	// Max is the value of the limit.
+	Max int64
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s limit of %d exceeded", e.Limit, e.Max)
+}
+
+// Is reports whether target is ErrExceeded.
+func (e *Error) Is(target error) bool {
+	return target == ErrExceeded
+}
+
+// Check returns an *Error for limit if n exceeds max. A max of zero or less
+// imposes no limit; resolve zero fields with WithDefaults first.
+func Check(limit string, n, max int64) error {
+	if max > 0 && n > max {
+		return &Error{Limit: limit, Max: max}
+	}
+	return nil
+}
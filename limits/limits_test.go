@@ -0,0 +1,53 @@
+package limits
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		n, max  int64
+		wantErr bool
+	}{
+		{"no limit", 100, 0, false},
+		{"under", 9, 10, false},
+		{"at", 10, 10, false},
+		{"over", 11, 10, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(Depth, tt.n, tt.max)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			if !errors.Is(err, ErrExceeded) {
+				t.Errorf("errors.Is(err, ErrExceeded) = false")
+			}
+			if want := "depth limit of 10 exceeded"; err.Error() != want {
+				t.Errorf("Error() = %q, want %q", err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestLimits_WithDefaults(t *testing.T) {
+	def := Default()
+
+	if got := (Limits{}).WithDefaults(); got != def {
+		t.Errorf("Limits{}.WithDefaults() = %+v, want %+v", got, def)
+	}
+
+	l := Limits{MaxInputBytes: 10, MaxDepth: -1}
+	want := def
+	want.MaxInputBytes = 10
+	want.MaxDepth = -1
+	if got := l.WithDefaults(); got != want {
+		t.Errorf("WithDefaults() = %+v, want %+v", got, want)
+	}
+}
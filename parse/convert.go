@@ -0,0 +1,302 @@
+package parse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/limits"
+	"github.com/pfrederiksen/configdiff/tree"
+	"gopkg.in/yaml.v3"
+)
+
+// converter builds trees from decoded documents within limits.
+type converter struct {
+	ctx context.Context
+	lim limits.Limits
+
+	nodes   int // values converted so far
+	aliases int // YAML aliases expanded so far
+}
+
+// visit counts a value at depth against the limits, and checks the context
+// every 1024 values.
+func (c *converter) visit(depth int) error {
+	c.nodes++
+	if err := limits.Check(limits.Nodes, int64(c.nodes), int64(c.lim.MaxNodes)); err != nil {
+		return err
+	}
+	if err := limits.Check(limits.Depth, int64(depth), int64(c.lim.MaxDepth)); err != nil {
+		return err
+	}
+	if c.nodes%1024 == 0 {
+		return c.ctx.Err()
+	}
+	return nil
+}
+
+// valueToNode converts a Go value at depth to a tree.Node.
+func (c *converter) valueToNode(v interface{}, depth int) (*tree.Node, error) {
+	if err := c.visit(depth); err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return tree.NewNull(), nil
+	}
+
+	switch val := v.(type) {
+	case bool:
+		return tree.NewBool(val), nil
+
+	case int:
+		return tree.NewInt(int64(val)), nil
+	case int8:
+		return tree.NewInt(int64(val)), nil
+	case int16:
+		return tree.NewInt(int64(val)), nil
+	case int32:
+		return tree.NewInt(int64(val)), nil
+	case int64:
+		return tree.NewInt(val), nil
+	case uint:
+		return tree.NewBigInt(new(big.Int).SetUint64(uint64(val))), nil
+	case uint8:
+		return tree.NewInt(int64(val)), nil
+	case uint16:
+		return tree.NewInt(int64(val)), nil
+	case uint32:
+		return tree.NewInt(int64(val)), nil
+	case uint64:
+		return tree.NewBigInt(new(big.Int).SetUint64(val)), nil
+	case *big.Int:
+		return tree.NewBigInt(val), nil
+	case float32:
+		return tree.NewNumber(float64(val)), nil
+	case float64:
+		return tree.NewNumber(val), nil
+	case json.Number:
+		return tree.ParseNumber(string(val))
+
+	case string:
+		return tree.NewString(val), nil
+
+	case map[string]interface{}:
+		obj := make(map[string]*tree.Node)
+		for k, v := range val {
+			node, err := c.valueToNode(v, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			obj[k] = node
+		}
+		return tree.NewObject(obj), nil
+
+	case []interface{}:
+		arr := make([]*tree.Node, len(val))
+		for i, item := range val {
+			node, err := c.valueToNode(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = node
+		}
+		return tree.NewArray(arr), nil
+
+	case []map[string]interface{}:
+		// TOML array of tables
+		arr := make([]*tree.Node, len(val))
+		for i, item := range val {
+			node, err := c.valueToNode(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = node
+		}
+		return tree.NewArray(arr), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported value type: %T", v)
+	}
+}
+
+// yamlToNode converts a YAML node at depth to a tree.Node, like decoding
+// into an interface{}, except that integers too large for 64 bits are kept
+// exact instead of being resolved as floats. Aliases and merge keys are
+// expanded here rather than by the YAML decoder, so that their expansion is
+// counted against the limits, which ParseContext always sets.
+func (c *converter) yamlToNode(n *yaml.Node, depth int) (*tree.Node, error) {
+	switch n.Kind {
+	case 0:
+		// Empty document
+		return c.valueToNode(nil, depth)
+
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return c.valueToNode(nil, depth)
+		}
+		return c.yamlToNode(n.Content[0], depth)
+
+	case yaml.AliasNode:
+		c.aliases++
+		if err := limits.Check(limits.Aliases, int64(c.aliases), int64(c.lim.MaxAliases)); err != nil {
+			return nil, err
+		}
+		return c.yamlToNode(n.Alias, depth)
+
+	case yaml.MappingNode:
+		if err := c.visit(depth); err != nil {
+			return nil, err
+		}
+		return c.yamlMapping(n, depth)
+
+	case yaml.SequenceNode:
+		if err := c.visit(depth); err != nil {
+			return nil, err
+		}
+		arr := make([]*tree.Node, len(n.Content))
+		for i, item := range n.Content {
+			node, err := c.yamlToNode(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = node
+		}
+		return tree.NewArray(arr), nil
+	}
+
+	var v interface{}
+	if err := n.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	// yaml.v3 resolves integers beyond 64 bits as floats
+	if _, ok := v.(float64); ok {
+		plain := strings.TrimPrefix(strings.ReplaceAll(n.Value, "_", ""), "+")
+		if i, ok := new(big.Int).SetString(plain, 0); ok {
+			return c.valueToNode(i, depth)
+		}
+	}
+	return c.valueToNode(v, depth)
+}
+
+// yamlMapping converts a YAML mapping. Keys become strings as written.
+// Keys set explicitly take precedence over merged ones, and earlier merged
+// mappings over later ones.
+func (c *converter) yamlMapping(n *yaml.Node, depth int) (*tree.Node, error) {
+	// Keys are duplicates when of the same kind and value, as in yaml.v3
+	type mappingKey struct {
+		kind  yaml.Kind
+		value string
+	}
+	seen := make(map[mappingKey]int, len(n.Content)/2)
+	for i := 0; i < len(n.Content); i += 2 {
+		k := n.Content[i]
+		key := mappingKey{k.Kind, k.Value}
+		if line, ok := seen[key]; ok {
+			return nil, fmt.Errorf("line %d: mapping key %q already defined at line %d", k.Line, k.Value, line)
+		}
+		seen[key] = k.Line
+	}
+
+	obj := make(map[string]*tree.Node, len(n.Content)/2)
+	var merge *yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		keyNode, valueNode := n.Content[i], n.Content[i+1]
+		if isMergeKey(keyNode) {
+			merge = valueNode
+			continue
+		}
+
+		if keyNode.Kind == yaml.AliasNode {
+			keyNode = keyNode.Alias
+		}
+		if keyNode.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: invalid map key", keyNode.Line)
+		}
+
+		value, err := c.yamlToNode(valueNode, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		obj[keyNode.Value] = value
+	}
+
+	if merge != nil {
+		var sources []*yaml.Node
+		if merge.Kind == yaml.SequenceNode {
+			sources = merge.Content
+		} else {
+			sources = []*yaml.Node{merge}
+		}
+		for _, src := range sources {
+			if err := c.yamlMerge(obj, src, depth); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return tree.NewObject(obj), nil
+}
+
+// yamlMerge adds the keys of the mapping src that obj lacks.
+func (c *converter) yamlMerge(obj map[string]*tree.Node, src *yaml.Node, depth int) error {
+	target := src
+	if src.Kind == yaml.AliasNode {
+		target = src.Alias
+	}
+	if target == nil || target.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: map merge requires map or sequence of maps as the value", src.Line)
+	}
+
+	merged, err := c.yamlToNode(src, depth)
+	if err != nil {
+		return err
+	}
+	for k, v := range merged.Object {
+		if _, ok := obj[k]; !ok {
+			obj[k] = v
+		}
+	}
+	return nil
+}
+
+// isMergeKey reports whether n is the YAML merge key <<.
+func isMergeKey(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.Value == "<<" && (n.Tag == "" || n.Tag == "!" || n.ShortTag() == "!!merge")
+}
+
+// checkJSONDepth reports an *limits.Error if arrays and objects in data nest
+// deeper than max, before the document is decoded.
+func checkJSONDepth(data []byte, max int) error {
+	if max <= 0 {
+		return nil
+	}
+
+	depth := 0
+	inString, escaped := false, false
+	for _, b := range data {
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if b == '\\' {
+				escaped = true
+			} else if b == '"' {
+				inString = false
+			}
+		case b == '"':
+			inString = true
+		case b == '{' || b == '[':
+			depth++
+			// The root container is at depth 0
+			if err := limits.Check(limits.Depth, int64(depth-1), int64(max)); err != nil {
+				return err
+			}
+		case b == '}' || b == ']':
+			depth--
+		}
+	}
+	return nil
+}
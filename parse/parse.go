@@ -2,11 +2,16 @@
 package parse
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/BurntSushi/toml"
 	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/pfrederiksen/configdiff/limits"
 	"github.com/pfrederiksen/configdiff/tree"
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/gocty"
@@ -30,77 +35,114 @@ const (
 	FormatTOML Format = "toml"
 )
 
-// Parse parses configuration data in the specified format into a normalized tree.
+// Parse parses configuration data in the specified format into a normalized
+// tree, within limits.Default().
 func Parse(data []byte, format Format) (*tree.Node, error) {
+	return ParseContext(context.Background(), data, format, limits.Limits{})
+}
+
+// ParseContext is like Parse, but stops when ctx is done and fails with a
+// *limits.Error when the input exceeds lim. Zero fields of lim take their
+// value from limits.Default(); negative ones are not enforced.
+func ParseContext(ctx context.Context, data []byte, format Format, lim limits.Limits) (*tree.Node, error) {
+	lim = lim.WithDefaults()
+	if err := limits.Check(limits.InputBytes, int64(len(data)), lim.MaxInputBytes); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c := &converter{ctx: ctx, lim: lim}
+	var node *tree.Node
+	var err error
 	switch format {
 	case FormatYAML:
-		return ParseYAML(data)
+		node, err = c.parseYAML(data)
 	case FormatJSON:
-		return ParseJSON(data)
+		node, err = c.parseJSON(data)
 	case FormatHCL:
-		return ParseHCL(data)
+		node, err = c.parseHCL(data)
 	case FormatTOML:
-		return ParseTOML(data)
+		node, err = c.parseTOML(data)
 	default:
 		return nil, fmt.Errorf("unsupported format: %s", format)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Set canonical paths and cache hashes so diffs skip identical subtrees
+	node.SetPaths("/")
+	node.Rehash()
+	return node, nil
 }
 
 // ParseYAML parses YAML data into a normalized tree.
 func ParseYAML(data []byte) (*tree.Node, error) {
-	var v interface{}
-	if err := yaml.Unmarshal(data, &v); err != nil {
+	return Parse(data, FormatYAML)
+}
+
+// ParseJSON parses JSON data into a normalized tree.
+func ParseJSON(data []byte) (*tree.Node, error) {
+	return Parse(data, FormatJSON)
+}
+
+// ParseTOML parses TOML data into a normalized tree.
+func ParseTOML(data []byte) (*tree.Node, error) {
+	return Parse(data, FormatTOML)
+}
+
+// ParseHCL parses HCL data into a normalized tree.
+func ParseHCL(data []byte) (*tree.Node, error) {
+	return Parse(data, FormatHCL)
+}
+
+func (c *converter) parseYAML(data []byte) (*tree.Node, error) {
+	// Decode to a node graph; aliases are expanded by the converter
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
 		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
 
-	// YAML unmarshals into map[interface{}]interface{}, need to normalize
-	normalized := normalizeYAMLValue(v)
-	node, err := valueToNode(normalized)
+	node, err := c.yamlToNode(&doc, 0)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
-
-	// Set canonical paths
-	node.SetPaths("/")
 	return node, nil
 }
 
-// ParseJSON parses JSON data into a normalized tree.
-func ParseJSON(data []byte) (*tree.Node, error) {
+func (c *converter) parseJSON(data []byte) (*tree.Node, error) {
+	// The decoder recurses, so check nesting before decoding
+	if err := checkJSONDepth(data, c.lim.MaxDepth); err != nil {
+		return nil, err
+	}
+
+	// Decode numbers as json.Number to keep large integers exact
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
 	var v interface{}
-	if err := json.Unmarshal(data, &v); err != nil {
+	if err := dec.Decode(&v); err != nil {
 		return nil, fmt.Errorf("failed to parse JSON: %w", err)
 	}
-
-	node, err := valueToNode(v)
-	if err != nil {
-		return nil, err
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse JSON: unexpected data after top-level value")
 	}
 
-	// Set canonical paths
-	node.SetPaths("/")
-	return node, nil
+	return c.valueToNode(v, 0)
 }
 
-// ParseTOML parses TOML data into a normalized tree.
-func ParseTOML(data []byte) (*tree.Node, error) {
+func (c *converter) parseTOML(data []byte) (*tree.Node, error) {
 	var v interface{}
 	if err := toml.Unmarshal(data, &v); err != nil {
 		return nil, fmt.Errorf("failed to parse TOML: %w", err)
 	}
 
-	node, err := valueToNode(v)
-	if err != nil {
-		return nil, err
-	}
-
-	// Set canonical paths
-	node.SetPaths("/")
-	return node, nil
+	return c.valueToNode(v, 0)
 }
 
-// ParseHCL parses HCL data into a normalized tree.
-func ParseHCL(data []byte) (*tree.Node, error) {
+func (c *converter) parseHCL(data []byte) (*tree.Node, error) {
 	parser := hclparse.NewParser()
 	file, diags := parser.ParseHCL(data, "config.hcl")
 	if diags.HasErrors() {
@@ -127,14 +169,7 @@ func ParseHCL(data []byte) (*tree.Node, error) {
 		result[name] = goVal
 	}
 
-	node, err := valueToNode(result)
-	if err != nil {
-		return nil, err
-	}
-
-	// Set canonical paths
-	node.SetPaths("/")
-	return node, nil
+	return c.valueToNode(result, 0)
 }
 
 // ctyToGo converts a cty.Value to a Go interface{} value
@@ -153,10 +188,13 @@ func ctyToGo(val cty.Value) (interface{}, error) {
 		return result, nil
 
 	case typ == cty.Number:
-		var result float64
-		if err := gocty.FromCtyValue(val, &result); err != nil {
-			return nil, err
+		// Keep integers exact, cty numbers are arbitrary precision
+		bf := val.AsBigFloat()
+		if bf.IsInt() {
+			i, _ := bf.Int(nil)
+			return i, nil
 		}
+		result, _ := bf.Float64()
 		return result, nil
 
 	case typ == cty.String:
@@ -198,105 +236,6 @@ func ctyToGo(val cty.Value) (interface{}, error) {
 	}
 }
 
-// normalizeYAMLValue converts YAML's map[interface{}]interface{} to map[string]interface{}
-// for consistent handling with JSON.
-func normalizeYAMLValue(v interface{}) interface{} {
-	switch val := v.(type) {
-	case map[interface{}]interface{}:
-		normalized := make(map[string]interface{})
-		for k, v := range val {
-			keyStr := fmt.Sprintf("%v", k)
-			normalized[keyStr] = normalizeYAMLValue(v)
-		}
-		return normalized
-	case []interface{}:
-		normalized := make([]interface{}, len(val))
-		for i, item := range val {
-			normalized[i] = normalizeYAMLValue(item)
-		}
-		return normalized
-	default:
-		return val
-	}
-}
-
-// valueToNode converts a Go value to a tree.Node.
-func valueToNode(v interface{}) (*tree.Node, error) {
-	if v == nil {
-		return tree.NewNull(), nil
-	}
-
-	switch val := v.(type) {
-	case bool:
-		return tree.NewBool(val), nil
-
-	case int:
-		return tree.NewNumber(float64(val)), nil
-	case int8:
-		return tree.NewNumber(float64(val)), nil
-	case int16:
-		return tree.NewNumber(float64(val)), nil
-	case int32:
-		return tree.NewNumber(float64(val)), nil
-	case int64:
-		return tree.NewNumber(float64(val)), nil
-	case uint:
-		return tree.NewNumber(float64(val)), nil
-	case uint8:
-		return tree.NewNumber(float64(val)), nil
-	case uint16:
-		return tree.NewNumber(float64(val)), nil
-	case uint32:
-		return tree.NewNumber(float64(val)), nil
-	case uint64:
-		return tree.NewNumber(float64(val)), nil
-	case float32:
-		return tree.NewNumber(float64(val)), nil
-	case float64:
-		return tree.NewNumber(val), nil
-
-	case string:
-		return tree.NewString(val), nil
-
-	case map[string]interface{}:
-		obj := make(map[string]*tree.Node)
-		for k, v := range val {
-			node, err := valueToNode(v)
-			if err != nil {
-				return nil, err
-			}
-			obj[k] = node
-		}
-		return tree.NewObject(obj), nil
-
-	case []interface{}:
-		arr := make([]*tree.Node, len(val))
-		for i, item := range val {
-			node, err := valueToNode(item)
-			if err != nil {
-				return nil, err
-			}
-			arr[i] = node
-		}
-		return tree.NewArray(arr), nil
-
-	case []map[string]interface{}:
-		// TOML array of tables
-		arr := make([]*tree.Node, len(val))
-		for i, item := range val {
-			node, err := valueToNode(item)
-			if err != nil {
-				return nil, err
-			}
-			arr[i] = node
-		}
-		return tree.NewArray(arr), nil
-
-	default:
-		return nil, fmt.Errorf("unsupported value type: %T", v)
-	}
-}
-
 // DetectFormat attempts to detect the format based on content.
 // Returns the detected format or an error if detection fails.
 func DetectFormat(data []byte) (Format, error) {
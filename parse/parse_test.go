@@ -1,9 +1,15 @@
 package parse
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/pfrederiksen/configdiff/limits"
 	"github.com/pfrederiksen/configdiff/tree"
 )
 
@@ -49,7 +55,7 @@ func TestParseJSON(t *testing.T) {
 			input:   `42`,
 			wantErr: false,
 			check: func(t *testing.T, n *tree.Node) {
-				if n.Kind != tree.KindNumber || n.Value != 42.0 {
+				if n.Kind != tree.KindNumber || n.Value != int64(42) {
 					t.Errorf("Node = %v, want number 42", n.Value)
 				}
 			},
@@ -98,7 +104,7 @@ func TestParseJSON(t *testing.T) {
 				if n.Object["name"].Kind != tree.KindString || n.Object["name"].Value != "test" {
 					t.Errorf("name = %v, want 'test'", n.Object["name"].Value)
 				}
-				if n.Object["value"].Kind != tree.KindNumber || n.Object["value"].Value != 123.0 {
+				if n.Object["value"].Kind != tree.KindNumber || n.Object["value"].Value != int64(123) {
 					t.Errorf("value = %v, want 123", n.Object["value"].Value)
 				}
 			},
@@ -125,7 +131,7 @@ func TestParseJSON(t *testing.T) {
 					t.Fatalf("Array len = %v, want 3", len(n.Array))
 				}
 				for i := 0; i < 3; i++ {
-					expected := float64(i + 1)
+					expected := int64(i + 1)
 					if n.Array[i].Value != expected {
 						t.Errorf("Array[%d] = %v, want %v", i, n.Array[i].Value, expected)
 					}
@@ -151,7 +157,7 @@ func TestParseJSON(t *testing.T) {
 				if spec == nil {
 					t.Fatal("spec is nil")
 				}
-				if spec.Object["replicas"].Value != 3.0 {
+				if spec.Object["replicas"].Value != int64(3) {
 					t.Errorf("replicas = %v, want 3", spec.Object["replicas"].Value)
 				}
 				containers := spec.Object["containers"]
@@ -241,7 +247,7 @@ func TestParseYAML(t *testing.T) {
 			input:   `42`,
 			wantErr: false,
 			check: func(t *testing.T, n *tree.Node) {
-				if n.Kind != tree.KindNumber || n.Value != 42.0 {
+				if n.Kind != tree.KindNumber || n.Value != int64(42) {
 					t.Errorf("Node = %v, want number 42", n.Value)
 				}
 			},
@@ -272,7 +278,7 @@ value: 123`,
 				if n.Object["name"].Kind != tree.KindString || n.Object["name"].Value != "test" {
 					t.Errorf("name = %v, want 'test'", n.Object["name"].Value)
 				}
-				if n.Object["value"].Kind != tree.KindNumber || n.Object["value"].Value != 123.0 {
+				if n.Object["value"].Kind != tree.KindNumber || n.Object["value"].Value != int64(123) {
 					t.Errorf("value = %v, want 123", n.Object["value"].Value)
 				}
 			},
@@ -292,7 +298,7 @@ value: 123`,
 					t.Fatalf("Array len = %v, want 3", len(n.Array))
 				}
 				for i := 0; i < 3; i++ {
-					expected := float64(i + 1)
+					expected := int64(i + 1)
 					if n.Array[i].Value != expected {
 						t.Errorf("Array[%d] = %v, want %v", i, n.Array[i].Value, expected)
 					}
@@ -313,7 +319,7 @@ spec:
 				if spec == nil {
 					t.Fatal("spec is nil")
 				}
-				if spec.Object["replicas"].Value != 3.0 {
+				if spec.Object["replicas"].Value != int64(3) {
 					t.Errorf("replicas = %v, want 3", spec.Object["replicas"].Value)
 				}
 				containers := spec.Object["containers"]
@@ -428,7 +434,7 @@ func TestParse(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := Parse([]byte(tt.data), tt.format)
+			node, err := Parse([]byte(tt.data), tt.format)
 			if tt.wantErr {
 				if err == nil {
 					t.Error("Parse() expected error, got nil")
@@ -437,6 +443,9 @@ func TestParse(t *testing.T) {
 				if err != nil {
 					t.Errorf("Parse() error = %v", err)
 				}
+				if !node.Hashed() || !node.Object["key"].Hashed() {
+					t.Errorf("Parse() returned a tree without cached hashes")
+				}
 			}
 		})
 	}
@@ -514,7 +523,7 @@ func TestValueToNode_AllTypes(t *testing.T) {
 			value:   int(42),
 			wantErr: false,
 			check: func(t *testing.T, n *tree.Node) {
-				if n.Kind != tree.KindNumber || n.Value != 42.0 {
+				if n.Kind != tree.KindNumber || n.Value != int64(42) {
 					t.Errorf("int conversion failed: %v", n.Value)
 				}
 			},
@@ -524,7 +533,7 @@ func TestValueToNode_AllTypes(t *testing.T) {
 			value:   uint(42),
 			wantErr: false,
 			check: func(t *testing.T, n *tree.Node) {
-				if n.Kind != tree.KindNumber || n.Value != 42.0 {
+				if n.Kind != tree.KindNumber || n.Value != int64(42) {
 					t.Errorf("uint conversion failed: %v", n.Value)
 				}
 			},
@@ -548,7 +557,8 @@ func TestValueToNode_AllTypes(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			node, err := valueToNode(tt.value)
+			c := &converter{ctx: context.Background()}
+			node, err := c.valueToNode(tt.value, 0)
 			if tt.wantErr {
 				if err == nil {
 					t.Error("valueToNode() expected error, got nil")
@@ -565,59 +575,53 @@ func TestValueToNode_AllTypes(t *testing.T) {
 	}
 }
 
-func TestNormalizeYAMLValue(t *testing.T) {
+func TestParseYAML_Decoding(t *testing.T) {
 	tests := []struct {
 		name  string
-		input interface{}
-		check func(*testing.T, interface{})
+		input string
+		check func(*testing.T, *tree.Node)
 	}{
 		{
-			name:  "map[interface{}]interface{}",
-			input: map[interface{}]interface{}{"key": "value"},
-			check: func(t *testing.T, v interface{}) {
-				m, ok := v.(map[string]interface{})
-				if !ok {
-					t.Fatalf("expected map[string]interface{}, got %T", v)
+			name:  "non-string keys",
+			input: "1: one\ntrue: yes",
+			check: func(t *testing.T, n *tree.Node) {
+				if n.Object["1"] == nil || n.Object["1"].Value != "one" {
+					t.Errorf("key 1 = %v, want 'one'", n.Object["1"])
 				}
-				if m["key"] != "value" {
-					t.Errorf("value = %v, want 'value'", m["key"])
+				if n.Object["true"] == nil {
+					t.Errorf("key true missing")
 				}
 			},
 		},
 		{
-			name:  "nested map",
-			input: map[interface{}]interface{}{"outer": map[interface{}]interface{}{"inner": "value"}},
-			check: func(t *testing.T, v interface{}) {
-				m, ok := v.(map[string]interface{})
-				if !ok {
-					t.Fatalf("expected map[string]interface{}, got %T", v)
+			name:  "aliases and merge keys",
+			input: "base: &base\n  x: 1\n  y: 2\nother:\n  <<: *base\n  y: 3\nlist:\n  - *base",
+			check: func(t *testing.T, n *tree.Node) {
+				other := n.Object["other"]
+				if other.Object["x"].Value != int64(1) || other.Object["y"].Value != int64(3) {
+					t.Errorf("merged object = %v, %v, want 1, 3", other.Object["x"].Value, other.Object["y"].Value)
 				}
-				outer := m["outer"].(map[string]interface{})
-				if outer["inner"] != "value" {
-					t.Errorf("inner value = %v, want 'value'", outer["inner"])
+				if !n.Object["list"].Array[0].Equal(n.Object["base"]) {
+					t.Errorf("alias in list does not match anchor")
 				}
 			},
 		},
 		{
-			name:  "array with maps",
-			input: []interface{}{map[interface{}]interface{}{"key": "value"}},
-			check: func(t *testing.T, v interface{}) {
-				arr, ok := v.([]interface{})
-				if !ok {
-					t.Fatalf("expected []interface{}, got %T", v)
-				}
-				m := arr[0].(map[string]interface{})
-				if m["key"] != "value" {
-					t.Errorf("value = %v, want 'value'", m["key"])
+			name:  "merge sequence prefers earlier maps",
+			input: "a: &a {x: 1, y: 1}\nb: &b {y: 2, z: 2}\nc:\n  <<: [*a, *b]\n  z: 3",
+			check: func(t *testing.T, n *tree.Node) {
+				c := n.Object["c"]
+				if c.Object["x"].Value != int64(1) || c.Object["y"].Value != int64(1) || c.Object["z"].Value != int64(3) {
+					t.Errorf("merged object = %v, %v, %v, want 1, 1, 3", c.Object["x"].Value, c.Object["y"].Value, c.Object["z"].Value)
 				}
 			},
 		},
 		{
-			name:  "passthrough scalar",
-			input: "string",
-			check: func(t *testing.T, v interface{}) {
-				if v != "string" {
-					t.Errorf("value = %v, want 'string'", v)
+			name:  "nested arrays of maps",
+			input: "items:\n  - key: value",
+			check: func(t *testing.T, n *tree.Node) {
+				if n.Object["items"].Array[0].Object["key"].Value != "value" {
+					t.Errorf("value = %v, want 'value'", n.Object["items"].Array[0].Object["key"].Value)
 				}
 			},
 		},
@@ -625,11 +629,199 @@ func TestNormalizeYAMLValue(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := normalizeYAMLValue(tt.input)
-			tt.check(t, result)
+			node, err := ParseYAML([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("ParseYAML() error = %v", err)
+			}
+			tt.check(t, node)
+		})
+	}
+}
+
+func TestParseYAML_DecodingErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"duplicate key", "a: 1\na: 2", `mapping key "a" already defined at line 1`},
+		{"merge of scalar", "a:\n  <<: 1", "map merge requires map"},
+		{"sequence key", "[a]: 1", "invalid map key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseYAML([]byte(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseYAML() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseContext_Limits(t *testing.T) {
+	// Each level of the bomb refers to the previous one nine times
+	bomb := "a0: &a0 [x]\n"
+	for i := 1; i <= 9; i++ {
+		bomb += fmt.Sprintf("a%d: &a%d [", i, i)
+		for j := 0; j < 9; j++ {
+			if j > 0 {
+				bomb += ", "
+			}
+			bomb += fmt.Sprintf("*a%d", i-1)
+		}
+		bomb += "]\n"
+	}
+
+	tests := []struct {
+		name   string
+		input  string
+		format Format
+		lim    limits.Limits
+		want   string // limit exceeded, or "" for none
+	}{
+		{"within limits", `{"a": [1, 2]}`, FormatJSON, limits.Default(), ""},
+		{"input bytes", `{"a": 1}`, FormatJSON, limits.Limits{MaxInputBytes: 4}, limits.InputBytes},
+		{"json depth", `{"a": {"b": {"c": 1}}}`, FormatJSON, limits.Limits{MaxDepth: 2}, limits.Depth},
+		{"json depth ignores strings", `{"a": "{{{{"}`, FormatJSON, limits.Limits{MaxDepth: 1}, ""},
+		{"yaml depth", "a:\n  b:\n    c: 1", FormatYAML, limits.Limits{MaxDepth: 2}, limits.Depth},
+		{"toml nodes", "a = [1, 2, 3]", FormatTOML, limits.Limits{MaxNodes: 4}, limits.Nodes},
+		{"alias bomb", bomb, FormatYAML, limits.Default(), limits.Aliases},
+		{"alias bomb with zero limits", bomb, FormatYAML, limits.Limits{}, limits.Aliases},
+		{"alias bomb with unlimited aliases", bomb, FormatYAML, limits.Limits{MaxAliases: -1, MaxNodes: 1000}, limits.Nodes},
+		{"alias bomb nodes", bomb, FormatYAML, limits.Limits{MaxNodes: 1000}, limits.Nodes},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseContext(context.Background(), []byte(tt.input), tt.format, tt.lim)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("ParseContext() error = %v", err)
+				}
+				return
+			}
+
+			var limErr *limits.Error
+			if !errors.As(err, &limErr) || limErr.Limit != tt.want {
+				t.Fatalf("ParseContext() error = %v, want %s limit exceeded", err, tt.want)
+			}
+			if !errors.Is(err, limits.ErrExceeded) {
+				t.Errorf("errors.Is(err, limits.ErrExceeded) = false")
+			}
+		})
+	}
+}
+
+func TestParse_AliasBomb(t *testing.T) {
+	// The classic billion laughs: ten references per level, nine levels
+	var b strings.Builder
+	b.WriteString("lol0: &lol0 lol\n")
+	for i := 1; i <= 9; i++ {
+		fmt.Fprintf(&b, "lol%d: &lol%d [", i, i)
+		for j := 0; j < 10; j++ {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "*lol%d", i-1)
+		}
+		b.WriteString("]\n")
+	}
+
+	_, err := Parse([]byte(b.String()), FormatYAML)
+	if !errors.Is(err, limits.ErrExceeded) {
+		t.Errorf("Parse() error = %v, want limits.ErrExceeded", err)
+	}
+}
+
+func TestParseYAML_LargeMapping(t *testing.T) {
+	// Duplicate keys must be found in linear time
+	var b strings.Builder
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&b, "key%d: %d\n", i, i)
+	}
+	valid := b.String()
+
+	start := time.Now()
+	node, err := ParseContext(context.Background(), []byte(valid), FormatYAML, limits.Default())
+	if err != nil {
+		t.Fatalf("ParseContext() error = %v", err)
+	}
+	if len(node.Object) != 100000 {
+		t.Errorf("ParseContext() = %d keys, want 100000", len(node.Object))
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("ParseContext() took %v, want linear time", elapsed)
+	}
+
+	_, err = ParseContext(context.Background(), []byte(valid+"key5: again\n"), FormatYAML, limits.Default())
+	if err == nil || !strings.Contains(err.Error(), `mapping key "key5" already defined at line 6`) {
+		t.Errorf("ParseContext() error = %v, want duplicate key5", err)
+	}
+}
+
+func TestParseContext_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := ParseContext(ctx, []byte("a: 1"), FormatYAML, limits.Limits{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ParseContext() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestParse_Numbers(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+		want   string
+		exact  bool
+	}{
+		{"json int", FormatJSON, `{"n": 9007199254740993}`, "9007199254740993", true},
+		{"json big int", FormatJSON, `{"n": 123456789012345678901234567890}`, "123456789012345678901234567890", true},
+		{"json float", FormatJSON, `{"n": 0.5}`, "0.5", false},
+		{"yaml int", FormatYAML, "n: 9007199254740993", "9007199254740993", true},
+		{"yaml big int", FormatYAML, "n: 123456789012345678901234567890", "123456789012345678901234567890", true},
+		{"yaml hex", FormatYAML, "n: 0x1F", "31", true},
+		{"yaml octal", FormatYAML, "n: 0755", "493", true},
+		{"yaml octal 1.2", FormatYAML, "n: 0o755", "493", true},
+		{"yaml underscores", FormatYAML, "n: 1_000", "1000", true},
+		{"yaml signed", FormatYAML, "n: +42", "42", true},
+		{"yaml exponent", FormatYAML, "n: 1e3", "1000", false},
+		{"yaml float", FormatYAML, "n: 1.5", "1.5", false},
+		{"toml int", FormatTOML, "n = 9007199254740993", "9007199254740993", true},
+		{"hcl int", FormatHCL, "n = 9007199254740993", "9007199254740993", true},
+		{"hcl float", FormatHCL, "n = 2.5", "2.5", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := Parse([]byte(tt.input), tt.format)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			n := node.Object["n"]
+			if n.Kind != tree.KindNumber {
+				t.Fatalf("Kind = %v, want number", n.Kind)
+			}
+			if got := n.NumberString(); got != tt.want {
+				t.Errorf("NumberString() = %s, want %s", got, tt.want)
+			}
+			if _, isFloat := n.Value.(float64); isFloat == tt.exact {
+				t.Errorf("Value type = %T, want exact integer %v", n.Value, tt.exact)
+			}
 		})
 	}
+
+	// Integers that collide as float64 must stay distinct
+	a, _ := ParseJSON([]byte(`9007199254740992`))
+	b, _ := ParseJSON([]byte(`9007199254740993`))
+	if a.Equal(b) {
+		t.Error("9007199254740992 and 9007199254740993 should not be equal")
+	}
 }
+
 func TestParseHCL(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -671,7 +863,7 @@ func TestParseHCL(t *testing.T) {
 				if n.Kind != tree.KindObject {
 					t.Fatalf("Kind = %v, want object", n.Kind)
 				}
-				if n.Object["count"].Kind != tree.KindNumber || n.Object["count"].Value != 42.0 {
+				if n.Object["count"].Kind != tree.KindNumber || n.Object["count"].Value != int64(42) {
 					t.Errorf("count = %v, want number 42", n.Object["count"].Value)
 				}
 			},
@@ -720,7 +912,7 @@ func TestParseHCL(t *testing.T) {
 				if configNode.Object["host"].Kind != tree.KindString || configNode.Object["host"].Value != "localhost" {
 					t.Errorf("config.host = %v, want 'localhost'", configNode.Object["host"].Value)
 				}
-				if configNode.Object["port"].Kind != tree.KindNumber || configNode.Object["port"].Value != 8080.0 {
+				if configNode.Object["port"].Kind != tree.KindNumber || configNode.Object["port"].Value != int64(8080) {
 					t.Errorf("config.port = %v, want 8080", configNode.Object["port"].Value)
 				}
 			},
@@ -958,7 +1150,7 @@ func TestParseTOML(t *testing.T) {
 				if !ok {
 					t.Fatal("Expected 'port' key not found")
 				}
-				if port.Kind != tree.KindNumber || port.Value != float64(5432) {
+				if port.Kind != tree.KindNumber || port.Value != int64(5432) {
 					t.Errorf("port = %v, want number 5432", port.Value)
 				}
 			},
@@ -31,6 +31,10 @@ type Operation struct {
 
 	// From is the source path for move/copy operations.
 	From string `json:"from,omitempty"`
+
+	// Severity is copied from the change the operation was made from
+	// (extension, optional).
+	Severity diff.Severity `json:"severity,omitempty"`
 }
 
 // FromChanges converts a list of changes into a patch.
@@ -38,10 +42,16 @@ func FromChanges(changes []diff.Change) (*Patch, error) {
 	ops := make([]Operation, 0, len(changes))
 
 	for _, change := range changes {
+		// Values equal after normalization need no operation
+		if change.Type == diff.ChangeTypeNormalized {
+			continue
+		}
+
 		op, err := changeToOperation(change)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert change at %s: %w", change.Path, err)
 		}
+		op.Severity = change.Severity
 		ops = append(ops, op)
 	}
 
@@ -80,10 +90,9 @@ func changeToOperation(change diff.Change) (Operation, error) {
 		}, nil
 
 	case diff.ChangeTypeMove:
-		// For move operations, we need both from and to paths
-		// This is currently not fully implemented in the diff engine
 		return Operation{
-			Op:   "replace",
+			Op:   "move",
+			From: change.From,
 			Path: change.Path,
 		}, nil
 
@@ -21,6 +21,39 @@ func TestFromChanges(t *testing.T) {
 			changes: []diff.Change{},
 			wantOps: 0,
 		},
+		{
+			name: "normalized values need no operation",
+			changes: []diff.Change{
+				{
+					Type:     diff.ChangeTypeNormalized,
+					Path:     "/cidr",
+					OldValue: tree.NewString("10.0.0.1/8"),
+					NewValue: tree.NewString("10.0.0.0/8"),
+				},
+			},
+			wantOps: 0,
+		},
+		{
+			name: "move",
+			changes: []diff.Change{
+				{
+					Type:     diff.ChangeTypeMove,
+					Path:     "/database_host",
+					From:     "/db_host",
+					OldValue: tree.NewString("db"),
+					NewValue: tree.NewString("db"),
+				},
+			},
+			wantOps: 1,
+			checkOps: func(t *testing.T, ops []Operation) {
+				if ops[0].Op != "move" || ops[0].From != "/db_host" || ops[0].Path != "/database_host" {
+					t.Errorf("op = %+v, want move from /db_host to /database_host", ops[0])
+				}
+				if err := ops[0].Validate(); err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+			},
+		},
 		{
 			name: "single add",
 			changes: []diff.Change{
@@ -0,0 +1,179 @@
+package policy
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/diff"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed packs/*.yaml
+var packs embed.FS
+
+// Classifier assigns a category and severity to changes. A classifier file
+// lists rules in YAML, matched like policy rules:
+//
+//	rules:
+//	  - name: scale-down
+//	    path: /spec/replicas
+//	    when: new < old
+//	    category: scaling
+//	    severity: warning
+//	  - name: labels
+//	    path: /metadata/labels/*
+//	    category: metadata
+//	    severity: info
+//
+// The first matching rule classifies a change.
+type Classifier struct {
+	Rules []ClassRule `yaml:"rules"`
+}
+
+// ClassRule classifies the changes it matches.
+type ClassRule struct {
+	// Name identifies the rule.
+	Name string `yaml:"name"`
+
+	// Match selects the changes the rule classifies.
+	Match `yaml:",inline"`
+
+	// Category assigned to matching changes, e.g. "security".
+	Category string `yaml:"category"`
+
+	// Severity assigned to matching changes: info, warn or error.
+	Severity diff.Severity `yaml:"severity"`
+}
+
+// LoadClassifier reads a classifier file.
+func LoadClassifier(path string) (*Classifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read classifier %q: %w", path, err)
+	}
+
+	c, err := ParseClassifier(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load classifier %q: %w", path, err)
+	}
+	return c, nil
+}
+
+// ParseClassifier parses and checks a classifier in YAML.
+func ParseClassifier(data []byte) (*Classifier, error) {
+	var c Classifier
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse classifier: %w", err)
+	}
+
+	for i := range c.Rules {
+		if err := c.Rules[i].compile(i); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+// Pack returns a built-in classifier: "kubernetes" or "terraform".
+func Pack(name string) (*Classifier, error) {
+	data, err := packs.ReadFile(path.Join("packs", name+".yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown rule pack %q, expected one of %s", name, strings.Join(PackNames(), ", "))
+	}
+
+	c, err := ParseClassifier(data)
+	if err != nil {
+		return nil, fmt.Errorf("rule pack %q: %w", name, err)
+	}
+	return c, nil
+}
+
+// PackNames returns the names of the built-in classifiers.
+func PackNames() []string {
+	entries, _ := packs.ReadDir("packs")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadClassifiers combines classifiers given as built-in pack names or
+// files, in order, so that earlier rules take precedence.
+func LoadClassifiers(names []string) (*Classifier, error) {
+	var combined Classifier
+	for _, name := range names {
+		var c *Classifier
+		var err error
+		if _, statErr := os.Stat(name); statErr != nil && isPackName(name) {
+			c, err = Pack(name)
+		} else {
+			c, err = LoadClassifier(name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		combined.Rules = append(combined.Rules, c.Rules...)
+	}
+	return &combined, nil
+}
+
+// isPackName reports whether name is a built-in pack.
+func isPackName(name string) bool {
+	for _, n := range PackNames() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// compile checks the rule at index i, fills in defaults and compiles its
+// `when` expression.
+func (r *ClassRule) compile(i int) error {
+	if r.Name == "" {
+		r.Name = fmt.Sprintf("rule %d", i+1)
+	}
+
+	if r.Severity != "" {
+		s, err := diff.ParseSeverity(string(r.Severity))
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.Name, err)
+		}
+		r.Severity = s
+	}
+
+	if err := r.Match.compile(); err != nil {
+		return fmt.Errorf("%s: %w", r.Name, err)
+	}
+
+	if r.Category == "" && r.Severity == "" {
+		return fmt.Errorf("%s: needs a category or severity", r.Name)
+	}
+	return nil
+}
+
+// Classify sets the category of each change matched by a rule and raises
+// its severity to the rule's. The first matching rule wins; values equal
+// after normalization are not classified.
+func (c *Classifier) Classify(changes []diff.Change) {
+	for i := range changes {
+		change := &changes[i]
+		for j := range c.Rules {
+			rule := &c.Rules[j]
+			if !rule.Matches(change) {
+				continue
+			}
+			if rule.Category != "" {
+				change.Category = rule.Category
+			}
+			change.Severity = change.Severity.Max(rule.Severity)
+			break
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+func TestClassifier_Classify(t *testing.T) {
+	c, err := ParseClassifier([]byte(`
+rules:
+  - path: /spec/replicas
+    when: new < old
+    category: scaling
+    severity: warn
+  - path: /spec/replicas
+    category: scaling
+  - path: /metadata/*
+    category: metadata
+    severity: info
+  - path: /spec/paused
+    severity: error
+`))
+	if err != nil {
+		t.Fatalf("ParseClassifier() error = %v", err)
+	}
+
+	changes := []diff.Change{
+		modify("/spec/replicas", tree.NewInt(3), tree.NewInt(2)),
+		modify("/metadata/labels/team", tree.NewString("a"), tree.NewString("b")),
+		modify("/spec/paused", tree.NewBool(false), tree.NewBool(true)),
+		modify("/data/key", tree.NewString("a"), tree.NewString("b")),
+		{Type: diff.ChangeTypeNormalized, Path: "/metadata/name", OldValue: tree.NewString("a"), NewValue: tree.NewString("a")},
+	}
+	changes[1].Severity = diff.SeverityError
+	c.Classify(changes)
+
+	want := []struct {
+		category string
+		severity diff.Severity
+	}{
+		{"scaling", diff.SeverityWarning},
+		{"metadata", diff.SeverityError},
+		{"", diff.SeverityError},
+		{"", ""},
+		{"", ""},
+	}
+	for i, w := range want {
+		if changes[i].Category != w.category || changes[i].Severity != w.severity {
+			t.Errorf("change %s = (%q, %q), want (%q, %q)", changes[i].Path,
+				changes[i].Category, changes[i].Severity, w.category, w.severity)
+		}
+	}
+
+	up := []diff.Change{modify("/spec/replicas", tree.NewInt(2), tree.NewInt(3))}
+	c.Classify(up)
+	if up[0].Category != "scaling" || up[0].Severity != "" {
+		t.Errorf("scale up = (%q, %q), want (scaling, none)", up[0].Category, up[0].Severity)
+	}
+}
+
+func TestParseClassifier_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{"no category or severity", "rules:\n  - path: /a\n"},
+		{"bad severity", "rules:\n  - path: /a\n    severity: fatal\n"},
+		{"bad when", "rules:\n  - path: /a\n    category: x\n    when: new <\n"},
+		{"bad change", "rules:\n  - change: rename\n    category: x\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseClassifier([]byte(tt.yaml)); err == nil {
+				t.Error("ParseClassifier() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestPack(t *testing.T) {
+	k8s, err := Pack("kubernetes")
+	if err != nil {
+		t.Fatalf("Pack(kubernetes) error = %v", err)
+	}
+
+	changes := []diff.Change{
+		modify("/spec/template/spec/containers[0]/image", tree.NewString("web:1"), tree.NewString("web:latest")),
+		modify("/spec/template/spec/containers[0]/image", tree.NewString("web:1"), tree.NewString("web:2")),
+		modify("/spec/replicas", tree.NewInt(3), tree.NewInt(1)),
+		modify("/spec/template/spec/securityContext/runAsNonRoot", tree.NewBool(true), tree.NewBool(false)),
+		modify("/metadata/labels/team", tree.NewString("a"), tree.NewString("b")),
+	}
+	k8s.Classify(changes)
+
+	want := []struct {
+		category string
+		severity diff.Severity
+	}{
+		{"image", diff.SeverityWarning},
+		{"image", diff.SeverityInfo},
+		{"scaling", diff.SeverityWarning},
+		{"security", diff.SeverityError},
+		{"metadata", diff.SeverityInfo},
+	}
+	for i, w := range want {
+		if changes[i].Category != w.category || changes[i].Severity != w.severity {
+			t.Errorf("change %d %s = (%q, %q), want (%q, %q)", i, changes[i].Path,
+				changes[i].Category, changes[i].Severity, w.category, w.severity)
+		}
+	}
+
+	tf, err := Pack("terraform")
+	if err != nil {
+		t.Fatalf("Pack(terraform) error = %v", err)
+	}
+	ami := []diff.Change{modify("/resource/aws_instance/web/ami", tree.NewString("ami-1"), tree.NewString("ami-2"))}
+	tf.Classify(ami)
+	if ami[0].Category != "image" {
+		t.Errorf("terraform ami category = %q, want image", ami[0].Category)
+	}
+
+	if _, err := Pack("nomad"); err == nil {
+		t.Error("Pack(nomad) error = nil, want error")
+	}
+}
+
+func TestLoadClassifiers(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(file, []byte("rules:\n  - path: /spec/replicas\n    category: capacity\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadClassifiers([]string{file, "kubernetes"})
+	if err != nil {
+		t.Fatalf("LoadClassifiers() error = %v", err)
+	}
+
+	changes := []diff.Change{modify("/spec/replicas", tree.NewInt(3), tree.NewInt(1))}
+	c.Classify(changes)
+	if changes[0].Category != "capacity" {
+		t.Errorf("category = %q, want capacity from the first classifier", changes[0].Category)
+	}
+
+	if _, err := LoadClassifiers([]string{filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Error("LoadClassifiers() with a missing file error = nil, want error")
+	}
+}
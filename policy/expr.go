@@ -0,0 +1,346 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// expr is a compiled `when` expression. The grammar is
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | "(" or ")" | compare
+//	compare = operand [ op operand ]
+//	op      = "==" | "!=" | "<" | "<=" | ">" | ">=" | "matches" | "contains"
+//	operand = "old" | "new" | "path" | "type" | number | string | "true" | "false" | "null"
+//
+// old and new are the values before and after the change, null when absent.
+type expr interface {
+	eval(c *diff.Change) bool
+}
+
+type orExpr struct{ left, right expr }
+
+func (e orExpr) eval(c *diff.Change) bool { return e.left.eval(c) || e.right.eval(c) }
+
+type andExpr struct{ left, right expr }
+
+func (e andExpr) eval(c *diff.Change) bool { return e.left.eval(c) && e.right.eval(c) }
+
+type notExpr struct{ inner expr }
+
+func (e notExpr) eval(c *diff.Change) bool { return !e.inner.eval(c) }
+
+// truthExpr is an operand used on its own, true when it is the boolean true.
+type truthExpr struct{ operand operand }
+
+func (e truthExpr) eval(c *diff.Change) bool {
+	v := e.operand(c)
+	return v.Kind == tree.KindBool && v.Value == true
+}
+
+// compareExpr compares two operands.
+type compareExpr struct {
+	op          string
+	left, right operand
+	re          *regexp.Regexp
+}
+
+func (e compareExpr) eval(c *diff.Change) bool {
+	l := e.left(c)
+	if e.op == "matches" {
+		s, ok := scalarString(l)
+		return ok && e.re.MatchString(s)
+	}
+
+	r := e.right(c)
+	switch e.op {
+	case "==":
+		return l.Equal(r)
+	case "!=":
+		return !l.Equal(r)
+	case "contains":
+		s, ok := scalarString(l)
+		sub, subOK := scalarString(r)
+		return ok && subOK && strings.Contains(s, sub)
+	}
+
+	cmp, ok := order(l, r)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// operand produces a value for a change.
+type operand func(c *diff.Change) *tree.Node
+
+// order compares two numbers or two strings.
+func order(a, b *tree.Node) (int, bool) {
+	if a.Kind == tree.KindNumber && b.Kind == tree.KindNumber {
+		return tree.CompareNumbers(a, b)
+	}
+	if a.Kind == tree.KindString && b.Kind == tree.KindString {
+		return strings.Compare(a.Value.(string), b.Value.(string)), true
+	}
+	return 0, false
+}
+
+// scalarString returns the text of a string, number or bool.
+func scalarString(n *tree.Node) (string, bool) {
+	switch n.Kind {
+	case tree.KindString:
+		return n.Value.(string), true
+	case tree.KindNumber:
+		return n.NumberString(), true
+	case tree.KindBool:
+		return strconv.FormatBool(n.Value.(bool)), true
+	default:
+		return "", false
+	}
+}
+
+// orNull returns n, or null for a missing value.
+func orNull(n *tree.Node) *tree.Node {
+	if n == nil {
+		return tree.NewNull()
+	}
+	return n
+}
+
+// compileExpr parses a `when` expression.
+func compileExpr(src string) (expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return e, nil
+}
+
+// token is a lexical token of an expression.
+type token struct {
+	text   string
+	quoted bool // a string literal, text is unquoted
+}
+
+// tokenize splits an expression into tokens.
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		r := rune(src[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(src) && src[end] != src[i] {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			text := src[i+1 : end]
+			if r == '"' {
+				unquoted, err := strconv.Unquote(src[i : end+1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid string %s", src[i:end+1])
+				}
+				text = unquoted
+			}
+			tokens = append(tokens, token{text: text, quoted: true})
+			i = end + 1
+
+		case strings.ContainsRune("()!<>=&|", r):
+			op := operatorAt(src[i:])
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at offset %d", r, i)
+			}
+			tokens = append(tokens, token{text: op})
+			i += len(op)
+
+		default:
+			end := i
+			for end < len(src) && !unicode.IsSpace(rune(src[end])) && !strings.ContainsRune("()!<>=&|\"'", rune(src[end])) {
+				end++
+			}
+			tokens = append(tokens, token{text: src[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// operatorAt returns the operator at the start of s, or "".
+func operatorAt(s string) string {
+	for _, op := range []string{"&&", "||", "==", "!=", "<=", ">=", "(", ")", "!", "<", ">"} {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// exprParser is a recursive descent parser over tokens.
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+// peek returns the next unquoted token text, or "".
+func (p *exprParser) peek() string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return ""
+	}
+	return p.tokens[p.pos].text
+}
+
+func (p *exprParser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (expr, error) {
+	switch p.peek() {
+	case "!":
+		p.pos++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{inner}, nil
+
+	case "(":
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return inner, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	op := p.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "matches", "contains":
+		p.pos++
+	default:
+		return truthExpr{left}, nil
+	}
+
+	if op == "matches" {
+		if p.pos >= len(p.tokens) || !p.tokens[p.pos].quoted {
+			return nil, fmt.Errorf("matches needs a quoted regular expression")
+		}
+		re, err := regexp.Compile(p.tokens[p.pos].text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		p.pos++
+		return compareExpr{op: op, left: left, re: re}, nil
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareExpr{op: op, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseOperand() (operand, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	if tok.quoted {
+		n := tree.NewString(tok.text)
+		return func(*diff.Change) *tree.Node { return n }, nil
+	}
+
+	switch tok.text {
+	case "old":
+		return func(c *diff.Change) *tree.Node { return orNull(c.OldValue) }, nil
+	case "new":
+		return func(c *diff.Change) *tree.Node { return orNull(c.NewValue) }, nil
+	case "path":
+		return func(c *diff.Change) *tree.Node { return tree.NewString(c.Path) }, nil
+	case "type":
+		return func(c *diff.Change) *tree.Node { return tree.NewString(string(c.Type)) }, nil
+	case "true", "false":
+		n := tree.NewBool(tok.text == "true")
+		return func(*diff.Change) *tree.Node { return n }, nil
+	case "null":
+		n := tree.NewNull()
+		return func(*diff.Change) *tree.Node { return n }, nil
+	}
+
+	n, err := tree.ParseNumber(tok.text)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected %q, expected old, new, path, type or a literal", tok.text)
+	}
+	return func(*diff.Change) *tree.Node { return n }, nil
+}
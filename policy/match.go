@@ -0,0 +1,112 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/selector"
+	"gopkg.in/yaml.v3"
+)
+
+// Match selects changes by path pattern, change type and `when` expression.
+// The zero value matches every change.
+type Match struct {
+	// Path is a selector, matched like IgnorePaths, e.g.
+	// "/spec/containers[*]/image" or "/**/securityContext/*". Empty
+	// matches every change.
+	Path string `yaml:"path"`
+
+	// Change limits the match to these change types (add, remove, modify,
+	// move). Empty matches all of them.
+	Change ChangeTypes `yaml:"change"`
+
+	// When is an expression over old, new, path and type that the change
+	// must satisfy, e.g. `new < old` or `new matches ":latest$"`.
+	When string `yaml:"when"`
+
+	path *selector.Selector
+	when expr
+}
+
+// IsZero reports whether m matches every change.
+func (m *Match) IsZero() bool {
+	return m.Path == "" && len(m.Change) == 0 && m.When == ""
+}
+
+// compile compiles the path selector and the `when` expression.
+func (m *Match) compile() error {
+	if m.Path != "" {
+		s, err := selector.Compile(m.Path)
+		if err != nil {
+			return err
+		}
+		m.path = s
+	}
+
+	if m.When == "" {
+		return nil
+	}
+	e, err := compileExpr(m.When)
+	if err != nil {
+		return fmt.Errorf("invalid when %q: %w", m.When, err)
+	}
+	m.when = e
+	return nil
+}
+
+// Matches reports whether change c is selected. Values equal after
+// normalization are not changes and never match.
+func (m *Match) Matches(c *diff.Change) bool {
+	if c.Type == diff.ChangeTypeNormalized {
+		return false
+	}
+	if m.path != nil && !m.path.Match(c.Path) {
+		return false
+	}
+	if len(m.Change) > 0 && !m.Change.contains(c.Type) {
+		return false
+	}
+	return m.when == nil || m.when.eval(c)
+}
+
+// ChangeTypes is a list of change types, written in YAML as one type or a list.
+type ChangeTypes []diff.ChangeType
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (t *ChangeTypes) UnmarshalYAML(n *yaml.Node) error {
+	var names []string
+	if n.Kind == yaml.ScalarNode {
+		names = []string{n.Value}
+	} else if err := n.Decode(&names); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		ct := diff.ChangeType(strings.ToLower(strings.TrimSpace(name)))
+		switch ct {
+		case diff.ChangeTypeAdd, diff.ChangeTypeRemove, diff.ChangeTypeModify, diff.ChangeTypeMove:
+			*t = append(*t, ct)
+		default:
+			return fmt.Errorf("unknown change type %q, expected add, remove, modify or move", name)
+		}
+	}
+	return nil
+}
+
+// contains reports whether ct is in the list.
+func (t ChangeTypes) contains(ct diff.ChangeType) bool {
+	for _, c := range t {
+		if c == ct {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPath reports whether a change path matches a rule pattern. See
+// package selector for the pattern syntax: "/containers/*/image" matches
+// "/containers[0]/image" and "/containers[name=web]/image".
+func MatchPath(path, pattern string) bool {
+	return selector.Match(path, pattern)
+}
@@ -0,0 +1,217 @@
+// Package policy evaluates declarative rules against the changes of a diff,
+// so that CI can fail on specific kinds of changes instead of on any change.
+//
+// A policy file lists rules in YAML:
+//
+//	rules:
+//	  - name: no-scale-down
+//	    path: /spec/replicas
+//	    change: modify
+//	    when: new < old
+//	    severity: error
+//	    message: replicas must not decrease
+//	  - name: data-only
+//	    allow: [/data/*]
+//	    severity: warn
+//
+// A rule is violated by every change matching its path, change types and
+// `when` expression. Rules with an allow list are instead violated by every
+// change outside the allowed paths.
+//
+// Classifiers use the same matching to assign a category and severity to
+// changes instead. Built-in rule packs cover Kubernetes and Terraform.
+package policy
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/selector"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is a set of rules.
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule flags changes matching a path pattern, change types and an optional
+// `when` expression.
+type Rule struct {
+	// Name identifies the rule in violations.
+	Name string `yaml:"name"`
+
+	// Match selects the changes the rule applies to.
+	Match `yaml:",inline"`
+
+	// Allow turns the rule into an allow list: changes matching none of
+	// these path patterns are violations.
+	Allow []string `yaml:"allow"`
+
+	// Severity of violations: info, warn or error. Defaults to error.
+	Severity diff.Severity `yaml:"severity"`
+
+	// Message describes violations. Defaults to a description of the rule.
+	Message string `yaml:"message"`
+
+	allow []*selector.Selector
+}
+
+// Violation is a change that breaks a rule.
+type Violation struct {
+	// Rule is the name of the broken rule.
+	Rule string `json:"rule"`
+
+	// Severity is the severity of the rule.
+	Severity diff.Severity `json:"severity"`
+
+	// Path is the path of the change.
+	Path string `json:"path"`
+
+	// Message describes the violation.
+	Message string `json:"message"`
+
+	// File is the file of the change in directory comparisons (optional).
+	File string `json:"file,omitempty"`
+}
+
+// Load reads a policy file.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy %q: %w", path, err)
+	}
+
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policy %q: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse parses and checks a policy in YAML.
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	for i := range p.Rules {
+		if err := p.Rules[i].compile(i); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// compile checks the rule at index i, fills in defaults and compiles its
+// `when` expression.
+func (r *Rule) compile(i int) error {
+	if r.Name == "" {
+		r.Name = fmt.Sprintf("rule %d", i+1)
+	}
+
+	if r.Severity == "" {
+		r.Severity = diff.SeverityError
+	} else {
+		s, err := diff.ParseSeverity(string(r.Severity))
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.Name, err)
+		}
+		r.Severity = s
+	}
+
+	if err := r.Match.compile(); err != nil {
+		return fmt.Errorf("%s: %w", r.Name, err)
+	}
+
+	r.allow = make([]*selector.Selector, 0, len(r.Allow))
+	for _, pattern := range r.Allow {
+		s, err := selector.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.Name, err)
+		}
+		r.allow = append(r.allow, s)
+	}
+
+	if r.Match.IsZero() && len(r.Allow) == 0 {
+		return fmt.Errorf("%s: needs a path, change, when or allow", r.Name)
+	}
+	return nil
+}
+
+// Evaluate returns the violations of changes, in rule order. Values equal
+// after normalization are not changes and never violate a rule.
+func (p *Policy) Evaluate(changes []diff.Change) []Violation {
+	var violations []Violation
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		for j := range changes {
+			if c := &changes[j]; rule.violatedBy(c) {
+				violations = append(violations, Violation{
+					Rule:     rule.Name,
+					Severity: rule.Severity,
+					Path:     c.Path,
+					Message:  rule.message(),
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// Apply evaluates changes and raises the severity of each violating change
+// to the severity of the rules it breaks.
+func (p *Policy) Apply(changes []diff.Change) []Violation {
+	violations := p.Evaluate(changes)
+
+	severities := make(map[string]diff.Severity, len(violations))
+	for _, v := range violations {
+		severities[v.Path] = severities[v.Path].Max(v.Severity)
+	}
+	for i := range changes {
+		if s, ok := severities[changes[i].Path]; ok && changes[i].Type != diff.ChangeTypeNormalized {
+			changes[i].Severity = changes[i].Severity.Max(s)
+		}
+	}
+	return violations
+}
+
+// MaxSeverity returns the highest severity among violations, or "" if there
+// are none.
+func MaxSeverity(violations []Violation) diff.Severity {
+	var max diff.Severity
+	for _, v := range violations {
+		max = max.Max(v.Severity)
+	}
+	return max
+}
+
+// violatedBy reports whether change c breaks the rule.
+func (r *Rule) violatedBy(c *diff.Change) bool {
+	if !r.Matches(c) {
+		return false
+	}
+
+	for _, s := range r.allow {
+		if s.Match(c.Path) {
+			return false
+		}
+	}
+	return true
+}
+
+// message returns the violation message of the rule.
+func (r *Rule) message() string {
+	switch {
+	case r.Message != "":
+		return r.Message
+	case len(r.Allow) > 0:
+		return "change outside allowed paths"
+	case r.When != "":
+		return "change where " + r.When
+	default:
+		return "change not allowed"
+	}
+}
@@ -0,0 +1,228 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+func modify(path string, old, new *tree.Node) diff.Change {
+	return diff.Change{Type: diff.ChangeTypeModify, Path: path, OldValue: old, NewValue: new}
+}
+
+func TestExpr(t *testing.T) {
+	change := modify("/spec/replicas", tree.NewInt(3), tree.NewInt(2))
+	image := modify("/image", tree.NewString("nginx:1.25"), tree.NewString("nginx:latest"))
+	removed := diff.Change{Type: diff.ChangeTypeRemove, Path: "/debug", OldValue: tree.NewBool(true)}
+
+	tests := []struct {
+		expr   string
+		change diff.Change
+		want   bool
+	}{
+		{"new < old", change, true},
+		{"new > old", change, false},
+		{"new <= 2 && old >= 3", change, true},
+		{"new == 2.0", change, true},
+		{"new != 2", change, false},
+		{"old < 1 || new < 3", change, true},
+		{"!(new < old)", change, false},
+		{`path == "/spec/replicas" && type == "modify"`, change, true},
+		{`new matches ":latest$"`, image, true},
+		{`old matches ':latest$'`, image, false},
+		{`new contains "latest"`, image, true},
+		{`new > "nginx:2"`, image, true},
+		{"new == null", removed, true},
+		{"old", removed, true},
+		{"old && new", removed, false},
+		{"new < old", removed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := compileExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("compileExpr() error = %v", err)
+			}
+			if got := e.eval(&tt.change); got != tt.want {
+				t.Errorf("eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpr_Errors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"new <",
+		"new = old",
+		"(new < old",
+		"new < old)",
+		"new matches old",
+		`new matches "("`,
+		`new == "unterminated`,
+		"replicas < 3",
+	} {
+		if _, err := compileExpr(src); err == nil {
+			t.Errorf("compileExpr(%q) expected error", src)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(`
+rules:
+  - path: /spec/replicas
+    change: modify
+  - name: pinned
+    change: [add, modify]
+    when: new matches ":latest$"
+    severity: warn
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if got := p.Rules[0]; got.Name != "rule 1" || got.Severity != diff.SeverityError {
+		t.Errorf("rule defaults = %q, %q", got.Name, got.Severity)
+	}
+	if got := p.Rules[1]; got.Severity != diff.SeverityWarning || len(got.Change) != 2 {
+		t.Errorf("rule = %+v", got)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"bad severity", "rules: [{path: /a, severity: fatal}]", "unknown severity"},
+		{"bad change type", "rules: [{path: /a, change: rename}]", "unknown change type"},
+		{"bad expression", "rules: [{path: /a, when: 'new <'}]", "invalid when"},
+		{"empty rule", "rules: [{name: nothing}]", "needs a path"},
+		{"bad path", "rules: [{path: '/a[name=web'}]", "unterminated ["},
+		{"bad allow", "rules: [{allow: ['/a/~(']}]", "invalid regular expression"},
+		{"bad yaml", "rules: [", "failed to parse policy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_Apply(t *testing.T) {
+	p, err := Parse([]byte(`
+rules:
+  - name: no-scale-down
+    path: /spec/replicas
+    when: new < old
+    message: replicas must not decrease
+  - name: keep-resources
+    path: /spec/containers/*/resources/*
+    change: remove
+  - name: no-latest
+    path: /spec/containers/*/image
+    when: new matches ":latest$"
+    severity: warn
+  - name: spec-only
+    allow: [/spec/*]
+    severity: info
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	changes := []diff.Change{
+		modify("/spec/replicas", tree.NewInt(3), tree.NewInt(2)),
+		{Type: diff.ChangeTypeRemove, Path: "/spec/containers[name=web]/resources/limits", OldValue: tree.NewObject(nil)},
+		modify("/spec/containers[0]/image", tree.NewString("web:1"), tree.NewString("web:latest")),
+		modify("/metadata/labels/team", tree.NewString("a"), tree.NewString("b")),
+		modify("/spec/paused", tree.NewBool(false), tree.NewBool(true)),
+		{Type: diff.ChangeTypeNormalized, Path: "/metadata/cidr", OldValue: tree.NewString("10.0.0.1/8"), NewValue: tree.NewString("10.0.0.0/8")},
+	}
+
+	violations := p.Apply(changes)
+
+	want := []Violation{
+		{Rule: "no-scale-down", Severity: diff.SeverityError, Path: "/spec/replicas", Message: "replicas must not decrease"},
+		{Rule: "keep-resources", Severity: diff.SeverityError, Path: "/spec/containers[name=web]/resources/limits", Message: "change not allowed"},
+		{Rule: "no-latest", Severity: diff.SeverityWarning, Path: "/spec/containers[0]/image", Message: `change where new matches ":latest$"`},
+		{Rule: "spec-only", Severity: diff.SeverityInfo, Path: "/metadata/labels/team", Message: "change outside allowed paths"},
+	}
+	if len(violations) != len(want) {
+		t.Fatalf("Apply() = %+v, want %d violations", violations, len(want))
+	}
+	for i := range want {
+		if violations[i] != want[i] {
+			t.Errorf("violation %d = %+v, want %+v", i, violations[i], want[i])
+		}
+	}
+
+	wantSeverities := []diff.Severity{diff.SeverityError, diff.SeverityError, diff.SeverityWarning, diff.SeverityInfo, "", ""}
+	for i, c := range changes {
+		if c.Severity != wantSeverities[i] {
+			t.Errorf("%s severity = %q, want %q", c.Path, c.Severity, wantSeverities[i])
+		}
+	}
+
+	if got := MaxSeverity(violations); got != diff.SeverityError {
+		t.Errorf("MaxSeverity() = %q, want error", got)
+	}
+	if got := MaxSeverity(nil); got != "" {
+		t.Errorf("MaxSeverity(nil) = %q, want none", got)
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		pattern string
+		want    bool
+	}{
+		{"/spec/replicas", "/spec/replicas", true},
+		{"/spec/containers[0]/image", "/spec/containers/*/image", true},
+		{"/spec/containers[name=web]/image", "/spec/containers/*/image", true},
+		{"/spec/containers[0]/image", "/spec/containers[0]/image", true},
+		{"/spec/containers[1]/image", "/spec/containers[0]/image", false},
+		{"/data", "/data/*", true},
+		{"/data/key", "/data/*", true},
+		{"/metadata/name", "/data/*", false},
+		{"/spec/containers[name=web]/image", "/spec/containers[name=web]/image", true},
+		{"/spec/template/spec/containers[0]/image", "/**/containers[*]/image", true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchPath(tt.path, tt.pattern); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.path, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte("rules: [{path: /a}]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(p.Rules) != 1 {
+		t.Errorf("Load() rules = %d, want 1", len(p.Rules))
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load() expected error for missing file")
+	}
+}
@@ -0,0 +1,142 @@
+package configdiff
+
+import (
+	"io/fs"
+	"math"
+	"sort"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// renameCandidate is a possible pairing of a removed and an added file.
+type renameCandidate struct {
+	oldPath    string
+	newPath    string
+	similarity float64
+	sameObject bool
+}
+
+// detectRenames pairs removed and added files whose contents are similar enough
+// to be treated as the same file. Files are paired when the share of unchanged
+// values is at least the rename threshold, or when both describe the same
+// Kubernetes resource. Each file takes part in at most one pair, best match first.
+// Pairs that cannot be compared are not candidates, so their files stay
+// removed and added.
+func (l *dirLoader) detectRenames(removed, added []string) []FileResult {
+	if len(removed) == 0 || len(added) == 0 {
+		return nil
+	}
+
+	// Files that cannot be parsed are never rename candidates
+	oldTrees := l.loadAll(l.oldFS, removed, l.opts.OldFormat)
+	newTrees := l.loadAll(l.newFS, added, l.opts.NewFormat)
+
+	var candidates []renameCandidate
+	for _, oldPath := range removed {
+		oldTree, ok := oldTrees[oldPath]
+		if !ok {
+			continue
+		}
+		for _, newPath := range added {
+			newTree, ok := newTrees[newPath]
+			if !ok {
+				continue
+			}
+
+			similarity, err := l.differ.d.Similarity(oldTree, newTree)
+			if err != nil {
+				continue
+			}
+
+			oldID, newID := resourceIdentity(oldTree), resourceIdentity(newTree)
+			sameObject := oldID != "" && oldID == newID
+
+			if sameObject || similarityPercent(similarity) >= l.opts.RenameThreshold {
+				candidates = append(candidates, renameCandidate{
+					oldPath:    oldPath,
+					newPath:    newPath,
+					similarity: similarity,
+					sameObject: sameObject,
+				})
+			}
+		}
+	}
+
+	// Best matches first: same resource, then most similar, then by path
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.sameObject != b.sameObject {
+			return a.sameObject
+		}
+		if a.similarity != b.similarity {
+			return a.similarity > b.similarity
+		}
+		if a.oldPath != b.oldPath {
+			return a.oldPath < b.oldPath
+		}
+		return a.newPath < b.newPath
+	})
+
+	usedOld := make(map[string]bool)
+	usedNew := make(map[string]bool)
+	var renames []FileResult
+	for _, c := range candidates {
+		if usedOld[c.oldPath] || usedNew[c.newPath] {
+			continue
+		}
+		usedOld[c.oldPath] = true
+		usedNew[c.newPath] = true
+		renames = append(renames, FileResult{
+			Path:       c.newPath,
+			OldPath:    c.oldPath,
+			Status:     FileRenamed,
+			Similarity: c.similarity,
+		})
+	}
+
+	return renames
+}
+
+// loadAll parses the named files, skipping those that cannot be read or parsed.
+func (l *dirLoader) loadAll(fsys fs.FS, names []string, formatHint string) map[string]*tree.Node {
+	trees := make(map[string]*tree.Node, len(names))
+	for _, name := range names {
+		node, err := l.load(fsys, name, formatHint)
+		if err != nil {
+			continue
+		}
+		trees[name] = node
+	}
+	return trees
+}
+
+// SimilarityPercent returns the similarity of a renamed file as a whole
+// percentage, as shown by git diff -M.
+func (f FileResult) SimilarityPercent() int {
+	return similarityPercent(f.Similarity)
+}
+
+// similarityPercent returns a similarity as a whole percentage, rounded down.
+func similarityPercent(similarity float64) int {
+	return int(math.Floor(similarity * 100))
+}
+
+// resourceIdentity returns a key identifying a Kubernetes resource by kind,
+// namespace and name, or "" if the document is not a Kubernetes resource.
+func resourceIdentity(node *tree.Node) string {
+	kind := node.GetByPath("/kind")
+	name := node.GetByPath("/metadata/name")
+	if kind == nil || kind.Kind != tree.KindString || name == nil || name.Kind != tree.KindString {
+		return ""
+	}
+	if node.GetByPath("/apiVersion") == nil {
+		return ""
+	}
+
+	namespace := ""
+	if ns := node.GetByPath("/metadata/namespace"); ns != nil && ns.Kind == tree.KindString {
+		namespace = ns.Value.(string)
+	}
+
+	return kind.Value.(string) + "/" + namespace + "/" + name.Value.(string)
+}
@@ -2,6 +2,7 @@ package report
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/pfrederiksen/configdiff/diff"
@@ -17,9 +18,7 @@ func GenerateGitDiff(changes []diff.Change, oldFile, newFile string) string {
 	var b strings.Builder
 	
 	// Git diff header
-	b.WriteString(fmt.Sprintf("diff --configdiff a/%s b/%s\n", oldFile, newFile))
-	b.WriteString(fmt.Sprintf("--- a/%s\n", oldFile))
-	b.WriteString(fmt.Sprintf("+++ b/%s\n", newFile))
+	writeGitDiffHeader(&b, oldFile, newFile)
 	
 	// Group changes by path for better readability
 	pathChanges := make(map[string][]diff.Change)
@@ -27,7 +26,7 @@ func GenerateGitDiff(changes []diff.Change, oldFile, newFile string) string {
 	
 	for _, change := range changes {
 		// Extract base path (before array indices)
-		basePath := strings.Split(change.Path, "[")[0]
+		basePath := gitDiffGroup(change.Path)
 		if pathChanges[basePath] == nil {
 			paths = append(paths, basePath)
 		}
@@ -39,28 +38,93 @@ func GenerateGitDiff(changes []diff.Change, oldFile, newFile string) string {
 		b.WriteString(fmt.Sprintf("@@ %s @@\n", basePath))
 		
 		for _, change := range pathChanges[basePath] {
-			switch change.Type {
-			case diff.ChangeTypeAdd:
-				val := formatValue(change.NewValue, 0)
-				b.WriteString(fmt.Sprintf("+%s: %s\n", change.Path, val))
-				
-			case diff.ChangeTypeRemove:
-				val := formatValue(change.OldValue, 0)
-				b.WriteString(fmt.Sprintf("-%s: %s\n", change.Path, val))
-				
-			case diff.ChangeTypeModify:
-				oldVal := formatValue(change.OldValue, 0)
-				newVal := formatValue(change.NewValue, 0)
-				b.WriteString(fmt.Sprintf("-%s: %s\n", change.Path, oldVal))
-				b.WriteString(fmt.Sprintf("+%s: %s\n", change.Path, newVal))
-				
-			case diff.ChangeTypeMove:
-				oldVal := formatValue(change.OldValue, 0)
-				newVal := formatValue(change.NewValue, 0)
-				b.WriteString(fmt.Sprintf("~%s: %s → %s\n", change.Path, oldVal, newVal))
-			}
+			writeGitDiffChange(&b, change)
 		}
 	}
 	
 	return b.String()
 }
+
+// writeGitDiffHeader writes the file header of a git diff.
+func writeGitDiffHeader(b *strings.Builder, oldFile, newFile string) {
+	b.WriteString(fmt.Sprintf("diff --configdiff a/%s b/%s\n", oldFile, newFile))
+	b.WriteString(fmt.Sprintf("--- a/%s\n", oldFile))
+	b.WriteString(fmt.Sprintf("+++ b/%s\n", newFile))
+}
+
+// gitDiffGroup returns the path a change is grouped under in a git diff:
+// its path up to the first array index.
+func gitDiffGroup(path string) string {
+	return strings.Split(path, "[")[0]
+}
+
+// writeGitDiffChange writes the lines of one change of a git diff.
+func writeGitDiffChange(b *strings.Builder, change diff.Change) {
+	switch change.Type {
+	case diff.ChangeTypeAdd:
+		val := formatValue(change.NewValue, 0)
+		b.WriteString(fmt.Sprintf("+%s: %s\n", change.Path, val))
+
+	case diff.ChangeTypeRemove:
+		val := formatValue(change.OldValue, 0)
+		b.WriteString(fmt.Sprintf("-%s: %s\n", change.Path, val))
+
+	case diff.ChangeTypeModify:
+		oldVal := formatValue(change.OldValue, 0)
+		newVal := formatValue(change.NewValue, 0)
+		b.WriteString(fmt.Sprintf("-%s: %s\n", change.Path, oldVal))
+		b.WriteString(fmt.Sprintf("+%s: %s\n", change.Path, newVal))
+		if change.Description != "" {
+			b.WriteString(fmt.Sprintf("# %s\n", change.Description))
+		}
+
+	case diff.ChangeTypeMove:
+		b.WriteString(fmt.Sprintf("~%s → %s (renamed)\n", change.From, change.Path))
+
+	case diff.ChangeTypeNormalized:
+		oldVal := formatValue(change.OldValue, 0)
+		newVal := formatValue(change.NewValue, 0)
+		b.WriteString(fmt.Sprintf("# %s: %s ≈ %s (equal after normalization)\n", change.Path, oldVal, newVal))
+	}
+
+	if change.Severity != "" {
+		b.WriteString(fmt.Sprintf("# severity: %s\n", change.Severity))
+	}
+	if change.Category != "" {
+		b.WriteString(fmt.Sprintf("# category: %s\n", change.Category))
+	}
+}
+
+// GitDiffWriter writes changes in git diff format as they are found, such
+// as from diff.Walk. Changes under the same path up to the first array
+// index must arrive together, as Walk reports them; the output then matches
+// GenerateGitDiff for the same changes in the same order.
+type GitDiffWriter struct {
+	w                io.Writer
+	oldFile, newFile string
+	group            string
+	started          bool
+}
+
+// NewGitDiffWriter returns a GitDiffWriter writing to w.
+func NewGitDiffWriter(w io.Writer, oldFile, newFile string) *GitDiffWriter {
+	return &GitDiffWriter{w: w, oldFile: oldFile, newFile: newFile}
+}
+
+// WriteChange writes change, preceded by the file header if it is the first
+// and by a hunk header if it starts a new group.
+func (g *GitDiffWriter) WriteChange(change diff.Change) error {
+	var b strings.Builder
+	if !g.started {
+		writeGitDiffHeader(&b, g.oldFile, g.newFile)
+	}
+	if group := gitDiffGroup(change.Path); !g.started || group != g.group {
+		b.WriteString(fmt.Sprintf("@@ %s @@\n", group))
+		g.group = group
+	}
+	g.started = true
+
+	writeGitDiffChange(&b, change)
+	_, err := io.WriteString(g.w, b.String())
+	return err
+}
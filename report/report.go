@@ -4,6 +4,7 @@ package report
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/fatih/color"
@@ -65,7 +66,8 @@ func Generate(changes []diff.Change, opts Options) string {
 		b.WriteString("\n")
 	}
 
-	// Write detailed changes
+	// Write detailed changes, most severe first
+	changes = sortBySeverity(changes)
 	b.WriteString("Changes:\n")
 	for i, change := range changes {
 		b.WriteString(formatChange(change, opts))
@@ -84,14 +86,21 @@ type Summary struct {
 	Removed  int
 	Modified int
 	Moved    int
+
+	// Normalized counts values equal only after normalization.
+	// They are not included in Total.
+	Normalized int
 }
 
 // summarizeChanges counts changes by type.
 func summarizeChanges(changes []diff.Change) Summary {
 	var s Summary
-	s.Total = len(changes)
 
 	for _, change := range changes {
+		if change.Type != diff.ChangeTypeNormalized {
+			s.Total++
+		}
+
 		switch change.Type {
 		case diff.ChangeTypeAdd:
 			s.Added++
@@ -101,6 +110,8 @@ func summarizeChanges(changes []diff.Change) Summary {
 			s.Modified++
 		case diff.ChangeTypeMove:
 			s.Moved++
+		case diff.ChangeTypeNormalized:
+			s.Normalized++
 		}
 	}
 
@@ -109,7 +120,7 @@ func summarizeChanges(changes []diff.Change) Summary {
 
 // formatSummary creates a summary header.
 func formatSummary(s Summary, opts Options) string {
-	parts := make([]string, 0, 4)
+	parts := make([]string, 0, 5)
 
 	green := color.New(color.FgGreen).SprintFunc()
 	red := color.New(color.FgRed).SprintFunc()
@@ -128,6 +139,9 @@ func formatSummary(s Summary, opts Options) string {
 	if s.Moved > 0 {
 		parts = append(parts, cyan(fmt.Sprintf("↔%d moved", s.Moved)))
 	}
+	if s.Normalized > 0 {
+		parts = append(parts, fmt.Sprintf("≈%d normalized", s.Normalized))
+	}
 
 	summary := strings.Join(parts, ", ")
 	return fmt.Sprintf("Summary: %s (%d total)\n", summary, s.Total)
@@ -160,6 +174,9 @@ func formatChange(change diff.Change, opts Options) string {
 	}
 
 	b.WriteString(fmt.Sprintf("  %s %s", coloredSymbol, change.Path))
+	if change.Type == diff.ChangeTypeMove {
+		b.WriteString(fmt.Sprintf(" %s", cyan("(renamed from "+change.From+")")))
+	}
 
 	// Add values if requested
 	if opts.ShowValues {
@@ -173,12 +190,25 @@ func formatChange(change diff.Change, opts Options) string {
 			b.WriteString(fmt.Sprintf(" (was: %s)", red(val)))
 
 		case diff.ChangeTypeModify:
+			if change.Description != "" {
+				b.WriteString(fmt.Sprintf(": %s", yellow(change.Description)))
+				break
+			}
 			oldVal := formatValue(change.OldValue, opts.MaxValueLength)
 			newVal := formatValue(change.NewValue, opts.MaxValueLength)
 			b.WriteString(fmt.Sprintf(": %s → %s", red(oldVal), green(newVal)))
+
+		case diff.ChangeTypeNormalized:
+			oldVal := formatValue(change.OldValue, opts.MaxValueLength)
+			newVal := formatValue(change.NewValue, opts.MaxValueLength)
+			b.WriteString(fmt.Sprintf(": %s ≈ %s (equal after normalization)", oldVal, newVal))
 		}
 	}
 
+	if tag := changeTag(change); tag != "" {
+		b.WriteString(" " + tagColor(change.Severity)(tag))
+	}
+
 	b.WriteString("\n")
 	return b.String()
 }
@@ -194,11 +224,65 @@ func getChangeSymbol(ct diff.ChangeType) string {
 		return "~"
 	case diff.ChangeTypeMove:
 		return "↔"
+	case diff.ChangeTypeNormalized:
+		return "≈"
 	default:
 		return "?"
 	}
 }
 
+// changeTag returns the marker shown next to changes with a severity or
+// category, e.g. "[error]", "[image]" or "[warning, image]", or "" for none.
+func changeTag(change diff.Change) string {
+	var parts []string
+	if change.Severity != "" {
+		parts = append(parts, string(change.Severity))
+	}
+	if change.Category != "" {
+		parts = append(parts, change.Category)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// tagColor returns the color function for the tag of a change with
+// severity s: red for errors, yellow for warnings and plain otherwise.
+func tagColor(s diff.Severity) func(a ...interface{}) string {
+	switch s {
+	case diff.SeverityError:
+		return color.New(color.FgRed).SprintFunc()
+	case diff.SeverityWarning:
+		return color.New(color.FgYellow).SprintFunc()
+	default:
+		return fmt.Sprint
+	}
+}
+
+// sortBySeverity returns changes ordered from the highest severity to the
+// lowest, keeping the diff order among equal severities. changes is
+// returned as is when no change has a severity.
+func sortBySeverity(changes []diff.Change) []diff.Change {
+	ranked := false
+	for _, change := range changes {
+		if change.Severity != "" {
+			ranked = true
+			break
+		}
+	}
+	if !ranked {
+		return changes
+	}
+
+	sorted := make([]diff.Change, len(changes))
+	copy(sorted, changes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Severity.Rank() > sorted[j].Severity.Rank()
+	})
+	return sorted
+}
+
 // formatValue converts a node value to a display string.
 func formatValue(node *tree.Node, maxLen int) string {
 	if node == nil {
@@ -215,17 +299,8 @@ func formatValue(node *tree.Node, maxLen int) string {
 		val = fmt.Sprintf("%v", node.Value)
 
 	case tree.KindNumber:
-		// Format numbers nicely
-		if f, ok := node.Value.(float64); ok {
-			// Check if it's a whole number
-			if f == float64(int64(f)) {
-				val = fmt.Sprintf("%d", int64(f))
-			} else {
-				val = fmt.Sprintf("%g", f)
-			}
-		} else {
-			val = fmt.Sprintf("%v", node.Value)
-		}
+		// Whole numbers are printed without a fraction or exponent
+		val = node.NumberString()
 
 	case tree.KindString:
 		val = fmt.Sprintf("%q", node.Value)
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/pfrederiksen/configdiff/diff"
@@ -710,6 +711,31 @@ func TestGenerateGitDiff(t *testing.T) {
 			newFile: "config.yaml",
 			golden:  "git_diff_multiple.txt",
 		},
+		{
+			name: "array elements grouped",
+			changes: []diff.Change{
+				{
+					Type:     diff.ChangeTypeModify,
+					Path:     "/items[0]/image",
+					OldValue: tree.NewString("web:1"),
+					NewValue: tree.NewString("web:2"),
+				},
+				{
+					Type:     diff.ChangeTypeAdd,
+					Path:     "/items[1]",
+					NewValue: tree.NewString("worker"),
+				},
+				{
+					Type:     diff.ChangeTypeModify,
+					Path:     "/replicas",
+					OldValue: tree.NewNumber(2),
+					NewValue: tree.NewNumber(3),
+				},
+			},
+			oldFile: "app.yaml",
+			newFile: "app.yaml",
+			golden:  "git_diff_arrays.txt",
+		},
 	}
 
 	for _, tt := range tests {
@@ -738,6 +764,221 @@ func TestGenerateGitDiff(t *testing.T) {
 				t.Errorf("GenerateGitDiff() output differs from golden file %s\nGot:\n%s\nWant:\n%s", tt.golden, got, string(want))
 				t.Logf("Run with -update flag to update golden files")
 			}
+
+			// Changes written one by one as they are found read the same
+			var streamed strings.Builder
+			w := NewGitDiffWriter(&streamed, tt.oldFile, tt.newFile)
+			for _, c := range tt.changes {
+				if err := w.WriteChange(c); err != nil {
+					t.Fatalf("WriteChange() error = %v", err)
+				}
+			}
+			if streamed.String() != string(want) {
+				t.Errorf("GitDiffWriter output differs from golden file %s\nGot:\n%s\nWant:\n%s", tt.golden, streamed.String(), string(want))
+			}
+		})
+	}
+}
+
+func TestGenerate_Description(t *testing.T) {
+	changes := []diff.Change{
+		{
+			Type:        diff.ChangeTypeModify,
+			Path:        "/version",
+			OldValue:    tree.NewString("^1.2"),
+			NewValue:    tree.NewString("~1.2.3"),
+			Description: "range narrowed",
+		},
+	}
+
+	opts := DefaultOptions()
+	opts.NoColor = true
+
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"report", Generate(changes, opts), "~ /version: range narrowed"},
+		{"side-by-side", GenerateSideBySide(changes, opts), "↳ range narrowed"},
+		{"git-diff", GenerateGitDiff(changes, "a.yaml", "b.yaml"), "# range narrowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !contains(tt.output, tt.want) {
+				t.Errorf("output missing %q, got:\n%s", tt.want, tt.output)
+			}
+		})
+	}
+}
+
+func TestGenerate_Normalized(t *testing.T) {
+	changes := []diff.Change{
+		{
+			Type:     diff.ChangeTypeNormalized,
+			Path:     "/cidr",
+			OldValue: tree.NewString("10.0.0.1/8"),
+			NewValue: tree.NewString("10.0.0.0/8"),
+		},
+		{
+			Type:     diff.ChangeTypeModify,
+			Path:     "/replicas",
+			OldValue: tree.NewNumber(2),
+			NewValue: tree.NewNumber(3),
+		},
+	}
+
+	opts := DefaultOptions()
+	opts.NoColor = true
+	output := Generate(changes, opts)
+
+	for _, want := range []string{
+		"~1 modified, ≈1 normalized (1 total)",
+		`≈ /cidr: "10.0.0.1/8" ≈ "10.0.0.0/8" (equal after normalization)`,
+	} {
+		if !contains(output, want) {
+			t.Errorf("output missing %q, got:\n%s", want, output)
+		}
+	}
+
+	stat := GenerateStat(changes)
+	if contains(stat, "/cidr") || !contains(stat, "1 normalized(≈)") {
+		t.Errorf("stat should count but not list normalized values, got:\n%s", stat)
+	}
+}
+
+func TestGenerate_Severity(t *testing.T) {
+	changes := []diff.Change{
+		{
+			Type:     diff.ChangeTypeModify,
+			Path:     "/replicas",
+			OldValue: tree.NewNumber(2),
+			NewValue: tree.NewNumber(0),
+			Severity: diff.SeverityError,
+		},
+	}
+
+	opts := DefaultOptions()
+	opts.NoColor = true
+
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"report", Generate(changes, opts), "~ /replicas: 2 → 0 [error]"},
+		{"compact", GenerateCompact(changes), "~ /replicas [error]"},
+		{"stat", GenerateStat(changes), "[error]"},
+		{"side-by-side", GenerateSideBySide(changes, opts), "/replicas [error]"},
+		{"git-diff", GenerateGitDiff(changes, "a.yaml", "b.yaml"), "# severity: error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !contains(tt.output, tt.want) {
+				t.Errorf("output missing %q, got:\n%s", tt.want, tt.output)
+			}
+		})
+	}
+}
+
+func TestGenerate_Category(t *testing.T) {
+	changes := []diff.Change{
+		{
+			Type:     diff.ChangeTypeModify,
+			Path:     "/metadata/labels/team",
+			OldValue: tree.NewString("a"),
+			NewValue: tree.NewString("b"),
+			Category: "metadata",
+		},
+		{
+			Type:     diff.ChangeTypeModify,
+			Path:     "/spec/image",
+			OldValue: tree.NewString("web:1"),
+			NewValue: tree.NewString("web:latest"),
+			Severity: diff.SeverityWarning,
+			Category: "image",
+		},
+		{
+			Type:     diff.ChangeTypeAdd,
+			Path:     "/spec/paused",
+			NewValue: tree.NewBool(true),
+		},
+	}
+
+	opts := DefaultOptions()
+	opts.NoColor = true
+
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"report", Generate(changes, opts), "~ /spec/image: \"web:1\" → \"web:latest\" [warning, image]"},
+		{"compact", GenerateCompact(changes), "~ /metadata/labels/team [metadata]"},
+		{"stat", GenerateStat(changes), "by category: image 1, metadata 1, uncategorized 1"},
+		{"side-by-side", GenerateSideBySide(changes, opts), "/spec/image [warning, image]"},
+		{"git-diff", GenerateGitDiff(changes, "a.yaml", "b.yaml"), "# category: image"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !contains(tt.output, tt.want) {
+				t.Errorf("output missing %q, got:\n%s", tt.want, tt.output)
+			}
+		})
+	}
+
+	t.Run("sorted by severity", func(t *testing.T) {
+		output := Generate(changes, opts)
+		if strings.Index(output, "/spec/image") > strings.Index(output, "/metadata/labels/team") {
+			t.Errorf("warning change not listed first:\n%s", output)
+		}
+		if changes[0].Path != "/metadata/labels/team" {
+			t.Error("Generate() reordered the caller's changes")
+		}
+	})
+
+	t.Run("no category line without categories", func(t *testing.T) {
+		output := GenerateStat(changes[2:])
+		if contains(output, "by category") {
+			t.Errorf("unexpected category line:\n%s", output)
+		}
+	})
+}
+
+func TestGenerate_Rename(t *testing.T) {
+	changes := []diff.Change{
+		{
+			Type:     diff.ChangeTypeMove,
+			Path:     "/database_host",
+			From:     "/db_host",
+			OldValue: tree.NewString("db"),
+			NewValue: tree.NewString("db"),
+		},
+	}
+
+	opts := DefaultOptions()
+	opts.NoColor = true
+
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"report", Generate(changes, opts), "↔ /database_host (renamed from /db_host)"},
+		{"compact", GenerateCompact(changes), "↔ /database_host (renamed from /db_host)"},
+		{"stat", GenerateStat(changes), "/database_host | →→→"},
+		{"side-by-side", GenerateSideBySide(changes, opts), "/db_host                             ↔ /database_host"},
+		{"git-diff", GenerateGitDiff(changes, "a.yaml", "b.yaml"), "~/db_host → /database_host (renamed)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !contains(tt.output, tt.want) {
+				t.Errorf("output missing %q, got:\n%s", tt.want, tt.output)
+			}
 		})
 	}
 }
@@ -38,12 +38,15 @@ func GenerateSideBySide(changes []diff.Change, opts Options) string {
 	red := color.New(color.FgRed).SprintFunc()
 	yellow := color.New(color.FgYellow).SprintFunc()
 	
-	for _, change := range changes {
+	for _, change := range sortBySeverity(changes) {
 		path := change.Path
 		if len(path) > 76 {
 			path = "..." + path[len(path)-73:]
 		}
 		
+		if tag := changeTag(change); tag != "" {
+			path += " " + tagColor(change.Severity)(tag)
+		}
 		b.WriteString(fmt.Sprintf("%s\n", path))
 		
 		switch change.Type {
@@ -71,11 +74,17 @@ func GenerateSideBySide(changes []diff.Change, opts Options) string {
 				newVal = yellow(newVal)
 			}
 			b.WriteString(fmt.Sprintf("  %-36s | %s\n", oldVal, newVal))
+			if change.Description != "" {
+				b.WriteString(fmt.Sprintf("  ↳ %s\n", change.Description))
+			}
 			
 		case diff.ChangeTypeMove:
+			b.WriteString(fmt.Sprintf("  %-36s ↔ %s\n", change.From, change.Path))
+
+		case diff.ChangeTypeNormalized:
 			oldVal := formatValue(change.OldValue, opts.MaxValueLength)
 			newVal := formatValue(change.NewValue, opts.MaxValueLength)
-			b.WriteString(fmt.Sprintf("  %-36s → %s\n", oldVal, newVal))
+			b.WriteString(fmt.Sprintf("  %-36s ≈ %s\n", oldVal, newVal))
 		}
 		
 		b.WriteString("\n")
@@ -2,6 +2,7 @@ package report
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/pfrederiksen/configdiff/diff"
@@ -19,11 +20,25 @@ func GenerateStat(changes []diff.Change) string {
 	
 	// Count affected paths
 	paths := make(map[string]*pathStat)
+	tags := make(map[string]diff.Change)
+	categories := make(map[string]int)
 	for _, change := range changes {
+		// Values equal after normalization did not change
+		if change.Type == diff.ChangeTypeNormalized {
+			continue
+		}
+
 		path := change.Path
 		if paths[path] == nil {
 			paths[path] = &pathStat{}
 		}
+		tag := tags[path]
+		tag.Severity = tag.Severity.Max(change.Severity)
+		if change.Category != "" {
+			tag.Category = change.Category
+		}
+		tags[path] = tag
+		categories[change.Category]++
 		
 		switch change.Type {
 		case diff.ChangeTypeAdd:
@@ -81,16 +96,22 @@ func GenerateStat(changes []diff.Change) string {
 			plusCount := (stat.additions * barWidth) / total
 			minusCount := (stat.deletions * barWidth) / total
 			modCount := (stat.modifications * barWidth) / total
+			moveCount := (stat.moves * barWidth) / total
 			
 			bar = strings.Repeat("+", plusCount) + 
 			      strings.Repeat("-", minusCount) + 
-			      strings.Repeat("~", modCount)
+			      strings.Repeat("~", modCount) +
+			      strings.Repeat("→", moveCount)
 			
-			if len(bar) > barWidth {
-				bar = bar[:barWidth]
+			if runes := []rune(bar); len(runes) > barWidth {
+				bar = string(runes[:barWidth])
 			}
 		}
 		
+		if tag := changeTag(tags[path]); tag != "" {
+			bar += " " + tag
+		}
+		
 		fmt.Fprintf(&b, " %-*s | %s\n", maxPathLen, displayPath, bar)
 	}
 	
@@ -108,11 +129,40 @@ func GenerateStat(changes []diff.Change) string {
 	if summary.Moved > 0 {
 		b.WriteString(fmt.Sprintf(", %d moves(→)", summary.Moved))
 	}
+	if summary.Normalized > 0 {
+		b.WriteString(fmt.Sprintf(", %d normalized(≈)", summary.Normalized))
+	}
 	b.WriteString("\n")
+	b.WriteString(formatCategoryCounts(categories))
 	
 	return b.String()
 }
 
+// formatCategoryCounts breaks change counts down by category, e.g.
+// " by category: image 1, scaling 2, uncategorized 1". It returns "" when
+// no change has a category.
+func formatCategoryCounts(categories map[string]int) string {
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s %d", name, categories[name]))
+	}
+	if n := categories[""]; n > 0 {
+		parts = append(parts, fmt.Sprintf("uncategorized %d", n))
+	}
+	return fmt.Sprintf(" by category: %s\n", strings.Join(parts, ", "))
+}
+
 type pathStat struct {
 	additions     int
 	deletions     int
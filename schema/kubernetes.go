@@ -0,0 +1,29 @@
+package schema
+
+import (
+	_ "embed"
+	"sync"
+)
+
+//go:embed kubernetes.yaml
+var kubernetesPreset []byte
+
+var (
+	kubernetesOnce   sync.Once
+	kubernetesSchema *Schema
+)
+
+// Kubernetes returns the built-in preset of defaults filled in by the
+// Kubernetes API server for Pods, Services, Deployments, StatefulSets,
+// DaemonSets, Jobs and CronJobs, such as `imagePullPolicy: IfNotPresent` or
+// `terminationMessagePath: /dev/termination-log`.
+func Kubernetes() *Schema {
+	kubernetesOnce.Do(func() {
+		s, err := Parse(kubernetesPreset)
+		if err != nil {
+			panic("schema: invalid built-in Kubernetes preset: " + err.Error())
+		}
+		kubernetesSchema = s
+	})
+	return kubernetesSchema
+}
@@ -0,0 +1,322 @@
+// Package schema reads default values from JSON Schema and OpenAPI documents
+// so that keys omitted on one side of a diff, and set to their default on
+// the other, are not reported as changes.
+//
+// Plain JSON Schema documents describe the whole compared document.
+// OpenAPI documents (Swagger 2 "definitions" or OpenAPI 3
+// "components/schemas") are matched against Kubernetes-style documents by
+// their apiVersion and kind, using the x-kubernetes-group-version-kind
+// extension.
+package schema
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/parse"
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// maxDepth bounds $ref and allOf resolution to guard against cycles.
+const maxDepth = 32
+
+// Schema is a parsed JSON Schema or OpenAPI document.
+type Schema struct {
+	// doc is the whole document, used to resolve $ref pointers.
+	doc *tree.Node
+
+	// root is the schema for the compared document, or nil for OpenAPI
+	// documents whose schemas are selected by resource type.
+	root *tree.Node
+
+	// resources maps "group/version/kind" to resource schemas.
+	resources map[string]*tree.Node
+}
+
+// Load reads a JSON Schema or OpenAPI document in JSON or YAML from a file.
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema %q: %w", path, err)
+	}
+
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schema %q: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse parses a JSON Schema or OpenAPI document in JSON or YAML.
+func Parse(data []byte) (*Schema, error) {
+	doc, err := parse.ParseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Kind != tree.KindObject {
+		return nil, fmt.Errorf("schema must be an object, got %s", doc.Kind)
+	}
+
+	s := &Schema{
+		doc:       doc,
+		resources: make(map[string]*tree.Node),
+	}
+
+	var defs *tree.Node
+	switch {
+	case doc.Object["openapi"] != nil:
+		defs = doc.GetByPath("/components/schemas")
+	case doc.Object["swagger"] != nil:
+		defs = doc.GetByPath("/definitions")
+	default:
+		s.root = doc
+		defs = doc.Object["definitions"]
+	}
+
+	if defs != nil && defs.Kind == tree.KindObject {
+		for _, def := range defs.Object {
+			s.indexResource(def)
+		}
+	}
+
+	return s, nil
+}
+
+// indexResource registers a definition under each group/version/kind it
+// declares.
+func (s *Schema) indexResource(def *tree.Node) {
+	gvks := def.Object["x-kubernetes-group-version-kind"]
+	if gvks == nil || gvks.Kind != tree.KindArray {
+		return
+	}
+
+	for _, gvk := range gvks.Array {
+		if gvk.Kind != tree.KindObject {
+			continue
+		}
+		key := resourceKey(stringField(gvk, "group"), stringField(gvk, "version"), stringField(gvk, "kind"))
+		s.resources[key] = def
+	}
+}
+
+// Default returns the default value declared for path in document root.
+func (s *Schema) Default(root *tree.Node, path string) (*tree.Node, bool) {
+	for _, candidate := range s.lookup(root, path) {
+		if def, ok := candidate.Object["default"]; ok {
+			return def, true
+		}
+	}
+	return nil, false
+}
+
+// IsDefault reports whether value, found at path in document root, is the
+// default for that path. Objects without a declared default are defaults if
+// every key they contain is set to its own default, so that materialized
+// defaults such as `strategy: {type: RollingUpdate}` are recognized.
+//
+// IsDefault implements diff.Defaults.
+func (s *Schema) IsDefault(root *tree.Node, path string, value *tree.Node) bool {
+	candidates := s.lookup(root, path)
+	if len(candidates) == 0 {
+		return false
+	}
+	return s.isDefault(value, candidates)
+}
+
+// isDefault checks value against the schemas that apply to it.
+func (s *Schema) isDefault(value *tree.Node, candidates []*tree.Node) bool {
+	for _, candidate := range candidates {
+		if def, ok := candidate.Object["default"]; ok {
+			return value.Equal(def)
+		}
+	}
+
+	if value.Kind != tree.KindObject || !describesObject(candidates) {
+		return false
+	}
+	for key, child := range value.Object {
+		childCandidates := s.properties(candidates, key)
+		if len(childCandidates) == 0 || !s.isDefault(child, childCandidates) {
+			return false
+		}
+	}
+	return true
+}
+
+// describesObject reports whether any of the schemas describes an object.
+func describesObject(candidates []*tree.Node) bool {
+	for _, candidate := range candidates {
+		if stringField(candidate, "type") == "object" ||
+			candidate.Object["properties"] != nil ||
+			candidate.Object["additionalProperties"] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the schemas that apply to path in document root.
+func (s *Schema) lookup(root *tree.Node, path string) []*tree.Node {
+	start := s.rootFor(root)
+	if start == nil {
+		return nil
+	}
+
+	candidates := s.expand(start, 0)
+	for _, segment := range tree.ParsePath(path) {
+		key, indexes := splitSegment(segment)
+		if key != "" {
+			candidates = s.properties(candidates, key)
+		}
+		for i := 0; i < indexes; i++ {
+			candidates = s.items(candidates)
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+	}
+	return candidates
+}
+
+// rootFor selects the schema describing document root.
+func (s *Schema) rootFor(root *tree.Node) *tree.Node {
+	if len(s.resources) > 0 && root != nil && root.Kind == tree.KindObject {
+		group, version := "", stringField(root, "apiVersion")
+		if i := strings.LastIndex(version, "/"); i >= 0 {
+			group, version = version[:i], version[i+1:]
+		}
+		if def, ok := s.resources[resourceKey(group, version, stringField(root, "kind"))]; ok {
+			return def
+		}
+	}
+	return s.root
+}
+
+// properties returns the schemas for key within objects described by candidates.
+func (s *Schema) properties(candidates []*tree.Node, key string) []*tree.Node {
+	var result []*tree.Node
+	for _, candidate := range candidates {
+		if props := candidate.Object["properties"]; props != nil && props.Kind == tree.KindObject {
+			if prop, ok := props.Object[key]; ok {
+				result = append(result, s.expand(prop, 0)...)
+				continue
+			}
+		}
+		if additional := candidate.Object["additionalProperties"]; additional != nil && additional.Kind == tree.KindObject {
+			result = append(result, s.expand(additional, 0)...)
+		}
+	}
+	return result
+}
+
+// items returns the schemas for elements of arrays described by candidates.
+func (s *Schema) items(candidates []*tree.Node) []*tree.Node {
+	var result []*tree.Node
+	for _, candidate := range candidates {
+		if items := candidate.Object["items"]; items != nil && items.Kind == tree.KindObject {
+			result = append(result, s.expand(items, 0)...)
+		}
+	}
+	return result
+}
+
+// expand resolves $ref and flattens allOf, anyOf and oneOf into the list of
+// schemas that may apply.
+func (s *Schema) expand(node *tree.Node, depth int) []*tree.Node {
+	if node == nil || node.Kind != tree.KindObject || depth > maxDepth {
+		return nil
+	}
+
+	if ref := stringField(node, "$ref"); ref != "" {
+		return s.expand(s.resolve(ref), depth+1)
+	}
+
+	result := []*tree.Node{node}
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		subs := node.Object[keyword]
+		if subs == nil || subs.Kind != tree.KindArray {
+			continue
+		}
+		for _, sub := range subs.Array {
+			result = append(result, s.expand(sub, depth+1)...)
+		}
+	}
+	return result
+}
+
+// resolve follows a local JSON pointer reference such as
+// "#/definitions/io.k8s.api.core.v1.Container". Remote references are not
+// supported and resolve to nil.
+func (s *Schema) resolve(ref string) *tree.Node {
+	if !strings.HasPrefix(ref, "#") {
+		return nil
+	}
+
+	current := s.doc
+	for _, token := range strings.Split(strings.TrimPrefix(ref[1:], "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+
+		switch current.Kind {
+		case tree.KindObject:
+			current = current.Object[token]
+		case tree.KindArray:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(current.Array) {
+				return nil
+			}
+			current = current.Array[i]
+		default:
+			return nil
+		}
+		if current == nil {
+			return nil
+		}
+	}
+	return current
+}
+
+// splitSegment splits a path segment such as "containers[0]" or
+// "containers[name=web]" into its object key and the number of array
+// indexes that follow it.
+func splitSegment(segment string) (string, int) {
+	start := strings.Index(segment, "[")
+	if start < 0 {
+		return segment, 0
+	}
+
+	indexes, depth := 0, 0
+	for _, r := range segment[start:] {
+		switch r {
+		case '[':
+			if depth == 0 {
+				indexes++
+			}
+			depth++
+		case ']':
+			depth--
+		}
+	}
+	return segment[:start], indexes
+}
+
+// resourceKey builds the resources map key for a group, version and kind.
+func resourceKey(group, version, kind string) string {
+	return group + "/" + version + "/" + kind
+}
+
+// stringField returns the string value of an object field, or "".
+func stringField(n *tree.Node, key string) string {
+	if n == nil || n.Kind != tree.KindObject {
+		return ""
+	}
+	v, ok := n.Object[key]
+	if !ok || v.Kind != tree.KindString {
+		return ""
+	}
+	return v.Value.(string)
+}
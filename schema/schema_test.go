@@ -0,0 +1,205 @@
+package schema
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/parse"
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+func mustParseYAML(t *testing.T, s string) *tree.Node {
+	t.Helper()
+	n, err := parse.ParseYAML([]byte(s))
+	if err != nil {
+		t.Fatalf("ParseYAML() error = %v", err)
+	}
+	return n
+}
+
+func TestSchema_Default(t *testing.T) {
+	s, err := Load(filepath.Join("testdata", "app.schema.json"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{"/server/port", "8080", true},
+		{"/server/tls/minVersion", `"1.2"`, true},
+		{"/workers[0]/queue", `"default"`, true},
+		{"/workers[name=a]/retries", "3", true},
+		{"/labels/team", `"none"`, true},
+		{"/server/host", "", false},
+		{"/missing/port", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			def, ok := s.Default(nil, tt.path)
+			if ok != tt.wantOK {
+				t.Fatalf("Default(%s) ok = %v, want %v", tt.path, ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+
+			var got string
+			switch def.Kind {
+			case tree.KindString:
+				got = `"` + def.Value.(string) + `"`
+			default:
+				got = def.NumberString()
+			}
+			if got != tt.want {
+				t.Errorf("Default(%s) = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchema_IsDefault(t *testing.T) {
+	s, err := Load(filepath.Join("testdata", "app.schema.json"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		value string
+		want  bool
+	}{
+		{"scalar default", "/server/port", "8080", true},
+		{"scalar non-default", "/server/port", "9090", false},
+		{"object of defaults", "/server/tls", "{enabled: false, minVersion: '1.2'}", true},
+		{"empty object", "/server/tls", "{}", true},
+		{"object with non-default", "/server/tls", "{enabled: true}", false},
+		{"object with unknown key", "/server/tls", "{ciphers: all}", false},
+		{"unknown path", "/server/host", "localhost", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.IsDefault(nil, tt.path, mustParseYAML(t, tt.value)); got != tt.want {
+				t.Errorf("IsDefault(%s, %s) = %v, want %v", tt.path, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchema_OpenAPI(t *testing.T) {
+	doc := `
+openapi: 3.0.0
+components:
+  schemas:
+    Widget:
+      x-kubernetes-group-version-kind:
+        - {group: example.com, version: v1, kind: Widget}
+      properties:
+        spec:
+          properties:
+            size: {default: 5}
+`
+	s, err := Parse([]byte(doc))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	widget := mustParseYAML(t, "apiVersion: example.com/v1\nkind: Widget")
+	if def, ok := s.Default(widget, "/spec/size"); !ok || def.NumberString() != "5" {
+		t.Errorf("Default() = %v, %v, want 5", def, ok)
+	}
+
+	other := mustParseYAML(t, "apiVersion: example.com/v2\nkind: Widget")
+	if _, ok := s.Default(other, "/spec/size"); ok {
+		t.Error("Default() should not apply to other resource versions")
+	}
+}
+
+func TestKubernetes(t *testing.T) {
+	live := mustParseYAML(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  revisionHistoryLimit: 10
+  progressDeadlineSeconds: 600
+  strategy:
+    type: RollingUpdate
+    rollingUpdate: {maxSurge: 25%, maxUnavailable: 25%}
+  template:
+    spec:
+      restartPolicy: Always
+      dnsPolicy: ClusterFirst
+      schedulerName: default-scheduler
+      securityContext: {}
+      terminationGracePeriodSeconds: 30
+      containers:
+        - name: web
+          image: nginx:1.27
+          imagePullPolicy: IfNotPresent
+          terminationMessagePath: /dev/termination-log
+          terminationMessagePolicy: File
+          resources: {}
+          ports:
+            - containerPort: 80
+              protocol: TCP
+`)
+	manifest := mustParseYAML(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.28
+          ports:
+            - containerPort: 80
+`)
+
+	changes, err := diff.Diff(manifest, live, diff.Options{Defaults: Kubernetes(), StableOrder: true})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	if len(changes) != 1 || changes[0].Path != "/spec/template/spec/containers[0]/image" {
+		for _, c := range changes {
+			t.Logf("  Change: %s %s", c.Type, c.Path)
+		}
+		t.Fatalf("Diff() got %d changes, want only the image change", len(changes))
+	}
+
+	// Without the preset, every default is an addition
+	changes, err = diff.Diff(manifest, live, diff.Options{})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if len(changes) < 10 {
+		t.Errorf("Diff() without defaults got %d changes, want defaults reported", len(changes))
+	}
+}
+
+func TestLoadAll(t *testing.T) {
+	set, err := LoadAll([]string{PresetKubernetes, filepath.Join("testdata", "app.schema.json")})
+	if err != nil {
+		t.Fatalf("LoadAll() error = %v", err)
+	}
+	if def, ok := set.Default(nil, "/server/port"); !ok || def.NumberString() != "8080" {
+		t.Errorf("Default() = %v, %v, want 8080 from the second schema", def, ok)
+	}
+
+	if _, err := LoadAll([]string{filepath.Join("testdata", "missing.json")}); err == nil {
+		t.Error("LoadAll() with a missing file should fail")
+	}
+}
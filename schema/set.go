@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// PresetKubernetes is the name accepted by LoadAll for the built-in
+// Kubernetes preset.
+const PresetKubernetes = "kubernetes"
+
+// Set combines several schemas. A value is a default if any schema says so.
+type Set []*Schema
+
+// LoadAll loads each named schema file, or the built-in preset for
+// PresetKubernetes.
+func LoadAll(names []string) (Set, error) {
+	set := make(Set, 0, len(names))
+	for _, name := range names {
+		if name == PresetKubernetes {
+			set = append(set, Kubernetes())
+			continue
+		}
+
+		s, err := Load(name)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, s)
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("no schemas given")
+	}
+	return set, nil
+}
+
+// Default returns the first default declared for path by the schemas.
+func (set Set) Default(root *tree.Node, path string) (*tree.Node, bool) {
+	for _, s := range set {
+		if def, ok := s.Default(root, path); ok {
+			return def, true
+		}
+	}
+	return nil, false
+}
+
+// IsDefault implements diff.Defaults.
+func (set Set) IsDefault(root *tree.Node, path string, value *tree.Node) bool {
+	for _, s := range set {
+		if s.IsDefault(root, path, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,307 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/parse"
+	"github.com/pfrederiksen/configdiff/tree"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// schemaURL names the compiled schema resource. Validation never fetches it.
+const schemaURL = "configdiff://schema.json"
+
+// printer renders validation messages.
+var printer = message.NewPrinter(language.English)
+
+// Validator validates documents against a JSON Schema. Draft 2020-12 is
+// assumed unless the schema declares another draft with "$schema".
+type Validator struct {
+	schema *jsonschema.Schema
+}
+
+// ValidationError is a single schema violation.
+type ValidationError struct {
+	// Path is the location of the invalid value, in the same notation as
+	// diff.Change paths.
+	Path string `json:"path"`
+
+	// Message describes the violation.
+	Message string `json:"message"`
+}
+
+// String formats the error as "path: message".
+func (e ValidationError) String() string {
+	return e.Path + ": " + e.Message
+}
+
+// LoadValidator reads a JSON Schema in JSON or YAML from a file and compiles it.
+func LoadValidator(path string) (*Validator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema %q: %w", path, err)
+	}
+
+	v, err := CompileValidator(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schema %q: %w", path, err)
+	}
+	return v, nil
+}
+
+// CompileValidator compiles a JSON Schema in JSON or YAML. Only the schema
+// itself and the standard metaschemas can be referenced: $ref targets on
+// the network or the local file system are never loaded.
+func CompileValidator(data []byte) (*Validator, error) {
+	doc, err := parse.ParseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	c.UseLoader(noLoader{})
+	if err := c.AddResource(schemaURL, toValue(doc)); err != nil {
+		return nil, err
+	}
+
+	sch, err := c.Compile(schemaURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{schema: sch}, nil
+}
+
+// noLoader rejects every URL, replacing the default loader that reads
+// file:// URLs.
+type noLoader struct{}
+
+// Load implements jsonschema.URLLoader.
+func (noLoader) Load(url string) (any, error) {
+	return nil, fmt.Errorf("schema references %q: external $ref targets are not loaded", url)
+}
+
+// Validate validates a document and returns its violations sorted by path.
+// A valid document has none.
+func (v *Validator) Validate(doc *tree.Node) []ValidationError {
+	err := v.schema.Validate(toValue(doc))
+	if err == nil {
+		return nil
+	}
+
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return []ValidationError{{Path: "/", Message: err.Error()}}
+	}
+
+	var result []ValidationError
+	seen := make(map[ValidationError]bool)
+	collectErrors(verr, doc, &result, seen)
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
+// collectErrors appends the leaf causes of err, which carry the specific
+// violations; the causes above them only say which subschema failed.
+func collectErrors(err *jsonschema.ValidationError, doc *tree.Node, result *[]ValidationError, seen map[ValidationError]bool) {
+	if len(err.Causes) > 0 {
+		for _, cause := range err.Causes {
+			collectErrors(cause, doc, result, seen)
+		}
+		return
+	}
+
+	e := ValidationError{
+		Path:    instancePath(doc, err.InstanceLocation),
+		Message: err.ErrorKind.LocalizedString(printer),
+	}
+	if !seen[e] {
+		seen[e] = true
+		*result = append(*result, e)
+	}
+}
+
+// instancePath converts a validator instance location, a list of JSON
+// pointer tokens, to a tree path. Tokens that index arrays in doc become
+// "[i]" so paths match the ones reported by diff.
+func instancePath(doc *tree.Node, tokens []string) string {
+	path := "/"
+	current := doc
+	for _, token := range tokens {
+		if current != nil && current.Kind == tree.KindArray {
+			if i, err := strconv.Atoi(token); err == nil {
+				path = fmt.Sprintf("%s[%d]", path, i)
+				if i >= 0 && i < len(current.Array) {
+					current = current.Array[i]
+				} else {
+					current = nil
+				}
+				continue
+			}
+		}
+
+		if path == "/" {
+			path = "/" + token
+		} else {
+			path = path + "/" + token
+		}
+		if current != nil && current.Kind == tree.KindObject {
+			current = current.Object[token]
+		} else {
+			current = nil
+		}
+	}
+	return path
+}
+
+// toValue converts a tree to the validator's data model: maps, slices,
+// strings, bools, nil and json.Number, which keeps numbers exact.
+func toValue(n *tree.Node) any {
+	if n == nil {
+		return nil
+	}
+
+	switch n.Kind {
+	case tree.KindObject:
+		obj := make(map[string]any, len(n.Object))
+		for k, v := range n.Object {
+			obj[k] = toValue(v)
+		}
+		return obj
+
+	case tree.KindArray:
+		arr := make([]any, len(n.Array))
+		for i, v := range n.Array {
+			arr[i] = toValue(v)
+		}
+		return arr
+
+	case tree.KindNumber:
+		// NaN and infinities have no JSON spelling and stay floats
+		if f, ok := n.Value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			return f
+		}
+		return json.Number(n.NumberString())
+
+	default:
+		return n.Value
+	}
+}
+
+// Validation holds the schema violations of both sides of a comparison.
+type Validation struct {
+	// Old and New are the violations of the old and new documents.
+	Old []ValidationError `json:"old"`
+	New []ValidationError `json:"new"`
+}
+
+// Compare validates both documents.
+func (v *Validator) Compare(oldDoc, newDoc *tree.Node) *Validation {
+	return &Validation{
+		Old: v.Validate(oldDoc),
+		New: v.Validate(newDoc),
+	}
+}
+
+// Introduced returns the violations of the new document that the old
+// document did not have.
+func (v *Validation) Introduced() []ValidationError {
+	old := make(map[ValidationError]bool, len(v.Old))
+	for _, e := range v.Old {
+		old[e] = true
+	}
+
+	var result []ValidationError
+	for _, e := range v.New {
+		if !old[e] {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Breaking reports whether a valid old document became invalid.
+func (v *Validation) Breaking() bool {
+	return len(v.Old) == 0 && len(v.New) > 0
+}
+
+// FlagChanges marks the changes responsible for violations introduced by
+// the new document with diff.SeverityError. A change is responsible for a
+// violation at its own path or below it; additions and removals are also
+// responsible for violations on their parent, which is where unexpected and
+// missing keys are reported.
+func (v *Validation) FlagChanges(changes []diff.Change) {
+	introduced := v.Introduced()
+	if len(introduced) == 0 {
+		return
+	}
+
+	for i := range changes {
+		c := &changes[i]
+		if c.Type == diff.ChangeTypeNormalized {
+			continue
+		}
+		for _, e := range introduced {
+			if causes(c, e.Path) {
+				c.Severity = diff.SeverityError
+				break
+			}
+		}
+	}
+}
+
+// causes reports whether change c may have caused a violation at path.
+// Keyed array paths such as "/items[name=a]" are compared through the
+// index path of the changed value.
+func causes(c *diff.Change, path string) bool {
+	paths := []string{c.Path}
+	value := c.NewValue
+	if value == nil {
+		value = c.OldValue
+	}
+	if value != nil && value.Path != "" && value.Path != c.Path {
+		paths = append(paths, value.Path)
+	}
+
+	keyed := c.Type == diff.ChangeTypeAdd || c.Type == diff.ChangeTypeRemove
+	for _, p := range paths {
+		if path == p || isBelow(path, p) || (keyed && path == parentPath(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBelow reports whether path is a descendant of parent.
+func isBelow(path, parent string) bool {
+	if parent == "/" {
+		return path != "/"
+	}
+	return strings.HasPrefix(path, parent+"/") || strings.HasPrefix(path, parent+"[")
+}
+
+// parentPath returns the path of the object or array containing path.
+func parentPath(path string) string {
+	if strings.HasSuffix(path, "]") {
+		if i := strings.LastIndex(path, "["); i > 0 {
+			return path[:i]
+		}
+	}
+	if i := strings.LastIndex(path, "/"); i > 0 {
+		return path[:i]
+	}
+	return "/"
+}
@@ -0,0 +1,210 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pfrederiksen/configdiff/diff"
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+const testSchema = `
+$schema: https://json-schema.org/draft/2020-12/schema
+type: object
+required: [name]
+properties:
+  name: {type: string}
+  replicas: {type: integer, minimum: 1}
+  ports:
+    type: array
+    items: {type: integer}
+  containers:
+    type: array
+    items:
+      type: object
+      required: [image]
+      properties:
+        image: {type: string}
+`
+
+func mustValidator(t *testing.T) *Validator {
+	t.Helper()
+	v, err := CompileValidator([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("CompileValidator() error = %v", err)
+	}
+	return v
+}
+
+func TestValidator_Validate(t *testing.T) {
+	v := mustValidator(t)
+
+	tests := []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{
+			name: "valid",
+			doc:  "name: web\nreplicas: 3\nports: [80, 443]",
+		},
+		{
+			name: "big integers stay integers",
+			doc:  "name: web\nreplicas: 123456789012345678901234567890",
+		},
+		{
+			name: "missing required key",
+			doc:  "replicas: 3",
+			want: []string{"/: missing property 'name'"},
+		},
+		{
+			name: "array element paths",
+			doc:  "name: web\nports: [80, \"x\"]\ncontainers:\n  - image: nginx\n  - name: sidecar",
+			want: []string{
+				"/containers[1]: missing property 'image'",
+				"/ports[1]: got string, want integer",
+			},
+		},
+		{
+			name: "non-integer number",
+			doc:  "name: web\nreplicas: 0.5",
+			want: []string{"/replicas: got number, want integer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range v.Validate(mustParseYAML(t, tt.doc)) {
+				got = append(got, e.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileValidator_Invalid(t *testing.T) {
+	for _, data := range []string{"[1, 2", "type: 12"} {
+		if _, err := CompileValidator([]byte(data)); err == nil {
+			t.Errorf("CompileValidator(%q) expected error", data)
+		}
+	}
+}
+
+func TestCompileValidator_ExternalRef(t *testing.T) {
+	dir := t.TempDir()
+	external := filepath.Join(dir, "external.json")
+	if err := os.WriteFile(external, []byte(`{"type": "string"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ref  string
+	}{
+		{"file URL", "file://" + filepath.ToSlash(external)},
+		{"http URL", "http://example.com/schema.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := "properties:\n  name: {$ref: '" + tt.ref + "'}\n"
+			if _, err := CompileValidator([]byte(data)); err == nil {
+				t.Errorf("CompileValidator() loaded $ref %q, want error", tt.ref)
+			}
+		})
+	}
+}
+
+func TestValidation_FlagChanges(t *testing.T) {
+	v := mustValidator(t)
+
+	oldDoc := mustParseYAML(t, "name: web\nreplicas: 2\nports: [80]\nlabel: a")
+	newDoc := mustParseYAML(t, "replicas: 0\nports: [\"x\"]\nlabel: b")
+
+	changes, err := diff.Diff(oldDoc, newDoc, diff.Options{})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	validation := v.Compare(oldDoc, newDoc)
+	if !validation.Breaking() {
+		t.Errorf("Breaking() = false, want true")
+	}
+	if got := len(validation.Introduced()); got != 3 {
+		t.Errorf("Introduced() has %d errors, want 3", got)
+	}
+
+	validation.FlagChanges(changes)
+
+	want := map[string]diff.Severity{
+		"/name":     diff.SeverityError,
+		"/replicas": diff.SeverityError,
+		"/ports[0]": diff.SeverityError,
+		"/label":    "",
+	}
+	for _, c := range changes {
+		if c.Severity != want[c.Path] {
+			t.Errorf("%s severity = %q, want %q", c.Path, c.Severity, want[c.Path])
+		}
+	}
+}
+
+func TestValidation_FlagChanges_PreexistingErrors(t *testing.T) {
+	v := mustValidator(t)
+
+	// Errors the old document already had are not blamed on the change
+	oldDoc := mustParseYAML(t, "replicas: 0")
+	newDoc := mustParseYAML(t, "replicas: 0\nports: [80]")
+
+	changes, err := diff.Diff(oldDoc, newDoc, diff.Options{})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	validation := v.Compare(oldDoc, newDoc)
+	if validation.Breaking() {
+		t.Errorf("Breaking() = true, want false")
+	}
+
+	validation.FlagChanges(changes)
+	for _, c := range changes {
+		if c.Severity != "" {
+			t.Errorf("%s severity = %q, want none", c.Path, c.Severity)
+		}
+	}
+}
+
+func TestInstancePath(t *testing.T) {
+	doc := mustParseYAML(t, "a:\n  b: [{c: 1}]\n  \"0\": x")
+
+	tests := []struct {
+		tokens []string
+		want   string
+	}{
+		{nil, "/"},
+		{[]string{"a"}, "/a"},
+		{[]string{"a", "b", "0"}, "/a/b[0]"},
+		{[]string{"a", "b", "0", "c"}, "/a/b[0]/c"},
+		{[]string{"a", "0"}, "/a/0"},
+	}
+
+	for _, tt := range tests {
+		if got := instancePath(doc, tt.tokens); got != tt.want {
+			t.Errorf("instancePath(%q) = %q, want %q", tt.tokens, got, tt.want)
+		}
+	}
+}
+
+func TestToValue_Numbers(t *testing.T) {
+	doc := tree.NewArray([]*tree.Node{tree.NewInt(3), tree.NewNumber(1.5)})
+	got := toValue(doc).([]any)
+	if got[0] != any(json.Number("3")) || got[1] != any(json.Number("1.5")) {
+		t.Errorf("toValue() = %#v", got)
+	}
+}
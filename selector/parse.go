@@ -0,0 +1,285 @@
+package selector
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// parser reads a selector one segment at a time.
+type parser struct {
+	src string
+	pos int
+}
+
+// errorf returns an *Error at offset.
+func (p *parser) errorf(offset int, format string, args ...interface{}) error {
+	return &Error{Pattern: p.src, Offset: offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+// peek returns the next byte, or 0 at the end.
+func (p *parser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+// segment parses the segment at the current position, up to the next '/'.
+// bare reports a segment that is a single *.
+func (p *parser) segment() (steps []step, bare bool, err error) {
+	start := p.pos
+
+	switch p.peek() {
+	case '~':
+		st, err := p.regexpKey()
+		if err != nil {
+			return nil, false, err
+		}
+		return []step{st}, false, nil
+
+	case '[':
+		// Element selectors without a key
+
+	case '/', 0:
+		return nil, false, p.errorf(start, "empty segment")
+
+	default:
+		literal, glob, wild := p.key()
+		switch raw := p.src[start:p.pos]; {
+		case raw == "**":
+			if p.peek() == '[' {
+				return nil, false, p.errorf(p.pos, "** cannot be followed by [")
+			}
+			steps = append(steps, step{kind: stepDescend})
+		case raw == "*":
+			steps = append(steps, step{kind: stepAny})
+			bare = p.peek() != '['
+		case wild:
+			steps = append(steps, step{kind: stepKey, re: regexp.MustCompile("^" + glob + "$")})
+		default:
+			steps = append(steps, step{kind: stepKey, key: literal})
+		}
+	}
+
+	for p.peek() == '[' {
+		st, err := p.bracket()
+		if err != nil {
+			return nil, false, err
+		}
+		steps = append(steps, st)
+	}
+
+	if c := p.peek(); c != '/' && c != 0 {
+		return nil, false, p.errorf(p.pos, "unexpected %q after ]", c)
+	}
+	return steps, bare, nil
+}
+
+// key reads an object key up to the next '/' or '['. A backslash escapes
+// the next character. It returns the key, its glob as a regular expression,
+// and whether the key has an unescaped *.
+func (p *parser) key() (literal, glob string, wild bool) {
+	var lit, re strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '/' || c == '[' {
+			break
+		}
+		if c == '\\' && p.pos+1 < len(p.src) {
+			p.pos++
+			c = p.src[p.pos]
+			lit.WriteByte(c)
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		} else if c == '*' {
+			wild = true
+			lit.WriteByte(c)
+			re.WriteString(".*")
+		} else {
+			lit.WriteByte(c)
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+		p.pos++
+	}
+	return lit.String(), re.String(), wild
+}
+
+// regexpKey reads a ~regexp segment up to the next '/'.
+func (p *parser) regexpKey() (step, error) {
+	start := p.pos
+	p.pos++ // '~'
+
+	for p.pos < len(p.src) && p.src[p.pos] != '/' {
+		p.pos++
+	}
+
+	expr := p.src[start+1 : p.pos]
+	if expr == "" {
+		return step{}, p.errorf(start, "empty regular expression")
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return step{}, p.errorf(start, "invalid regular expression: %v", err)
+	}
+	return step{kind: stepKey, re: re}, nil
+}
+
+// bracket parses an element selector: [*], [index] or [predicates].
+func (p *parser) bracket() (step, error) {
+	open := p.pos
+	end := -1
+	var quote byte
+	for i := open + 1; i < len(p.src); i++ {
+		c := p.src[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if c == '"' || c == '\'' {
+			quote = c
+		} else if c == ']' {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return step{}, p.errorf(open, "unterminated [")
+	}
+	p.pos = end + 1
+
+	content := strings.TrimSpace(p.src[open+1 : end])
+	switch {
+	case content == "":
+		return step{}, p.errorf(open, "empty []")
+	case content == "*":
+		return step{kind: stepElem, index: -1}, nil
+	case isDigits(content):
+		index, err := strconv.Atoi(content)
+		if err != nil {
+			return step{}, p.errorf(open+1, "invalid index %q", content)
+		}
+		return step{kind: stepElem, index: index}, nil
+	case content[0] == '-' && isDigits(content[1:]):
+		return step{}, p.errorf(open+1, "negative index %s", content)
+	}
+
+	st := step{kind: stepElem, index: -1}
+	offset := open + 1
+	for _, part := range splitUnquoted(p.src[open+1:end], ',') {
+		pred, err := p.predicate(part, offset)
+		if err != nil {
+			return step{}, err
+		}
+		st.preds = append(st.preds, pred)
+		offset += len(part) + 1
+	}
+	return st, nil
+}
+
+// predicate parses one predicate of an element selector starting at offset.
+func (p *parser) predicate(src string, offset int) (predicate, error) {
+	i := strings.IndexAny(src, "=!~<>")
+	if i < 0 {
+		field := strings.TrimSpace(src)
+		if field == "" {
+			return predicate{}, p.errorf(offset, "empty predicate")
+		}
+		return predicate{field: field}, nil
+	}
+
+	pred := predicate{field: strings.TrimSpace(src[:i])}
+	if pred.field == "" {
+		return predicate{}, p.errorf(offset, "missing field name")
+	}
+
+	rest := src[i:]
+	op := rest[:1]
+	if len(rest) > 1 && rest[1] == '=' {
+		op = rest[:2]
+	}
+	switch op {
+	case "=", "==":
+		pred.op = "="
+	case "!=", "~=", "<", "<=", ">", ">=":
+		pred.op = op
+	default:
+		return predicate{}, p.errorf(offset+i, "unknown operator %q, expected =, !=, ~=, <, <=, > or >=", op)
+	}
+
+	value, err := unquote(strings.TrimSpace(rest[len(op):]))
+	if err != nil {
+		return predicate{}, p.errorf(offset+i, "invalid value: %v", err)
+	}
+	pred.value = value
+
+	switch pred.op {
+	case "~=":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return predicate{}, p.errorf(offset+i, "invalid regular expression: %v", err)
+		}
+		pred.re = re
+	case "<", "<=", ">", ">=":
+		n, err := tree.ParseNumber(value)
+		if err != nil {
+			return predicate{}, p.errorf(offset+i, "%s needs a number, got %q", pred.op, value)
+		}
+		pred.num = n
+	}
+	return pred, nil
+}
+
+// unquote removes double or single quotes around a value.
+func unquote(s string) (string, error) {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return strconv.Unquote(s)
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return s[1 : len(s)-1], nil
+	}
+	return s, nil
+}
+
+// splitUnquoted splits s at sep outside quotes.
+func splitUnquoted(s string, sep byte) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// isDigits reports whether s is a non-empty string of decimal digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
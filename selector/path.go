@@ -0,0 +1,164 @@
+package selector
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// token is one step of a path: an object key or an array element.
+type token struct {
+	elem   bool
+	key    string            // object key, or the bracket text of an element
+	index  int               // element index, or -1 for keyed elements
+	fields map[string]string // key fields of keyed elements like [name=web]
+}
+
+// tokenize splits a path like /spec/containers[0]/image into tokens. Path
+// segments that are not well-formed keys with element suffixes are taken
+// as literal keys.
+func tokenize(path string) []token {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return nil
+	}
+
+	var tokens []token
+	for _, segment := range splitPath(trimmed) {
+		tokens = appendSegment(tokens, segment)
+	}
+	return tokens
+}
+
+// splitPath splits a path at slashes outside brackets, so key values like
+// [mountPath=/data] stay in their segment.
+func splitPath(path string) []string {
+	var segments []string
+	depth, start := 0, 0
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '[':
+			depth++
+		case ']':
+			if depth > 0 {
+				depth--
+			}
+		case '/':
+			if depth == 0 {
+				segments = append(segments, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, path[start:])
+}
+
+// appendSegment appends the tokens of one path segment.
+func appendSegment(tokens []token, segment string) []token {
+	open := strings.IndexByte(segment, '[')
+	if open < 0 || !strings.HasSuffix(segment, "]") {
+		return append(tokens, token{key: segment, index: -1})
+	}
+
+	var elems []token
+	for rest := segment[open:]; rest != ""; {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 {
+			return append(tokens, token{key: segment, index: -1})
+		}
+		elems = append(elems, elementToken(rest[1:end]))
+		rest = rest[end+1:]
+	}
+
+	if open > 0 {
+		tokens = append(tokens, token{key: segment[:open], index: -1})
+	}
+	return append(tokens, elems...)
+}
+
+// elementToken parses the bracket text of an array element: an index or
+// key fields like name=web,port=80.
+func elementToken(text string) token {
+	tok := token{elem: true, key: text, index: -1}
+	if isDigits(text) {
+		if i, err := strconv.Atoi(text); err == nil {
+			tok.index = i
+		}
+		return tok
+	}
+
+	fields := make(map[string]string)
+	for _, pair := range strings.Split(text, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return tok
+		}
+		fields[name] = value
+	}
+	tok.fields = fields
+	return tok
+}
+
+// resolve returns the node at tokens in root, or nil if there is none.
+// Keyed elements are looked up by their key fields.
+func resolve(root *tree.Node, tokens []token) *tree.Node {
+	current := root
+	for i := range tokens {
+		if current == nil {
+			return nil
+		}
+		tok := &tokens[i]
+
+		switch {
+		case !tok.elem:
+			if current.Kind != tree.KindObject {
+				return nil
+			}
+			current = current.Object[tok.key]
+
+		case current.Kind != tree.KindArray:
+			return nil
+
+		case tok.index >= 0:
+			if tok.index >= len(current.Array) {
+				return nil
+			}
+			current = current.Array[tok.index]
+
+		default:
+			current = findKeyed(current.Array, tok.fields)
+		}
+	}
+	return current
+}
+
+// findKeyed returns the first element whose fields render as the values in
+// fields, or nil.
+func findKeyed(elems []*tree.Node, fields map[string]string) *tree.Node {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	for _, elem := range elems {
+		if elem == nil || elem.Kind != tree.KindObject {
+			continue
+		}
+		match := true
+		for name, want := range fields {
+			v, ok := elem.Object[name]
+			if !ok {
+				match = false
+				break
+			}
+			if s, ok := scalarString(v); !ok || s != want {
+				match = false
+				break
+			}
+		}
+		if match {
+			return elem
+		}
+	}
+	return nil
+}
@@ -0,0 +1,359 @@
+// Package selector compiles path selectors, the patterns that pick values by
+// path in IgnorePaths, ArraySetKeys and the other per-path options.
+//
+// A selector is a slash-separated list of segments matched against paths
+// such as /spec/containers[0]/image:
+//
+//	name           the object key "name"
+//	name*          object keys matching a glob
+//	~regexp        object keys fully matching a regular expression
+//	*              one or more keys and array elements
+//	**             any number of keys and array elements, including none
+//	key[*]         any element of the array at key
+//	key[2]         the element at index 2
+//	key[name=web]  the element whose name field is "web"
+//	key[port>=80]  elements matching a predicate
+//
+// Predicates compare a field of an array element with =, !=, ~= (regular
+// expression), <, <=, > or >=; [field] alone requires the field to exist.
+// Predicates in one bracket are combined with commas, e.g.
+// [name=web,port=80]. Element selectors may also stand alone as a segment,
+// as in /~ports?/[*].
+//
+// A * segment spans as many keys and elements as it needs, as path
+// patterns always have: /spec/*/image matches /spec/containers[0]/image and
+// /a/*/d matches /a/b/c/d. A trailing * matches everything below its
+// parent, so /metadata/* matches /metadata, /metadata/name and
+// /metadata/labels/app. A * followed by a bracket, as in *[0], is a single
+// key.
+package selector
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+// Selector is a compiled path selector.
+type Selector struct {
+	pattern string
+	steps   []step
+}
+
+// stepKind is the kind of a selector step.
+type stepKind int
+
+const (
+	stepKey     stepKind = iota // an object key by name, glob or regexp
+	stepAny                     // any single key or array element
+	stepSpan                    // one or more keys and array elements
+	stepDescend                 // any number of keys and array elements
+	stepElem                    // an array element by index, wildcard or predicates
+)
+
+// step matches one path token, or any number of them for stepDescend.
+type step struct {
+	kind  stepKind
+	key   string         // stepKey: literal key when re is nil
+	re    *regexp.Regexp // stepKey: glob or regular expression
+	index int            // stepElem: element index, or -1
+	preds []predicate    // stepElem: predicates, all of which must hold
+}
+
+// Error describes an invalid selector.
+type Error struct {
+	// Pattern is the selector source.
+	Pattern string
+
+	// Offset is the byte offset of the problem in Pattern.
+	Offset int
+
+	// Msg describes the problem.
+	Msg string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid selector %q: %s at offset %d", e.Pattern, e.Msg, e.Offset)
+}
+
+// Compile parses a selector. The leading slash is optional.
+func Compile(pattern string) (*Selector, error) {
+	p := &parser{src: pattern}
+	if strings.TrimSpace(pattern) == "" {
+		return nil, p.errorf(0, "empty selector")
+	}
+	if pattern[0] == '/' {
+		p.pos = 1
+	}
+
+	var steps []step
+	bareStar := false
+	for p.pos < len(p.src) {
+		segment, bare, err := p.segment()
+		if err != nil {
+			return nil, err
+		}
+		if bare {
+			segment[0].kind = stepSpan
+		}
+		steps = append(steps, segment...)
+		bareStar = bare
+
+		if p.pos < len(p.src) {
+			p.pos++ // the '/' ending the segment
+		}
+	}
+
+	// A trailing * keeps matching everything below its parent
+	if bareStar {
+		steps[len(steps)-1].kind = stepDescend
+	}
+
+	return &Selector{pattern: pattern, steps: steps}, nil
+}
+
+// MustCompile is like Compile but panics if the selector is invalid.
+func MustCompile(pattern string) *Selector {
+	s, err := Compile(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Match reports whether path matches pattern. Invalid patterns match
+// nothing; use Compile to report them.
+func Match(path, pattern string) bool {
+	s, err := Compile(pattern)
+	if err != nil {
+		return false
+	}
+	return s.Match(path)
+}
+
+// String returns the selector source.
+func (s *Selector) String() string {
+	return s.pattern
+}
+
+// Specificity ranks how precisely the selector picks paths, for choosing
+// between several matching selectors: literal keys, indexes and predicates
+// count most, globs and regular expressions less, * and [*] little and **
+// nothing. A longer selector outranks a shorter one with the same score.
+func (s *Selector) Specificity() int {
+	score := 0
+	for i := range s.steps {
+		st := &s.steps[i]
+		switch {
+		case st.kind == stepDescend:
+		case st.kind == stepAny || st.kind == stepSpan:
+			score += 1
+		case st.kind == stepKey && st.re != nil:
+			score += 2
+		case st.kind == stepElem && st.index < 0 && len(st.preds) == 0:
+			score += 1
+		default:
+			score += 3
+		}
+	}
+	return score*16 + min(len(s.steps), 15)
+}
+
+// Match reports whether path matches the selector. Predicates on array
+// elements are evaluated against the key fields in the path, e.g.
+// [name=web]; use MatchIn to evaluate them against the documents.
+func (s *Selector) Match(path string) bool {
+	return s.MatchIn(path)
+}
+
+// MatchIn reports whether path matches the selector, evaluating predicates
+// on array elements against the elements at that path in roots. An element
+// matches when the predicates hold in any of the roots.
+func (s *Selector) MatchIn(path string, roots ...*tree.Node) bool {
+	m := &matcher{tokens: tokenize(path), roots: roots}
+	return m.match(s.steps, 0)
+}
+
+// matcher matches steps against the tokens of one path.
+type matcher struct {
+	tokens []token
+	roots  []*tree.Node
+}
+
+// match reports whether steps match the tokens from index i to the end.
+func (m *matcher) match(steps []step, i int) bool {
+	if len(steps) == 0 {
+		return i == len(m.tokens)
+	}
+
+	st := &steps[0]
+	if st.kind == stepDescend || st.kind == stepSpan {
+		first := i
+		if st.kind == stepSpan {
+			first++
+		}
+		for j := first; j <= len(m.tokens); j++ {
+			if m.match(steps[1:], j) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if i >= len(m.tokens) || !m.matchStep(st, i) {
+		return false
+	}
+	return m.match(steps[1:], i+1)
+}
+
+// matchStep reports whether a single step matches the token at index i.
+func (m *matcher) matchStep(st *step, i int) bool {
+	tok := &m.tokens[i]
+	switch st.kind {
+	case stepAny:
+		return true
+
+	case stepKey:
+		if tok.elem {
+			return false
+		}
+		if st.re != nil {
+			return st.re.MatchString(tok.key)
+		}
+		return tok.key == st.key
+
+	case stepElem:
+		if !tok.elem {
+			return false
+		}
+		if st.index >= 0 && tok.index != st.index {
+			return false
+		}
+		if len(st.preds) == 0 {
+			return true
+		}
+		return m.matchPredicates(st.preds, i)
+	}
+	return false
+}
+
+// matchPredicates reports whether the element at token index i satisfies
+// all predicates, judged by its key fields or by its value in a root.
+func (m *matcher) matchPredicates(preds []predicate, i int) bool {
+	if fields := m.tokens[i].fields; fields != nil {
+		lookup := func(name string) (*tree.Node, bool) {
+			v, ok := fields[name]
+			if !ok {
+				return nil, false
+			}
+			return tree.NewString(v), true
+		}
+		if allHold(preds, lookup) {
+			return true
+		}
+	}
+
+	for _, root := range m.roots {
+		elem := resolve(root, m.tokens[:i+1])
+		if elem == nil || elem.Kind != tree.KindObject {
+			continue
+		}
+		lookup := func(name string) (*tree.Node, bool) {
+			v, ok := elem.Object[name]
+			return v, ok
+		}
+		if allHold(preds, lookup) {
+			return true
+		}
+	}
+	return false
+}
+
+// allHold reports whether every predicate holds for the fields in lookup.
+func allHold(preds []predicate, lookup func(string) (*tree.Node, bool)) bool {
+	for i := range preds {
+		if !preds[i].holds(lookup) {
+			return false
+		}
+	}
+	return true
+}
+
+// predicate tests a field of an array element.
+type predicate struct {
+	field string
+	op    string // "" tests that the field exists
+	value string
+	num   *tree.Node     // value as a number, for ordered comparisons
+	re    *regexp.Regexp // value as a regular expression, for ~=
+}
+
+// holds reports whether the predicate holds for the fields in lookup.
+func (p *predicate) holds(lookup func(string) (*tree.Node, bool)) bool {
+	v, ok := lookup(p.field)
+	if !ok {
+		return false
+	}
+	if p.op == "" {
+		return true
+	}
+
+	if p.num != nil {
+		n := v
+		if v.Kind == tree.KindString {
+			parsed, err := tree.ParseNumber(v.Value.(string))
+			if err != nil {
+				return false
+			}
+			n = parsed
+		}
+		if n.Kind != tree.KindNumber {
+			return false
+		}
+		cmp, ok := tree.CompareNumbers(n, p.num)
+		if !ok {
+			return false
+		}
+		switch p.op {
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		case ">":
+			return cmp > 0
+		default:
+			return cmp >= 0
+		}
+	}
+
+	s, ok := scalarString(v)
+	if !ok {
+		return false
+	}
+	switch p.op {
+	case "=":
+		return s == p.value
+	case "!=":
+		return s != p.value
+	default:
+		return p.re.MatchString(s)
+	}
+}
+
+// scalarString returns the text of a string, number or bool.
+func scalarString(n *tree.Node) (string, bool) {
+	switch n.Kind {
+	case tree.KindString:
+		return n.Value.(string), true
+	case tree.KindNumber:
+		return n.NumberString(), true
+	case tree.KindBool:
+		return strconv.FormatBool(n.Value.(bool)), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,196 @@
+package selector
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pfrederiksen/configdiff/tree"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		path    string
+		pattern string
+		want    bool
+	}{
+		// Literal keys
+		{"/exact/path", "/exact/path", true},
+		{"/exact/path", "exact/path", true},
+		{"/exact/path", "/exact/other", false},
+		{"/exact/path/deeper", "/exact/path", false},
+		{"/", "/", true},
+
+		// Trailing * matches everything below its parent
+		{"/metadata/timestamp", "/metadata/*", true},
+		{"/metadata", "/metadata/*", true},
+		{"/status/conditions/0/type", "/status/*", true},
+		{"/other/timestamp", "/metadata/*", false},
+
+		// * matches one or more keys and elements
+		{"/spec/containers[0]/image", "/spec/containers/*/image", true},
+		{"/spec/containers[name=web]/image", "/spec/containers/*/image", true},
+		{"/spec/containers[0]/image", "/spec/*/image", true},
+		{"/a/b/c/d", "/a/*/d", true},
+		{"/a/d", "/a/*/d", false},
+		{"/spec/template/image", "/*/image", true},
+		{"/spec/image", "/*/image", true},
+		{"/image", "/*/image", false},
+		{"/spec/image/tag", "/*/image", false},
+		{"/spec/image", "/spec/*[0]", false},
+		{"/spec/containers[0]", "/spec/*[0]", true},
+		{"/spec/a/containers[0]", "/spec/*[0]", false},
+
+		// ** matches any depth
+		{"/spec/template/spec/containers[0]/image", "/**/image", true},
+		{"/image", "/**/image", true},
+		{"/spec/image/tag", "/**/image", false},
+		{"/a/b/c", "/a/**", true},
+
+		// Globs and regular expressions
+		{"/metadata/labels/app.kubernetes.io", "/metadata/labels/app.*", true},
+		{"/metadata/labels/team", "/metadata/labels/app.*", false},
+		{"/spec/ports", "/spec/~ports?", true},
+		{"/spec/portsx", "/spec/~ports?", false},
+		{"/spec/ports[0]", "/spec/~ports?/[*]", true},
+		{"/spec/a*b", `/spec/a\*b`, true},
+		{"/spec/axb", `/spec/a\*b`, false},
+
+		// Array elements
+		{"/spec/containers[0]/env", "/spec/containers[*]/env", true},
+		{"/spec/containers[name=web]/env", "/spec/containers[*]/env", true},
+		{"/spec/containers/env", "/spec/containers[*]/env", false},
+		{"/spec/containers[1]/env", "/spec/containers[1]/env", true},
+		{"/spec/containers[0]/env", "/spec/containers[1]/env", false},
+		{"/[2]/name", "/[*]/name", true},
+		{"/matrix[0][1]", "/matrix[*][1]", true},
+
+		// Keyed elements
+		{"/spec/containers[name=web]/image", "/spec/containers[name=web]/image", true},
+		{"/spec/containers[name=api]/image", "/spec/containers[name=web]/image", false},
+		{"/spec/containers[name=api]/image", "/spec/containers[name!=web]/image", true},
+		{"/spec/containers[name=web-1]/image", "/spec/containers[name~=^web-]/image", true},
+		{"/spec/containers[name=web]/image", `/spec/containers[name="web"]/image`, true},
+		{"/spec/containers[name=web]/image", "/spec/containers[name]/image", true},
+		{"/spec/containers[name=web]/image", "/spec/containers[image]/image", false},
+		{"/ports[port=8080]", "/ports[port>=1024]", true},
+		{"/ports[port=80]", "/ports[port>=1024]", false},
+		{"/ports[name=http,port=80]", "/ports[name=http,port=80]", true},
+		{"/ports[name=http,port=80]", "/ports[name=http, port<80]", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" vs "+tt.pattern, func(t *testing.T) {
+			if got := Match(tt.path, tt.pattern); got != tt.want {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.path, tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchIn(t *testing.T) {
+	doc := tree.NewObject(map[string]*tree.Node{
+		"containers": tree.NewArray([]*tree.Node{
+			tree.NewObject(map[string]*tree.Node{
+				"name":  tree.NewString("web"),
+				"image": tree.NewString("nginx:1.25"),
+				"port":  tree.NewInt(8080),
+			}),
+			tree.NewObject(map[string]*tree.Node{
+				"name":  tree.NewString("sidecar"),
+				"image": tree.NewString("envoy:1.30"),
+			}),
+		}),
+	})
+
+	tests := []struct {
+		path    string
+		pattern string
+		want    bool
+	}{
+		{"/containers[0]/image", "/containers[name=web]/image", true},
+		{"/containers[1]/image", "/containers[name=web]/image", false},
+		{"/containers[0]/image", "/containers[image~=^nginx]/image", true},
+		{"/containers[0]/image", "/containers[port>8000]/image", true},
+		{"/containers[1]/image", "/containers[port]/image", false},
+		{"/containers[5]/image", "/containers[name=web]/image", false},
+		{"/containers[name=sidecar]/image", "/containers[image~=envoy]/image", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" vs "+tt.pattern, func(t *testing.T) {
+			s := MustCompile(tt.pattern)
+			if got := s.MatchIn(tt.path, nil, doc); got != tt.want {
+				t.Errorf("MatchIn(%q) with %q = %v, want %v", tt.path, tt.pattern, got, tt.want)
+			}
+		})
+	}
+
+	// Without documents, predicates on positional elements cannot hold
+	if MustCompile("/containers[name=web]/image").Match("/containers[0]/image") {
+		t.Error("Match() without documents matched a positional element")
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		offset  int
+	}{
+		{"", 0},
+		{"/a//b", 3},
+		{"/a[", 2},
+		{"/a[]", 2},
+		{"/a[-1]", 3},
+		{"/a[name=web", 2},
+		{"/a[0]b", 5},
+		{"/**[0]", 3},
+		{"/a/~(", 3},
+		{"/a/~", 3},
+		{"/a[=web]", 3},
+		{"/a[name!web]", 7},
+		{"/a[port>big]", 7},
+		{"/a[name~=(]", 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			_, err := Compile(tt.pattern)
+			var selErr *Error
+			if !errors.As(err, &selErr) {
+				t.Fatalf("Compile(%q) error = %v, want *Error", tt.pattern, err)
+			}
+			if selErr.Offset != tt.offset {
+				t.Errorf("Compile(%q) offset = %d, want %d (%v)", tt.pattern, selErr.Offset, tt.offset, err)
+			}
+		})
+	}
+}
+
+func TestMustCompile_Panics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("MustCompile() did not panic")
+		}
+	}()
+	MustCompile("/a[")
+}
+
+func TestSpecificity(t *testing.T) {
+	// Each selector is more specific than the next
+	ordered := []string{
+		"/spec/template/spec/containers",
+		"/spec/containers[*]/env",
+		"/**/spec/containers",
+		"/spec/~cont.*",
+		"/**/containers",
+		"/**",
+	}
+
+	for i := 1; i < len(ordered); i++ {
+		a, b := MustCompile(ordered[i-1]), MustCompile(ordered[i])
+		if a.Specificity() <= b.Specificity() {
+			t.Errorf("Specificity(%q) = %d, want more than Specificity(%q) = %d",
+				a, a.Specificity(), b, b.Specificity())
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package tree
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"math/big"
+	"sort"
+)
+
+// Hash returns a structural hash of the subtree rooted at n: nodes that are
+// Equal hash alike, whatever the order of their object keys or how their
+// numbers are stored. Paths are not hashed.
+//
+// Hashes are 64-bit FNV-1a, not cryptographic, so unequal trees collide
+// with a probability of about 2^-64. Hash returns the hash cached by Rehash
+// if there is one, and otherwise walks the whole subtree; use Hashes to
+// hash many subtrees of an unhashed tree in one pass.
+func (n *Node) Hash() uint64 {
+	return hashNode(n, nil)
+}
+
+// Rehash computes the structural hash of every node in the subtree rooted
+// at n and caches it in the node, so that later calls to Hash return at
+// once. Trees returned by package parse are hashed. Call Rehash again after
+// modifying a hashed tree, and not while other goroutines read it.
+func (n *Node) Rehash() {
+	if n == nil {
+		return
+	}
+	for _, v := range n.Object {
+		v.Rehash()
+	}
+	for _, elem := range n.Array {
+		elem.Rehash()
+	}
+
+	n.hashed = false
+	n.hash = hashNode(n, nil)
+	n.hashed = true
+}
+
+// Hashed reports whether n caches its hash, set by Rehash.
+func (n *Node) Hashed() bool {
+	return n != nil && n.hashed
+}
+
+// Hashes memoizes the structural hashes of the unhashed subtrees of one or
+// more trees, so each node is hashed once; see Rehash for hashed trees. It
+// holds no state in the nodes, so the trees may be shared between
+// goroutines, each with its own Hashes. A Hashes must not be used after its
+// trees are modified, or concurrently.
+type Hashes map[*Node]uint64
+
+// Of returns the structural hash of n, like n.Hash().
+func (h Hashes) Of(n *Node) uint64 {
+	return hashNode(n, h)
+}
+
+// hashNode returns the structural hash of n, cached in n or memoized in
+// memo if not nil.
+func hashNode(n *Node, memo Hashes) uint64 {
+	if n == nil {
+		return nilHash
+	}
+	if n.hashed {
+		return n.hash
+	}
+	if sum, ok := memo[n]; ok {
+		return sum
+	}
+
+	h := fnv.New64a()
+	var buf [8]byte
+	writeUint := func(v uint64) {
+		binary.BigEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	writeString := func(s string) {
+		writeUint(uint64(len(s)))
+		h.Write([]byte(s))
+	}
+
+	writeUint(uint64(n.Kind))
+	switch n.Kind {
+	case KindBool:
+		if n.Value.(bool) {
+			writeUint(1)
+		} else {
+			writeUint(0)
+		}
+	case KindString:
+		writeString(n.Value.(string))
+	case KindNumber:
+		writeString(numberKey(n))
+	case KindObject:
+		keys := make([]string, 0, len(n.Object))
+		for k := range n.Object {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeUint(uint64(len(keys)))
+		for _, k := range keys {
+			writeString(k)
+			writeUint(hashNode(n.Object[k], memo))
+		}
+	case KindArray:
+		writeUint(uint64(len(n.Array)))
+		for _, elem := range n.Array {
+			writeUint(hashNode(elem, memo))
+		}
+	}
+
+	sum := h.Sum64()
+	if memo != nil {
+		memo[n] = sum
+	}
+	return sum
+}
+
+// nilHash is the hash of a nil node.
+const nilHash uint64 = 0xcbf29ce484222325
+
+// numberKey renders a number so that values equal under CompareNumbers
+// render alike, however they are stored.
+func numberKey(n *Node) string {
+	if v, ok := n.Value.(float64); ok && v == math.Trunc(v) && !math.IsInf(v, 0) {
+		i, _ := new(big.Float).SetFloat64(v).Int(nil)
+		return i.String()
+	}
+	return n.NumberString()
+}
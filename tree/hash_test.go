@@ -0,0 +1,116 @@
+package tree
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestNodeHash(t *testing.T) {
+	obj := func(kvs ...interface{}) *Node {
+		m := make(map[string]*Node)
+		for i := 0; i < len(kvs); i += 2 {
+			m[kvs[i].(string)] = kvs[i+1].(*Node)
+		}
+		return NewObject(m)
+	}
+
+	tests := []struct {
+		name string
+		a, b *Node
+		same bool
+	}{
+		{"equal strings", NewString("a"), NewString("a"), true},
+		{"different strings", NewString("a"), NewString("b"), false},
+		{"string and number", NewString("1"), NewInt(1), false},
+		{"int and float", NewInt(3), NewNumber(3), true},
+		{"big int and float", NewBigInt(new(big.Int).Lsh(big.NewInt(1), 80)), NewNumber(1 << 80), true},
+		{"fractions", NewNumber(0.5), NewNumber(0.25), false},
+		{"bools", NewBool(true), NewBool(false), false},
+		{"null and empty string", NewNull(), NewString(""), false},
+		{
+			name: "objects with equal values",
+			a:    obj("x", NewInt(1), "y", NewString("b")),
+			b:    obj("y", NewString("b"), "x", NewNumber(1)),
+			same: true,
+		},
+		{
+			name: "keys and values are not interchangeable",
+			a:    obj("a", NewString("b")),
+			b:    obj("b", NewString("a")),
+			same: false,
+		},
+		{
+			name: "array order matters",
+			a:    NewArray([]*Node{NewString("a"), NewString("b")}),
+			b:    NewArray([]*Node{NewString("b"), NewString("a")}),
+			same: false,
+		},
+		{
+			name: "empty object and array",
+			a:    NewObject(map[string]*Node{}),
+			b:    NewArray([]*Node{}),
+			same: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := tt.a.Hash() == tt.b.Hash(); same != tt.same {
+				t.Errorf("Hash() equal = %v, want %v", same, tt.same)
+			}
+			if eq := tt.a.Equal(tt.b); eq != tt.same {
+				t.Errorf("Equal() = %v, want %v", eq, tt.same)
+			}
+		})
+	}
+}
+
+func TestHashes(t *testing.T) {
+	n := NewObject(map[string]*Node{"a": NewString("x"), "b": NewArray([]*Node{NewInt(1)})})
+	h := make(Hashes)
+	if got := h.Of(n); got != n.Hash() {
+		t.Fatalf("Of() = %x, want %x", got, n.Hash())
+	}
+	if len(h) != 4 {
+		t.Errorf("Of() memoized %d nodes, want 4", len(h))
+	}
+
+	// Nodes hold no cached state, so a modified tree hashes afresh
+	before := n.Hash()
+	n.Object["a"].Value = "y"
+	if n.Hash() == before {
+		t.Errorf("Hash() of modified tree = original hash")
+	}
+	if !n.Equal(NewObject(map[string]*Node{"a": NewString("y"), "b": NewArray([]*Node{NewInt(1)})})) {
+		t.Errorf("Equal() of modified tree = false")
+	}
+}
+
+func TestRehash(t *testing.T) {
+	n := NewObject(map[string]*Node{"a": NewString("x"), "b": NewArray([]*Node{NewInt(1)})})
+	want := n.Hash()
+	if n.Hashed() {
+		t.Fatalf("Hashed() before Rehash = true")
+	}
+
+	n.Rehash()
+	if !n.Hashed() || !n.Object["b"].Array[0].Hashed() {
+		t.Fatalf("Hashed() after Rehash = false")
+	}
+	if got := n.Hash(); got != want {
+		t.Errorf("Hash() after Rehash = %x, want %x", got, want)
+	}
+	if clone := n.Clone(); !clone.Hashed() || clone.Hash() != want {
+		t.Errorf("Clone() dropped the cached hash")
+	}
+
+	// The cached hash stands until the tree is hashed again
+	n.Object["a"].Value = "y"
+	if n.Hash() != want {
+		t.Errorf("Hash() of modified tree changed before Rehash")
+	}
+	n.Rehash()
+	if n.Hash() == want {
+		t.Errorf("Hash() after Rehash of modified tree = original hash")
+	}
+}
@@ -0,0 +1,154 @@
+package tree
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Number nodes hold one of three value types:
+//
+//   - int64 for integers that fit in 64 bits,
+//   - *big.Int for larger integers,
+//   - float64 for everything else.
+//
+// Integers are kept exact so that large IDs such as 9007199254740993, which
+// cannot be represented as a float64, compare correctly.
+
+// NewInt creates an integer numeric node.
+func NewInt(v int64) *Node {
+	return &Node{Kind: KindNumber, Value: v}
+}
+
+// NewBigInt creates an arbitrary-precision integer node. Values that fit in
+// an int64 are stored as int64.
+func NewBigInt(v *big.Int) *Node {
+	if v.IsInt64() {
+		return NewInt(v.Int64())
+	}
+	return &Node{Kind: KindNumber, Value: new(big.Int).Set(v)}
+}
+
+// ParseNumber creates a numeric node from its decimal text representation,
+// keeping integers exact.
+func ParseNumber(s string) (*Node, error) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return NewInt(i), nil
+	}
+	if isInteger(s) {
+		if i, ok := new(big.Int).SetString(strings.TrimPrefix(s, "+"), 10); ok {
+			return NewBigInt(i), nil
+		}
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return NewNumber(f), nil
+}
+
+// isInteger reports whether s is an optionally signed string of decimal digits.
+func isInteger(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Float64 returns the value of a number node as a float64, which may lose
+// precision for large integers. It returns false for non-number nodes.
+func (n *Node) Float64() (float64, bool) {
+	if n == nil || n.Kind != KindNumber {
+		return 0, false
+	}
+
+	switch v := n.Value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f, true
+	default:
+		return 0, false
+	}
+}
+
+// IsInteger reports whether n is a number node holding an integer value.
+func (n *Node) IsInteger() bool {
+	if n == nil || n.Kind != KindNumber {
+		return false
+	}
+
+	switch v := n.Value.(type) {
+	case int64, *big.Int:
+		return true
+	case float64:
+		return !math.IsInf(v, 0) && v == math.Trunc(v)
+	default:
+		return false
+	}
+}
+
+// NumberString formats the value of a number node, printing integers
+// (including integral floats) without an exponent or fraction.
+func (n *Node) NumberString() string {
+	switch v := n.Value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case *big.Int:
+		return v.String()
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e21 {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// CompareNumbers compares two number nodes exactly, regardless of how their
+// values are stored. It returns -1, 0 or +1, and false if either node is not
+// a number or is NaN.
+func CompareNumbers(a, b *Node) (int, bool) {
+	x, ok := bigFloat(a)
+	if !ok {
+		return 0, false
+	}
+	y, ok := bigFloat(b)
+	if !ok {
+		return 0, false
+	}
+	return x.Cmp(y), true
+}
+
+// bigFloat converts a number node to an exact big.Float.
+func bigFloat(n *Node) (*big.Float, bool) {
+	if n == nil || n.Kind != KindNumber {
+		return nil, false
+	}
+
+	switch v := n.Value.(type) {
+	case int64:
+		return new(big.Float).SetInt64(v), true
+	case *big.Int:
+		return new(big.Float).SetInt(v), true
+	case float64:
+		if math.IsNaN(v) {
+			return nil, false
+		}
+		return new(big.Float).SetFloat64(v), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,95 @@
+package tree
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{"42", int64(42)},
+		{"-7", int64(-7)},
+		{"9007199254740993", int64(9007199254740993)},
+		{"0.5", 0.5},
+		{"1e3", 1000.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			n, err := ParseNumber(tt.input)
+			if err != nil {
+				t.Fatalf("ParseNumber() error = %v", err)
+			}
+			if n.Value != tt.want {
+				t.Errorf("ParseNumber() = %#v, want %#v", n.Value, tt.want)
+			}
+		})
+	}
+
+	n, err := ParseNumber("123456789012345678901234567890")
+	if err != nil {
+		t.Fatalf("ParseNumber() error = %v", err)
+	}
+	if v, ok := n.Value.(*big.Int); !ok || v.String() != "123456789012345678901234567890" {
+		t.Errorf("ParseNumber() = %#v, want *big.Int", n.Value)
+	}
+
+	if _, err := ParseNumber("abc"); err == nil {
+		t.Error("ParseNumber(\"abc\") should fail")
+	}
+}
+
+func TestCompareNumbers(t *testing.T) {
+	big1, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	big2, _ := new(big.Int).SetString("123456789012345678901234567891", 10)
+
+	tests := []struct {
+		name   string
+		a, b   *Node
+		want   int
+		wantOK bool
+	}{
+		{"int and float", NewInt(3), NewNumber(3.0), 0, true},
+		{"int less than float", NewInt(3), NewNumber(3.5), -1, true},
+		{"precise ints", NewInt(9007199254740993), NewInt(9007199254740992), 1, true},
+		{"int and float beyond float precision", NewInt(9007199254740993), NewNumber(9007199254740992), 1, true},
+		{"big ints", NewBigInt(big1), NewBigInt(big2), -1, true},
+		{"NaN", NewNumber(math.NaN()), NewNumber(math.NaN()), 0, false},
+		{"not a number", NewString("3"), NewInt(3), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := CompareNumbers(tt.a, tt.b)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("CompareNumbers() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNumberString(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tenth := 0.1
+
+	tests := []struct {
+		node *Node
+		want string
+	}{
+		{NewInt(42), "42"},
+		{NewNumber(3), "3"},
+		{NewNumber(tenth + 0.2), "0.30000000000000004"},
+		{NewNumber(1e300), "1e+300"},
+		{NewBigInt(huge), "123456789012345678901234567890"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.node.NumberString(); got != tt.want {
+			t.Errorf("NumberString(%#v) = %s, want %s", tt.node.Value, got, tt.want)
+		}
+	}
+}
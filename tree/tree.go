@@ -70,6 +70,11 @@ type Node struct {
 	// Path is the canonical path to this node from the root.
 	// Example: "/spec/template/spec/containers[0]/image"
 	Path string
+
+	// hash caches the structural hash of the node once hashed is set, by
+	// Rehash.
+	hash   uint64
+	hashed bool
 }
 
 // NewNull creates a null node.
@@ -109,9 +114,11 @@ func (n *Node) Clone() *Node {
 	}
 
 	cloned := &Node{
-		Kind:  n.Kind,
-		Value: n.Value,
-		Path:  n.Path,
+		Kind:   n.Kind,
+		Value:  n.Value,
+		Path:   n.Path,
+		hash:   n.hash,
+		hashed: n.hashed,
 	}
 
 	if n.Object != nil {
@@ -146,7 +153,10 @@ func (n *Node) Equal(other *Node) bool {
 	switch n.Kind {
 	case KindNull:
 		return true
-	case KindBool, KindNumber, KindString:
+	case KindNumber:
+		cmp, ok := CompareNumbers(n, other)
+		return ok && cmp == 0
+	case KindBool, KindString:
 		return n.Value == other.Value
 	case KindObject:
 		if len(n.Object) != len(other.Object) {
@@ -174,6 +184,38 @@ func (n *Node) Equal(other *Node) bool {
 	return false
 }
 
+// LeafCount returns the number of scalar leaves in the subtree rooted at n.
+// Empty objects and arrays count as a single leaf so that every node
+// contributes to the size of the tree.
+func (n *Node) LeafCount() int {
+	if n == nil {
+		return 0
+	}
+
+	switch n.Kind {
+	case KindObject:
+		if len(n.Object) == 0 {
+			return 1
+		}
+		count := 0
+		for _, v := range n.Object {
+			count += v.LeafCount()
+		}
+		return count
+	case KindArray:
+		if len(n.Array) == 0 {
+			return 1
+		}
+		count := 0
+		for _, elem := range n.Array {
+			count += elem.LeafCount()
+		}
+		return count
+	default:
+		return 1
+	}
+}
+
 // SortedKeys returns the sorted keys of an object node.
 // Returns nil for non-object nodes.
 func (n *Node) SortedKeys() []string {
@@ -436,3 +436,36 @@ func TestGetByPath(t *testing.T) {
 		})
 	}
 }
+
+func TestNodeLeafCount(t *testing.T) {
+	tests := []struct {
+		name string
+		node *Node
+		want int
+	}{
+		{"nil", nil, 0},
+		{"scalar", NewString("x"), 1},
+		{"empty object", NewObject(map[string]*Node{}), 1},
+		{"empty array", NewArray([]*Node{}), 1},
+		{
+			name: "nested",
+			node: NewObject(map[string]*Node{
+				"name": NewString("app"),
+				"ports": NewArray([]*Node{
+					NewNumber(80),
+					NewNumber(443),
+				}),
+				"labels": NewObject(map[string]*Node{}),
+			}),
+			want: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.node.LeafCount(); got != tt.want {
+				t.Errorf("LeafCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}